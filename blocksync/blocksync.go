@@ -1,10 +1,12 @@
 package blocksync
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/KYVENetwork/ksync/blocksync/helpers"
 	"github.com/KYVENetwork/ksync/bootstrap"
+	"github.com/KYVENetwork/ksync/control"
 	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 	"strings"
@@ -15,67 +17,95 @@ var (
 	logger = utils.KsyncLogger("block-sync")
 )
 
-func PerformBlockSyncValidationChecks(chainRest string, blockRpcConfig *types.BlockRpcConfig, blockPoolId *int64, continuationHeight, targetHeight int64, checkEndHeight, userInput bool) error {
+// PerformBlockSyncValidationChecks checks if the blocks from the continuation height to the target height are
+// available and returns the plan of the blocks which get synced
+func PerformBlockSyncValidationChecks(chainRest *utils.EndpointGroup, blockRpcConfig *types.BlockRpcConfig, blockPoolId *int64, continuationHeight, targetHeight int64, checkEndHeight, userInput bool) (*types.BlockSyncPlan, error) {
 	logger.Info().Msg(fmt.Sprintf("loaded current block height of node: %d", continuationHeight-1))
 
 	// perform boundary checks
 	_, startHeight, endHeight, err := helpers.GetBlockBoundaries(chainRest, blockRpcConfig, blockPoolId)
 	if err != nil {
-		return fmt.Errorf("failed to get block boundaries: %w", err)
+		return nil, fmt.Errorf("failed to get block boundaries: %w", err)
 	}
 
 	logger.Info().Msg(fmt.Sprintf("retrieved block boundaries, earliest block height = %d, latest block height %d", startHeight, endHeight))
 
 	if continuationHeight < startHeight {
-		return fmt.Errorf("app is currently at height %d but first available block on pool is %d", continuationHeight, startHeight)
+		return nil, fmt.Errorf("app is currently at height %d but first available block on pool is %d", continuationHeight, startHeight)
 	}
 
 	if continuationHeight > endHeight {
-		return fmt.Errorf("app is currently at height %d but last available block on pool is %d", continuationHeight, endHeight)
+		return nil, fmt.Errorf("app is currently at height %d but last available block on pool is %d", continuationHeight, endHeight)
 	}
 
 	if targetHeight > 0 && continuationHeight > targetHeight {
-		return fmt.Errorf("requested target height is %d but app is already at block height %d", targetHeight, continuationHeight)
+		return nil, fmt.Errorf("requested target height is %d but app is already at block height %d", targetHeight, continuationHeight)
 	}
 
 	if checkEndHeight && targetHeight > 0 && targetHeight > endHeight {
-		return fmt.Errorf("requested target height is %d but last available block on pool is %d", targetHeight, endHeight)
+		return nil, fmt.Errorf("requested target height is %d but last available block on pool is %d", targetHeight, endHeight)
 	}
 
 	if targetHeight == 0 {
 		logger.Info().Msg(fmt.Sprintf("no target height specified, syncing to latest available block height %d", endHeight))
 	}
 
+	plan := &types.BlockSyncPlan{
+		PoolStartHeight: startHeight,
+		PoolEndHeight:   endHeight,
+		StartHeight:     continuationHeight - 1,
+		EndHeight:       endHeight,
+	}
+
+	if targetHeight > 0 {
+		plan.EndHeight = targetHeight
+	}
+
+	plan.Blocks = plan.EndHeight - continuationHeight + 1
+
 	if userInput {
 		answer := ""
 
-		if targetHeight > 0 {
-			fmt.Printf("\u001B[36m[KSYNC]\u001B[0m should %d blocks from height %d to %d be synced [y/N]: ", targetHeight-continuationHeight+1, continuationHeight-1, targetHeight)
-		} else {
-			fmt.Printf("\u001B[36m[KSYNC]\u001B[0m should %d blocks from height %d to %d be synced [y/N]: ", endHeight-continuationHeight+1, continuationHeight-1, endHeight)
-		}
+		utils.Prompt("should %d blocks from height %d to %d be synced [y/N]: ", plan.Blocks, plan.StartHeight, plan.EndHeight)
 
 		if _, err := fmt.Scan(&answer); err != nil {
-			return fmt.Errorf("failed to read in user input: %s", err)
+			return nil, fmt.Errorf("failed to read in user input: %s", err)
 		}
 
 		if strings.ToLower(answer) != "y" {
-			return errors.New("aborted block-sync")
+			return nil, errors.New("aborted block-sync")
 		}
 	}
 
+	return plan, nil
+}
+
+// checkTargetHeight rejects a new target height beyond the latest available block, the block
+// collector would stop at the latest block and the executor would wait for the target forever
+func checkTargetHeight(chainRest *utils.EndpointGroup, blockRpcConfig *types.BlockRpcConfig, blockPoolId *int64, targetHeight int64) error {
+	_, _, endHeight, err := helpers.GetBlockBoundaries(chainRest, blockRpcConfig, blockPoolId)
+	if err != nil {
+		return fmt.Errorf("failed to get block boundaries: %w", err)
+	}
+
+	if targetHeight > endHeight {
+		return fmt.Errorf("requested target height is %d but last available block on pool is %d", targetHeight, endHeight)
+	}
+
 	return nil
 }
 
-func StartBlockSyncWithBinary(engine types.Engine, binaryPath, homePath, chainId, chainRest, storageRest string, blockRpcConfig *types.BlockRpcConfig, blockPoolId *int64, targetHeight int64, backupCfg *types.BackupConfig, appFlags string, rpcServer, optOut, debug bool) error {
+// StartBlockSyncWithBinary block-syncs the node until the target height. If upgrades are given
+// the binary is switched at every upgrade height, the first upgrade is the active one
+func StartBlockSyncWithBinary(ctx context.Context, engine types.Engine, binaryPath, homePath, chainId string, chainRest *utils.EndpointGroup, storageRest string, blockRpcConfig *types.BlockRpcConfig, blockPoolId *int64, prefetchCfg *types.PrefetchConfig, progressCfg *types.ProgressConfig, targetHeight int64, backupCfg *types.BackupConfig, upgrades []types.BinaryUpgrade, appFlags string, rpcServer, optOut, debug bool) error {
 	logger.Info().Msg("starting block-sync")
 
-	if err := bootstrap.StartBootstrapWithBinary(engine, binaryPath, homePath, chainRest, storageRest, blockRpcConfig, blockPoolId, appFlags, debug); err != nil {
+	if err := bootstrap.StartBootstrapWithBinary(ctx, engine, binaryPath, homePath, chainRest, storageRest, blockRpcConfig, blockPoolId, appFlags, debug); err != nil {
 		return fmt.Errorf("failed to bootstrap node: %w", err)
 	}
 
 	// start binary process thread
-	processId, err := utils.StartBinaryProcessForDB(engine, binaryPath, debug, strings.Split(appFlags, ","))
+	process, err := utils.StartBinaryProcessForDB(engine, binaryPath, debug, strings.Split(appFlags, ","))
 	if err != nil {
 		return fmt.Errorf("failed to start binary process: %w", err)
 	}
@@ -94,24 +124,74 @@ func StartBlockSyncWithBinary(engine types.Engine, binaryPath, homePath, chainId
 
 	currentHeight := engine.GetHeight()
 
-	// db executes blocks against app until target height is reached
-	if err := StartBlockSyncExecutor(engine, chainRest, storageRest, blockRpcConfig, blockPoolId, targetHeight, 0, 0, false, false, backupCfg); err != nil {
-		logger.Error().Msg(fmt.Sprintf("%s", err))
+	// without a target height all upgrades are known, else the target height can not be
+	// raised beyond the height up to which the upgrades were looked up
+	initialTargetHeight, hasUpgrades := targetHeight, len(upgrades) > 0
+
+	control.SetTargetHeight(targetHeight)
+	control.EnableTargetHeightChange(func(newTargetHeight int64) error {
+		if hasUpgrades && initialTargetHeight > 0 && newTargetHeight > initialTargetHeight {
+			return fmt.Errorf("target height can not be raised above %d since the upgrades were only looked up to this height", initialTargetHeight)
+		}
+		return checkTargetHeight(chainRest, blockRpcConfig, blockPoolId, newTargetHeight)
+	})
+	defer control.EnableTargetHeightChange(nil)
+
+	for {
+		// blocks are only applied until the next upgrade, the block at the
+		// upgrade height already has to be applied by the new binary
+		stopHeight, nextUpgrade := targetHeight, getNextUpgrade(upgrades, targetHeight)
+		if nextUpgrade != nil {
+			stopHeight = nextUpgrade.Height - 1
+		}
+
+		// db executes blocks against app until target height is reached
+		if err := StartBlockSyncExecutor(ctx, engine, process, binaryPath, chainRest, storageRest, blockRpcConfig, blockPoolId, prefetchCfg, progressCfg, currentHeight, targetHeight, stopHeight, 0, 0, false, false, backupCfg); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return utils.StopSyncGracefully(engine, process, "block-sync", currentHeight, start)
+			}
+
+			if errors.Is(err, errTargetHeightChanged) {
+				targetHeight, _ = control.TakePendingTargetHeight()
+
+				// the block store can already be beyond the new target height
+				if targetHeight > 0 && engine.GetHeight() >= targetHeight {
+					logger.Info().Msg(fmt.Sprintf("already reached new target height %d", targetHeight))
+					break
+				}
+
+				logger.Info().Msg(fmt.Sprintf("continuing block-sync with target height %d", targetHeight))
+				continue
+			}
+
+			logger.Error().Msg(fmt.Sprintf("%s", err))
+
+			// stop binary process thread
+			if err := process.Stop(); err != nil {
+				return fmt.Errorf("failed to stop binary process: %w", err)
+			}
+
+			return fmt.Errorf("failed to start block-sync executor: %w", err)
+		}
+
+		if nextUpgrade == nil {
+			break
+		}
 
-		// stop binary process thread
-		if err := utils.StopProcessByProcessId(processId); err != nil {
-			return fmt.Errorf("failed to stop process by process id: %w", err)
+		engine, process, err = switchBinary(engine, process, *nextUpgrade, appFlags, rpcServer, debug)
+		if err != nil {
+			return fmt.Errorf("failed to switch binary at upgrade \"%s\": %w", nextUpgrade.Name, err)
 		}
 
-		return fmt.Errorf("failed to start block-sync executor: %w", err)
+		binaryPath, upgrades = nextUpgrade.BinaryPath, upgrades[1:]
 	}
 
 	elapsed := time.Since(start).Seconds()
 	utils.TrackSyncCompletedEvent(0, targetHeight-currentHeight, targetHeight, elapsed, optOut)
 
 	// stop binary process thread
-	if err := utils.StopProcessByProcessId(processId); err != nil {
-		return fmt.Errorf("failed to stop process by process id: %w", err)
+	if err := process.Stop(); err != nil {
+		return fmt.Errorf("failed to stop binary process: %w", err)
 	}
 
 	if err := engine.CloseDBs(); err != nil {
@@ -0,0 +1,41 @@
+package blocksync
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newPoolServer serves block pool 1 which has archived the blocks from start to end height
+func newPoolServer(t *testing.T, startHeight, endHeight int64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/kyve/query/v1beta1/pool/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"pool":{"id":"1","data":{"runtime":"%s","start_key":"%d","current_key":"%d"}}}`, utils.KSyncRuntimeTendermintBsync, startHeight, endHeight)))
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckTargetHeight(t *testing.T) {
+	server := newPoolServer(t, 1, 100)
+
+	chainRest := utils.NewEndpointGroup([]string{server.URL})
+	blockPoolId := int64(1)
+
+	for _, targetHeight := range []int64{50, 100} {
+		if err := checkTargetHeight(chainRest, nil, &blockPoolId, targetHeight); err != nil {
+			t.Errorf("expected target height %d to be accepted, got %s", targetHeight, err)
+		}
+	}
+
+	if err := checkTargetHeight(chainRest, nil, &blockPoolId, 101); err == nil {
+		t.Errorf("expected target height beyond the last block of the pool to be rejected")
+	}
+}
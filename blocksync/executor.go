@@ -1,22 +1,30 @@
 package blocksync
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/KYVENetwork/ksync/backup"
+	"github.com/KYVENetwork/ksync/checkpoint"
 	"github.com/KYVENetwork/ksync/collectors/blocks"
 	"github.com/KYVENetwork/ksync/collectors/pool"
+	"github.com/KYVENetwork/ksync/control"
+	"github.com/KYVENetwork/ksync/metrics"
 	stateSyncHelpers "github.com/KYVENetwork/ksync/statesync/helpers"
 	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
+	"strconv"
 	"time"
 )
 
-var (
-	itemCh  = make(chan types.DataItem, utils.BlockBuffer)
-	errorCh = make(chan error)
-)
+// errTargetHeightChanged is returned by the executor after it stopped because a new target height was requested
+var errTargetHeightChanged = errors.New("target height changed")
 
-func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string, blockRpcConfig *types.BlockRpcConfig, blockPoolId *int64, targetHeight int64, snapshotPoolId, snapshotInterval int64, pruning, skipWaiting bool, backupCfg *types.BackupConfig) error {
+// StartBlockSyncExecutor applies blocks against the app until the target height is reached. If the context
+// gets canceled the block which is currently applied is finished, the proxy app is stopped and
+// context.Canceled is returned. The progress and the metrics are reported from the sync start height to
+// the sync target height, since a sync can run the executor multiple times, e.g. once per upgrade
+func StartBlockSyncExecutor(ctx context.Context, engine types.Engine, process *utils.Process, binaryPath string, chainRest *utils.EndpointGroup, storageRest string, blockRpcConfig *types.BlockRpcConfig, blockPoolId *int64, prefetchCfg *types.PrefetchConfig, progressCfg *types.ProgressConfig, syncStartHeight, syncTargetHeight, targetHeight int64, snapshotPoolId, snapshotInterval int64, pruning, skipWaiting bool, backupCfg *types.BackupConfig) error {
 	continuationHeight, err := engine.GetContinuationHeight()
 	if err != nil {
 		return fmt.Errorf("failed to get continuation height from engine: %w", err)
@@ -27,6 +35,38 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 		return fmt.Errorf("failed to get app height from engine: %w", err)
 	}
 
+	// check if the app and the block store are in a state from which we can continue
+	if err := checkpoint.CheckAppHeight(appHeight, engine.GetHeight(), engine.GetBaseHeight()); err != nil {
+		return fmt.Errorf("app height does not match block store: %w", err)
+	}
+
+	binaryVersion, err := utils.GetBinaryVersion(binaryPath)
+	if err != nil {
+		logger.Error().Msg(fmt.Sprintf("failed to get binary version: %s", err))
+	}
+
+	lastCheckpoint, err := checkpoint.LoadCheckpoint(engine.GetHomePath())
+	if err != nil {
+		logger.Error().Msg(fmt.Sprintf("failed to load checkpoint: %s", err))
+	}
+
+	resumeBundleId := checkpoint.ValidateCheckpoint(lastCheckpoint, blockPoolId, engine.GetName(), binaryVersion, engine.GetHeight())
+
+	currentCheckpoint := &checkpoint.Checkpoint{
+		Height:        engine.GetHeight(),
+		BundleId:      resumeBundleId,
+		PoolId:        blockPoolId,
+		Engine:        engine.GetName(),
+		BinaryVersion: binaryVersion,
+	}
+
+	// always persist the latest progress, no matter how the sync ends
+	defer func() {
+		if err := checkpoint.SaveCheckpoint(engine.GetHomePath(), currentCheckpoint); err != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to save checkpoint: %s", err))
+		}
+	}()
+
 	if err := engine.StartProxyApp(); err != nil {
 		return fmt.Errorf("failed to start proxy app: %w", err)
 	}
@@ -35,6 +75,33 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 		return fmt.Errorf("failed to do handshake: %w", err)
 	}
 
+	// shutdown stops the proxy app after the context got canceled
+	shutdown := func() error {
+		logger.Info().Msg(fmt.Sprintf("stopping block-sync executor at height %d", engine.GetHeight()))
+
+		if err := engine.StopProxyApp(); err != nil {
+			return fmt.Errorf("failed to stop proxy app: %w", err)
+		}
+
+		return ctx.Err()
+	}
+
+	// exited reports the exit of the binary process, which makes the sync impossible to continue
+	exited := func() error {
+		return fmt.Errorf("binary process exited during block-sync: %w", process.ExitError())
+	}
+
+	// wait sleeps between two checks and stops the executor if it got canceled or the binary exited
+	wait := func(duration time.Duration) error {
+		if err := process.Sleep(ctx, duration); err != nil {
+			if ctx.Err() != nil {
+				return shutdown()
+			}
+			return exited()
+		}
+		return nil
+	}
+
 	var poolResponse *types.PoolResponse
 	var runtime *string
 	if blockPoolId != nil {
@@ -45,8 +112,30 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 		runtime = &poolResponse.Pool.Data.Runtime
 	}
 
+	metrics.SetCurrentHeight(engine.GetHeight())
+	metrics.SetTargetHeight(syncTargetHeight)
+
+	control.SetPhase(control.PhaseBlockSync)
+	control.SetEngine(engine.GetName())
+	control.SetHeight(engine.GetHeight())
+	control.SetBlockPoolId(blockPoolId)
+	if snapshotInterval > 0 {
+		control.SetSnapshotPoolId(snapshotPoolId)
+	}
+
+	reporter := newProgressReporter(progressCfg, chainRest, poolResponse, syncStartHeight, syncTargetHeight)
+	reporter.Start()
+	defer reporter.Stop()
+
+	// the collector gets stopped once the executor returns, every run gets its own channels
+	// since the executor is started again after switching the binary at an upgrade
+	itemCh, errorCh := make(chan types.DataItem, utils.BlockBuffer), make(chan error)
+
+	collectorCtx, cancelCollector := context.WithCancel(ctx)
+	defer cancelCollector()
+
 	// start block collector. we must exit if snapshot interval is zero
-	go blocks.StartBlockCollector(itemCh, errorCh, chainRest, storageRest, blockRpcConfig, poolResponse, continuationHeight, targetHeight, snapshotInterval == 0)
+	go blocks.StartBlockCollector(collectorCtx, itemCh, errorCh, chainRest, storageRest, blockRpcConfig, poolResponse, prefetchCfg, resumeBundleId, continuationHeight, targetHeight, snapshotInterval == 0)
 
 	snapshotPoolHeight := int64(0)
 
@@ -54,6 +143,7 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 	// in order to not bloat the KSYNC process
 	if snapshotInterval > 0 && !skipWaiting {
 		snapshotPoolHeight = stateSyncHelpers.GetSnapshotPoolHeight(chainRest, snapshotPoolId)
+		metrics.SetSnapshotPoolHeight(snapshotPoolHeight)
 
 		if continuationHeight > snapshotPoolHeight+(utils.SnapshotPruningAheadFactor*snapshotInterval) {
 			logger.Info().Msg("synced too far ahead of snapshot pool. Waiting for snapshot pool to produce new bundles")
@@ -62,10 +152,13 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 		for {
 			// if we are in that range we wait until the snapshot pool moved on
 			if continuationHeight > snapshotPoolHeight+(utils.SnapshotPruningAheadFactor*snapshotInterval) {
-				time.Sleep(10 * time.Second)
+				if err := wait(10 * time.Second); err != nil {
+					return err
+				}
 
 				// refresh snapshot pool height
 				snapshotPoolHeight = stateSyncHelpers.GetSnapshotPoolHeight(chainRest, snapshotPoolId)
+				metrics.SetSnapshotPoolHeight(snapshotPoolHeight)
 				continue
 			}
 
@@ -74,10 +167,35 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 	}
 
 	for {
+		if err := control.WaitWhilePaused(ctx); err != nil {
+			return shutdown()
+		}
+
+		// a new target height is applied by starting the executor again, this way the
+		// block collector also collects the blocks for the new target height
+		if control.HasPendingTargetHeight() {
+			logger.Info().Msg(fmt.Sprintf("stopping block-sync executor at height %d to change the target height", engine.GetHeight()))
+
+			if err := engine.StopProxyApp(); err != nil {
+				return fmt.Errorf("failed to stop proxy app: %w", err)
+			}
+
+			return errTargetHeightChanged
+		}
+
+		// the time spent waiting for the next block is reported as download time
+		waitStart := time.Now()
+
 		select {
+		case <-ctx.Done():
+			return shutdown()
+		case <-process.Exited():
+			return exited()
 		case err := <-errorCh:
 			return fmt.Errorf("error in block collector: %w", err)
 		case item := <-itemCh:
+			downloadTime := time.Since(waitStart)
+
 			// parse block height from item key
 			height, err := utils.ParseBlockHeightFromKey(item.Key)
 			if err != nil {
@@ -86,10 +204,30 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 
 			prevHeight := height - 1
 
+			applyStart := time.Now()
+
 			if err := engine.ApplyBlock(runtime, item.Value); err != nil {
 				return fmt.Errorf("failed to apply block in engine: %w", err)
 			}
 
+			metrics.ObserveAppliedBlock(engine.GetHeight(), time.Since(applyStart))
+			reporter.BlockApplied(engine.GetHeight(), downloadTime, time.Since(applyStart))
+			control.SetHeight(engine.GetHeight())
+
+			// the block of the current item is not applied yet, so a resumed
+			// sync has to start in the bundle of the current item
+			currentCheckpoint.Height = prevHeight
+			currentCheckpoint.BundleId = nil
+			if bundleId, err := strconv.ParseInt(item.BundleId, 10, 64); err == nil {
+				currentCheckpoint.BundleId = &bundleId
+			}
+
+			if prevHeight%utils.CheckpointInterval == 0 {
+				if err := checkpoint.SaveCheckpoint(engine.GetHomePath(), currentCheckpoint); err != nil {
+					logger.Error().Msg(fmt.Sprintf("failed to save checkpoint: %s", err))
+				}
+			}
+
 			// if we have reached a height where a snapshot should be created by the app
 			// we wait until it is created, else if KSYNC moves to fast the snapshot can
 			// not be properly written to disk. We check if the initial app height is smaller
@@ -101,14 +239,18 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 
 					found, err := engine.IsSnapshotAvailable(prevHeight)
 					if err != nil {
-						logger.Error().Msg(fmt.Sprintf("check snapshot availability failed at height %d", prevHeight))
-						time.Sleep(10 * time.Second)
+						logger.Error().Msg(fmt.Sprintf("check snapshot availability failed at height %d: %s", prevHeight, err))
+						if err := wait(10 * time.Second); err != nil {
+							return err
+						}
 						continue
 					}
 
 					if !found {
 						logger.Info().Msg(fmt.Sprintf("snapshot at height %d was not created yet. Waiting ...", prevHeight))
-						time.Sleep(10 * time.Second)
+						if err := wait(10 * time.Second); err != nil {
+							return err
+						}
 						continue
 					}
 
@@ -118,6 +260,7 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 
 				// refresh snapshot pool height here, because we don't want to fetch this on every block
 				snapshotPoolHeight = stateSyncHelpers.GetSnapshotPoolHeight(chainRest, snapshotPoolId)
+				metrics.SetSnapshotPoolHeight(snapshotPoolHeight)
 			}
 
 			// skip below operations because we don't want to execute them already
@@ -150,13 +293,20 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 
 				chainId, err := engine.GetChainId()
 				if err != nil {
-					return fmt.Errorf("failed to get chain id from genesis: %w")
+					return fmt.Errorf("failed to get chain id from genesis: %w", err)
 				}
 
-				if err = backup.CreateBackup(backupCfg, chainId, prevHeight, false); err != nil {
+				control.SetPhase(control.PhaseBackup)
+				backupStart := time.Now()
+
+				err = backup.CreateBackup(backupCfg, chainId, prevHeight, false)
+				if err != nil {
 					logger.Error().Msg(fmt.Sprintf("failed to create backup: %v", err))
 				}
 
+				metrics.ObserveBackup(time.Since(backupStart), err)
+				control.SetPhase(control.PhaseBlockSync)
+
 				logger.Info().Msg(fmt.Sprintf("finished backup at block height: %d", prevHeight))
 			}
 
@@ -171,10 +321,13 @@ func StartBlockSyncExecutor(engine types.Engine, chainRest, storageRest string,
 				for {
 					// if we are in that range we wait until the snapshot pool moved on
 					if height > snapshotPoolHeight+(utils.SnapshotPruningAheadFactor*snapshotInterval) {
-						time.Sleep(10 * time.Second)
+						if err := wait(10 * time.Second); err != nil {
+							return err
+						}
 
 						// refresh snapshot pool height
 						snapshotPoolHeight = stateSyncHelpers.GetSnapshotPoolHeight(chainRest, snapshotPoolId)
+						metrics.SetSnapshotPoolHeight(snapshotPoolHeight)
 						continue
 					}
 
@@ -7,9 +7,9 @@ import (
 	"github.com/KYVENetwork/ksync/utils"
 )
 
-func GetBlockBoundaries(restEndpoint string, blockRpcConfig *types.BlockRpcConfig, poolId *int64) (*types.PoolResponse, int64, int64, error) {
+func GetBlockBoundaries(chainRest *utils.EndpointGroup, blockRpcConfig *types.BlockRpcConfig, poolId *int64) (*types.PoolResponse, int64, int64, error) {
 	if poolId != nil {
-		return getBlockBoundariesFromPool(restEndpoint, *poolId)
+		return getBlockBoundariesFromPool(chainRest, *poolId)
 	}
 	if blockRpcConfig != nil {
 		return getBlockBoundariesFromRpc(*blockRpcConfig)
@@ -17,9 +17,9 @@ func GetBlockBoundaries(restEndpoint string, blockRpcConfig *types.BlockRpcConfi
 	return nil, 0, 0, fmt.Errorf("both block rpc and pool id are nil")
 }
 
-func getBlockBoundariesFromPool(restEndpoint string, poolId int64) (*types.PoolResponse, int64, int64, error) {
+func getBlockBoundariesFromPool(chainRest *utils.EndpointGroup, poolId int64) (*types.PoolResponse, int64, int64, error) {
 	// load start and latest height
-	poolResponse, err := pool.GetPoolInfo(restEndpoint, poolId)
+	poolResponse, err := pool.GetPoolInfo(chainRest, poolId)
 	if err != nil {
 		return nil, 0, 0, fmt.Errorf("failed to get pool info: %w", err)
 	}
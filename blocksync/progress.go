@@ -0,0 +1,240 @@
+package blocksync
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/collectors/pool"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressSample is the height which was reached at a point in time, the samples
+// within the rate window are used for the rolling blocks per second
+type progressSample struct {
+	time   time.Time
+	height int64
+}
+
+// progressReporter reports how far the block-sync has progressed. It logs a progress line every
+// interval and draws a progress bar at the bottom of the terminal if enabled. The ETA is based on
+// the target height or, if the sync has no target height, on the current key of the block pool
+type progressReporter struct {
+	mu sync.Mutex
+
+	cfg       types.ProgressConfig
+	chainRest *utils.EndpointGroup
+	poolId    *int64
+
+	startHeight  int64
+	height       int64
+	targetHeight int64
+	poolHeight   int64
+
+	downloadTime  time.Duration
+	executionTime time.Duration
+	samples       []progressSample
+
+	bar  bool
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newProgressReporter(progressCfg *types.ProgressConfig, chainRest *utils.EndpointGroup, poolResponse *types.PoolResponse, startHeight, targetHeight int64) *progressReporter {
+	reporter := &progressReporter{
+		cfg:          types.ProgressConfig{Interval: utils.DefaultProgressInterval * time.Second},
+		chainRest:    chainRest,
+		startHeight:  startHeight,
+		height:       startHeight,
+		targetHeight: targetHeight,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	if progressCfg != nil {
+		reporter.cfg = *progressCfg
+	}
+
+	if poolResponse != nil {
+		reporter.poolId = &poolResponse.Pool.Id
+		reporter.poolHeight = parsePoolHeight(poolResponse)
+	}
+
+	reporter.bar = reporter.cfg.Bar && utils.StatusLineSupported()
+	return reporter
+}
+
+// parsePoolHeight returns the height of the latest block archived by the pool, zero if it is unknown
+func parsePoolHeight(poolResponse *types.PoolResponse) int64 {
+	height, err := strconv.ParseInt(poolResponse.Pool.Data.CurrentKey, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return height
+}
+
+// Start reports the progress in the background until the reporter gets stopped
+func (reporter *progressReporter) Start() {
+	go func() {
+		defer close(reporter.done)
+
+		ticker := time.NewTicker(utils.ProgressBarRefreshInterval)
+		defer ticker.Stop()
+
+		lastReport, lastPoolRefresh := time.Now(), time.Now()
+
+		for {
+			select {
+			case <-reporter.stop:
+				if reporter.bar {
+					utils.SetStatusLine("")
+				}
+				return
+			case now := <-ticker.C:
+				reporter.addSample(now)
+
+				if reporter.bar {
+					utils.SetStatusLine(reporter.barLine())
+				}
+
+				if reporter.cfg.Interval > 0 && now.Sub(lastReport) >= reporter.cfg.Interval {
+					logger.Info().Msg(reporter.progressLine())
+					lastReport = now
+				}
+
+				// the pool keeps archiving new blocks, so without a target height the ETA has to follow it
+				if reporter.targetHeight == 0 && reporter.poolId != nil && now.Sub(lastPoolRefresh) >= utils.ProgressPoolRefreshInterval {
+					go reporter.refreshPoolHeight()
+					lastPoolRefresh = now
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the reporter and removes the progress bar
+func (reporter *progressReporter) Stop() {
+	close(reporter.stop)
+	<-reporter.done
+}
+
+// BlockApplied records that the block store reached the height, the download time is the time the
+// executor waited for the block and the execution time is the time it took to apply the block
+func (reporter *progressReporter) BlockApplied(height int64, download, execution time.Duration) {
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+
+	reporter.height = height
+	reporter.downloadTime += download
+	reporter.executionTime += execution
+}
+
+func (reporter *progressReporter) refreshPoolHeight() {
+	poolResponse, err := pool.GetPoolInfo(reporter.chainRest, *reporter.poolId)
+	if err != nil {
+		logger.Error().Msg(fmt.Sprintf("failed to refresh pool height for progress: %s", err))
+		return
+	}
+
+	if height := parsePoolHeight(poolResponse); height > 0 {
+		reporter.mu.Lock()
+		reporter.poolHeight = height
+		reporter.mu.Unlock()
+	}
+}
+
+func (reporter *progressReporter) addSample(now time.Time) {
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+
+	reporter.samples = append(reporter.samples, progressSample{time: now, height: reporter.height})
+
+	for len(reporter.samples) > 2 && now.Sub(reporter.samples[0].time) > utils.ProgressRateWindow {
+		reporter.samples = reporter.samples[1:]
+	}
+}
+
+// progress is a snapshot of the current progress
+type progress struct {
+	startHeight     int64
+	height          int64
+	endHeight       int64
+	percent         float64
+	blocksPerSecond float64
+	downloadShare   float64
+	eta             time.Duration
+}
+
+func (reporter *progressReporter) progress() progress {
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+
+	p := progress{
+		startHeight: reporter.startHeight,
+		height:      reporter.height,
+		endHeight:   reporter.targetHeight,
+		eta:         -1,
+	}
+
+	if p.endHeight == 0 {
+		p.endHeight = reporter.poolHeight
+	}
+
+	if len(reporter.samples) > 1 {
+		first, last := reporter.samples[0], reporter.samples[len(reporter.samples)-1]
+		if elapsed := last.time.Sub(first.time).Seconds(); elapsed > 0 {
+			p.blocksPerSecond = float64(last.height-first.height) / elapsed
+		}
+	}
+
+	if total := reporter.downloadTime + reporter.executionTime; total > 0 {
+		p.downloadShare = float64(reporter.downloadTime) / float64(total)
+	}
+
+	if p.endHeight > p.startHeight {
+		p.percent = min(max(float64(p.height-p.startHeight)/float64(p.endHeight-p.startHeight)*100, 0), 100)
+
+		if p.blocksPerSecond > 0 {
+			remaining := float64(max(p.endHeight-p.height, 0)) / p.blocksPerSecond
+			p.eta = time.Duration(remaining * float64(time.Second)).Round(time.Second)
+		}
+	}
+
+	return p
+}
+
+func (p progress) etaString() string {
+	if p.eta < 0 {
+		return "unknown"
+	}
+
+	return p.eta.String()
+}
+
+func (reporter *progressReporter) progressLine() string {
+	p := reporter.progress()
+
+	split := fmt.Sprintf("download %.0f%% / execution %.0f%%", p.downloadShare*100, (1-p.downloadShare)*100)
+
+	if p.endHeight == 0 {
+		return fmt.Sprintf("synced from height %d to %d, %.2f blocks/s, %s", p.startHeight, p.height, p.blocksPerSecond, split)
+	}
+
+	return fmt.Sprintf("synced from height %d to %d of %d (%.2f%%), %.2f blocks/s, %s, ETA %s", p.startHeight, p.height, p.endHeight, p.percent, p.blocksPerSecond, split, p.etaString())
+}
+
+func (reporter *progressReporter) barLine() string {
+	p := reporter.progress()
+
+	filled := int(p.percent / 100 * utils.ProgressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", utils.ProgressBarWidth-filled)
+
+	if p.endHeight == 0 {
+		return fmt.Sprintf("[%s] height %d | %.2f blocks/s | dl %.0f%% / exec %.0f%%", bar, p.height, p.blocksPerSecond, p.downloadShare*100, (1-p.downloadShare)*100)
+	}
+
+	return fmt.Sprintf("[%s] %.2f%% | height %d/%d | %.2f blocks/s | dl %.0f%% / exec %.0f%% | ETA %s", bar, p.percent, p.height, p.endHeight, p.blocksPerSecond, p.downloadShare*100, (1-p.downloadShare)*100, p.etaString())
+}
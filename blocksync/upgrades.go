@@ -0,0 +1,66 @@
+package blocksync
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/engines"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"strings"
+)
+
+// getNextUpgrade returns the upgrade following the active one if it is not beyond the target height
+func getNextUpgrade(upgrades []types.BinaryUpgrade, targetHeight int64) *types.BinaryUpgrade {
+	if len(upgrades) < 2 {
+		return nil
+	}
+
+	if targetHeight > 0 && upgrades[1].Height > targetHeight {
+		return nil
+	}
+
+	return &upgrades[1]
+}
+
+// switchBinary stops the binary after the last block before the upgrade height was applied and starts
+// the binary of the upgrade. If the upgrade requires a different consensus engine the engine is replaced
+func switchBinary(engine types.Engine, process *utils.Process, upgrade types.BinaryUpgrade, appFlags string, rpcServer bool, debug bool) (types.Engine, *utils.Process, error) {
+	logger.Info().Msg(fmt.Sprintf("reached upgrade \"%s\" at height %d, switching to binary %s with version %s", upgrade.Name, upgrade.Height, upgrade.BinaryPath, upgrade.RecommendedVersion))
+
+	// the rpc server serves the blocks from the dbs which get closed now
+	if rpcServer {
+		engine.StopRPCServer()
+	}
+
+	if err := process.Stop(); err != nil {
+		return nil, nil, fmt.Errorf("failed to stop binary process: %w", err)
+	}
+
+	if err := engine.CloseDBs(); err != nil {
+		return nil, nil, fmt.Errorf("failed to close dbs in engine: %w", err)
+	}
+
+	if upgrade.Engine != "" {
+		if upgradeEngine := engines.EngineFactory(upgrade.Engine, engine.GetEngineConfig()); upgradeEngine.GetName() != engine.GetName() {
+			logger.Info().Msg(fmt.Sprintf("switching consensus engine from %s to %s", engine.GetName(), upgradeEngine.GetName()))
+			engine = upgradeEngine
+		}
+	}
+
+	process, err := utils.StartBinaryProcessForDB(engine, upgrade.BinaryPath, debug, strings.Split(appFlags, ","))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start binary process: %w", err)
+	}
+
+	if err := engine.OpenDBs(); err != nil {
+		if err := process.Stop(); err != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to stop binary process: %s", err))
+		}
+		return nil, nil, fmt.Errorf("failed to open dbs in engine: %w", err)
+	}
+
+	if rpcServer {
+		go engine.StartRPCServer()
+	}
+
+	return engine, process, nil
+}
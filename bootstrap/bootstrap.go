@@ -1,22 +1,24 @@
 package bootstrap
 
 import (
+	"context"
 	"fmt"
 	blocksyncHelpers "github.com/KYVENetwork/ksync/blocksync/helpers"
 	"github.com/KYVENetwork/ksync/bootstrap/helpers"
 	"github.com/KYVENetwork/ksync/collectors/blocks"
+	"github.com/KYVENetwork/ksync/control"
 	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 	"strings"
-	"time"
 )
 
 var (
 	logger = utils.KsyncLogger("bootstrap")
 )
 
-func StartBootstrapWithBinary(engine types.Engine, binaryPath, homePath, chainRest, storageRest string, blockRpcConfig *types.BlockRpcConfig, poolId *int64, appFlags string, debug bool) error {
+func StartBootstrapWithBinary(ctx context.Context, engine types.Engine, binaryPath, homePath string, chainRest *utils.EndpointGroup, storageRest string, blockRpcConfig *types.BlockRpcConfig, poolId *int64, appFlags string, debug bool) error {
 	logger.Info().Msg("starting bootstrap")
+	control.SetPhase(control.PhaseBootstrap)
 
 	if err := engine.OpenDBs(); err != nil {
 		return fmt.Errorf("failed to open dbs in engine: %w", err)
@@ -63,12 +65,12 @@ func StartBootstrapWithBinary(engine types.Engine, binaryPath, homePath, chainRe
 		return fmt.Errorf(fmt.Sprintf("genesis height %d bigger than latest pool height %d", genesisHeight+1, endHeight))
 	}
 
-	item, err := blocks.RetrieveBlock(chainRest, storageRest, blockRpcConfig, poolResponse, genesisHeight)
+	item, err := blocks.RetrieveBlock(ctx, chainRest, storageRest, blockRpcConfig, poolResponse, genesisHeight)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve block %d from pool", genesisHeight)
 	}
 
-	nextItem, err := blocks.RetrieveBlock(chainRest, storageRest, blockRpcConfig, poolResponse, genesisHeight+1)
+	nextItem, err := blocks.RetrieveBlock(ctx, chainRest, storageRest, blockRpcConfig, poolResponse, genesisHeight+1)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve block %d from pool", genesisHeight+1)
 	}
@@ -78,7 +80,7 @@ func StartBootstrapWithBinary(engine types.Engine, binaryPath, homePath, chainRe
 	}
 
 	// start binary process thread
-	processId, err := utils.StartBinaryProcessForP2P(engine, binaryPath, debug, strings.Split(appFlags, ","))
+	process, err := utils.StartBinaryProcessForP2P(engine, binaryPath, debug, strings.Split(appFlags, ","))
 	if err != nil {
 		return err
 	}
@@ -86,13 +88,12 @@ func StartBootstrapWithBinary(engine types.Engine, binaryPath, homePath, chainRe
 	logger.Info().Msg("bootstrapping node. Depending on the size of the genesis file, this step can take several minutes")
 
 	// wait until binary has properly started by testing if the /abci
-	// endpoint is up
-	for {
-		if _, err := helpers.GetAppHeightFromRPC(homePath); err != nil {
-			time.Sleep(5 * time.Second)
-			continue
-		}
-		break
+	// endpoint is up, loading a big genesis file has no upper time limit
+	if err := process.WaitUntilReady(func() error {
+		_, err := helpers.GetAppHeightFromRPC(homePath)
+		return err
+	}, 0); err != nil {
+		return fmt.Errorf("failed to start binary process: %w", err)
 	}
 
 	logger.Info().Msg("loaded genesis file and completed ABCI handshake between app and tendermint")
@@ -100,7 +101,7 @@ func StartBootstrapWithBinary(engine types.Engine, binaryPath, homePath, chainRe
 	// start p2p executors and try to execute the first block on the app
 	if err := engine.ApplyFirstBlockOverP2P(poolResponse.Pool.Data.Runtime, item.Value, nextItem.Value); err != nil {
 		// stop binary process thread
-		if err := utils.StopProcessByProcessId(processId); err != nil {
+		if err := process.Stop(); err != nil {
 			panic(err)
 		}
 
@@ -109,30 +110,32 @@ func StartBootstrapWithBinary(engine types.Engine, binaryPath, homePath, chainRe
 
 	// wait until block was properly executed by testing if the /abci
 	// endpoint returns the correct block height
-	for {
+	if err := process.WaitUntilReady(func() error {
 		height, err := helpers.GetAppHeightFromRPC(homePath)
 		if err != nil {
 			return err
 		}
 
 		if height != genesisHeight {
-			time.Sleep(5 * time.Second)
-			continue
+			return fmt.Errorf("app height %d has not reached genesis height %d yet", height, genesisHeight)
+		}
+
+		return nil
+	}, 0); err != nil {
+		if err := process.Stop(); err != nil {
+			return fmt.Errorf("failed to stop binary process: %w", err)
 		}
 
-		break
+		return fmt.Errorf("failed to execute first block: %w", err)
 	}
 
 	logger.Info().Msg("node was bootstrapped. Cleaning up")
 
-	// stop process by sending signal SIGTERM
-	if err := utils.StopProcessByProcessId(processId); err != nil {
+	// stop process by sending signal SIGTERM and wait until it has shut down
+	if err := process.Stop(); err != nil {
 		return err
 	}
 
-	// wait until process has properly shut down
-	time.Sleep(10 * time.Second)
-
 	logger.Info().Msg("successfully bootstrapped node. Continuing with syncing blocks over DB")
 	return nil
 }
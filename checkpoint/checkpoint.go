@@ -0,0 +1,139 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	logger = utils.KsyncLogger("checkpoint")
+)
+
+const (
+	CheckpointFile = "ksync-checkpoint.json"
+)
+
+// Checkpoint records the progress of a block-sync, so an interrupted sync can directly
+// resume at the bundle it stopped in instead of looking it up again
+type Checkpoint struct {
+	// Height is the last height which was stored in the block store
+	Height int64 `json:"height"`
+
+	// BundleId is the id of the finalized bundle containing the next height,
+	// it is empty if the blocks are collected from a rpc. It replaces the pagination
+	// key of the bundle: the following bundles are read from the local bundle index
+	// and the first page after the index is requested with the id of the next bundle
+	// as offset, which is the same page a stored pagination key would point to
+	BundleId *int64 `json:"bundle_id,omitempty"`
+
+	// PoolId is the id of the block pool the bundle belongs to
+	PoolId *int64 `json:"pool_id,omitempty"`
+
+	Engine        string    `json:"engine"`
+	BinaryVersion string    `json:"binary_version"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// GetCheckpointPath returns the path of the checkpoint file which is stored next to the
+// block store, this way it gets removed together with the data of the node
+func GetCheckpointPath(homePath string) string {
+	return filepath.Join(homePath, "data", CheckpointFile)
+}
+
+// LoadCheckpoint loads the checkpoint of the node, it returns nil if no checkpoint exists
+func LoadCheckpoint(homePath string) (*Checkpoint, error) {
+	data, err := os.ReadFile(GetCheckpointPath(homePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+
+	return &checkpoint, nil
+}
+
+// SaveCheckpoint atomically writes the checkpoint of the node
+func SaveCheckpoint(homePath string, checkpoint *Checkpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	// write to a temporary file first so a crash never
+	// leaves a corrupted checkpoint behind
+	path := GetCheckpointPath(homePath)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// ValidateCheckpoint compares the checkpoint with the block store and returns the bundle id at
+// which the search for the continuation height can start. The checkpoint is only saved every
+// few blocks, so after a crash the block store is usually ahead of it and the bundle id is a
+// lower bound. The bundle id is nil if the checkpoint can not be used, in this case the sync
+// looks up the bundle of the block store height
+func ValidateCheckpoint(checkpoint *Checkpoint, poolId *int64, engineName, binaryVersion string, storeHeight int64) *int64 {
+	if checkpoint == nil {
+		return nil
+	}
+
+	if checkpoint.Engine != engineName {
+		logger.Info().Msg(fmt.Sprintf("checkpoint was created with engine %s but engine %s is used now", checkpoint.Engine, engineName))
+	}
+
+	if checkpoint.BinaryVersion != binaryVersion {
+		logger.Info().Msg(fmt.Sprintf("checkpoint was created with binary version \"%s\" but binary version \"%s\" is used now", checkpoint.BinaryVersion, binaryVersion))
+	}
+
+	if checkpoint.Height > storeHeight {
+		logger.Error().Msg(fmt.Sprintf("block store height %d is %d blocks behind the checkpoint height %d, the last blocks were not persisted. Ignoring checkpoint and continuing from block store", storeHeight, checkpoint.Height-storeHeight, checkpoint.Height))
+		return nil
+	}
+
+	if checkpoint.BundleId == nil || poolId == nil || checkpoint.PoolId == nil || *checkpoint.PoolId != *poolId {
+		logger.Info().Msg(fmt.Sprintf("found checkpoint at height %d", checkpoint.Height))
+		return nil
+	}
+
+	if checkpoint.Height < storeHeight {
+		logger.Info().Msg(fmt.Sprintf("block store height %d is %d blocks ahead of the checkpoint height %d, resuming from bundle %d of pool %d or a following bundle", storeHeight, storeHeight-checkpoint.Height, checkpoint.Height, *checkpoint.BundleId, *checkpoint.PoolId))
+		return checkpoint.BundleId
+	}
+
+	logger.Info().Msg(fmt.Sprintf("resuming from checkpoint at height %d in bundle %d of pool %d", checkpoint.Height, *checkpoint.BundleId, *checkpoint.PoolId))
+	return checkpoint.BundleId
+}
+
+// CheckAppHeight compares the height of the app with the height of the block store and reports
+// how they differ. Only if the app is more than one block ahead of the block store the sync can not
+// continue, if the app is behind the missing blocks are replayed during the handshake
+func CheckAppHeight(appHeight, storeHeight, baseHeight int64) error {
+	switch {
+	case appHeight == storeHeight:
+		return nil
+	case appHeight == storeHeight+1:
+		logger.Info().Msg(fmt.Sprintf("app height %d is one block ahead of block store height %d, the block was applied but not stored and gets verified again", appHeight, storeHeight))
+		return nil
+	case appHeight > storeHeight:
+		return fmt.Errorf("app height %d is %d blocks ahead of block store height %d, the app committed blocks which are missing in the block store. Reset the node or restore a backup to continue", appHeight, appHeight-storeHeight, storeHeight)
+	case appHeight < baseHeight-1:
+		return fmt.Errorf("app height %d is %d blocks behind block store height %d, but the block store only contains blocks from height %d so the missing blocks can not be replayed", appHeight, storeHeight-appHeight, storeHeight, baseHeight)
+	default:
+		logger.Info().Msg(fmt.Sprintf("app height %d is %d blocks behind block store height %d, the missing blocks get replayed during the handshake", appHeight, storeHeight-appHeight, storeHeight))
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
+
+func TestValidateCheckpoint(t *testing.T) {
+	poolId := int64Ptr(1)
+
+	tests := []struct {
+		name        string
+		checkpoint  *Checkpoint
+		storeHeight int64
+		want        *int64
+	}{
+		{
+			name:        "no checkpoint",
+			checkpoint:  nil,
+			storeHeight: 100,
+			want:        nil,
+		},
+		{
+			name:        "checkpoint matches block store",
+			checkpoint:  &Checkpoint{Height: 100, BundleId: int64Ptr(5), PoolId: poolId},
+			storeHeight: 100,
+			want:        int64Ptr(5),
+		},
+		{
+			name:        "block store ahead of checkpoint after a crash",
+			checkpoint:  &Checkpoint{Height: 100, BundleId: int64Ptr(5), PoolId: poolId},
+			storeHeight: 187,
+			want:        int64Ptr(5),
+		},
+		{
+			name:        "block store behind checkpoint",
+			checkpoint:  &Checkpoint{Height: 100, BundleId: int64Ptr(5), PoolId: poolId},
+			storeHeight: 90,
+			want:        nil,
+		},
+		{
+			name:        "checkpoint of another pool",
+			checkpoint:  &Checkpoint{Height: 100, BundleId: int64Ptr(5), PoolId: int64Ptr(2)},
+			storeHeight: 100,
+			want:        nil,
+		},
+		{
+			name:        "checkpoint without bundle",
+			checkpoint:  &Checkpoint{Height: 100, PoolId: poolId},
+			storeHeight: 100,
+			want:        nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ValidateCheckpoint(tt.checkpoint, poolId, "engine", "v1.0.0", tt.storeHeight)
+
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Fatalf("expected bundle id %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSaveAndLoadCheckpoint(t *testing.T) {
+	homePath := t.TempDir()
+
+	checkpoint, err := LoadCheckpoint(homePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint != nil {
+		t.Fatalf("expected no checkpoint, got %v", checkpoint)
+	}
+
+	if err := os.MkdirAll(filepath.Join(homePath, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SaveCheckpoint(homePath, &Checkpoint{Height: 100, BundleId: int64Ptr(5), PoolId: int64Ptr(1), Engine: "engine"}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint, err = LoadCheckpoint(homePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if checkpoint == nil || checkpoint.Height != 100 || *checkpoint.BundleId != 5 || *checkpoint.PoolId != 1 || checkpoint.Engine != "engine" {
+		t.Fatalf("loaded checkpoint does not match saved checkpoint: %v", checkpoint)
+	}
+}
+
+func TestCheckAppHeight(t *testing.T) {
+	for name, c := range map[string]struct {
+		appHeight, storeHeight, baseHeight int64
+		expectedErr                        bool
+	}{
+		"in sync":                   {100, 100, 1, false},
+		"app behind":                {90, 100, 1, false},
+		"app behind pruned blocks":  {40, 100, 50, true},
+		"app one block ahead":       {101, 100, 1, false},
+		"app multiple blocks ahead": {102, 100, 1, true},
+	} {
+		if err := CheckAppHeight(c.appHeight, c.storeHeight, c.baseHeight); (err != nil) != c.expectedErr {
+			t.Errorf("%s: expected error %t, got %v", name, c.expectedErr, err)
+		}
+	}
+}
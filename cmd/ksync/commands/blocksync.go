@@ -7,33 +7,57 @@ import (
 	"github.com/KYVENetwork/ksync/blocksync"
 	"github.com/KYVENetwork/ksync/engines"
 	"github.com/KYVENetwork/ksync/sources"
+	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 	"github.com/spf13/cobra"
-	"strings"
+	"time"
 )
 
 func init() {
 	blockSyncCmd.Flags().StringVarP(&engine, "engine", "e", "", fmt.Sprintf("consensus engine of the binary by default %s is used, list all engines with \"ksync engines\"", utils.DefaultEngine))
 
 	blockSyncCmd.Flags().StringVarP(&binaryPath, "binary", "b", "", "binary path of node to be synced, if not provided the binary has to be started externally with --with-tendermint=false")
+	blockSyncCmd.Flags().StringVar(&abciTransport, "abci-transport", "", fmt.Sprintf("transport of the ABCI connection to the app [\"%s\",\"%s\"], by default detected from the proxy_app address and the abci setting of the config.toml", utils.AbciTransportSocket, utils.AbciTransportGrpc))
+	blockSyncCmd.Flags().BoolVar(&verifyResults, "verify-results", false, "compare the results hash and the app hash of the app with the next block after every block and stop with a divergence report on a mismatch")
+
+	blockSyncCmd.Flags().StringVar(&upgradeBinaries, "upgrade-binaries", "", "directory with the binaries of the chain upgrades, either a cosmovisor home or a directory containing a binary for every recommended version. The binary gets switched automatically at every upgrade height. Only supported by block-sync")
 
 	blockSyncCmd.Flags().StringVarP(&homePath, "home", "h", "", "home directory")
 
 	blockSyncCmd.Flags().StringVarP(&chainId, "chain-id", "c", utils.DefaultChainId, fmt.Sprintf("KYVE chain id [\"%s\",\"%s\",\"%s\"]", utils.ChainIdMainnet, utils.ChainIdKaon, utils.ChainIdKorellia))
 
-	blockSyncCmd.Flags().StringVar(&chainRest, "chain-rest", "", "rest endpoint for KYVE chain")
-	blockSyncCmd.Flags().StringVar(&storageRest, "storage-rest", "", "storage endpoint for requesting bundle data")
+	blockSyncCmd.Flags().StringVar(&chainRest, "chain-rest", "", "rest endpoint for KYVE chain, multiple endpoints can be given comma separated for failover")
+	blockSyncCmd.Flags().StringVar(&storageRest, "storage-rest", "", "storage endpoint for requesting bundle data, supports http(s)://, file:// and s3:// endpoints, multiple endpoints can be given comma separated for failover")
+	blockSyncCmd.Flags().StringVar(&mirrorDir, "mirror-dir", "", "directory of a bundle mirror created with \"ksync mirror\", replaces the KYVE chain and storage endpoints")
+
+	blockSyncCmd.Flags().BoolVar(&bundleCache, "bundle-cache", true, "cache downloaded bundles on disk so they can be reused by following syncs, disable with --bundle-cache=false")
+	blockSyncCmd.Flags().StringVar(&bundleCacheDir, "bundle-cache-dir", "", "directory of the bundle cache (default = ~/.ksync/cache)")
+	blockSyncCmd.Flags().Int64Var(&bundleCacheSize, "bundle-cache-size", utils.DefaultBundleCacheSize, "maximum size of the bundle cache in MB, least recently used bundles get evicted first")
 
 	blockSyncCmd.Flags().StringVarP(&source, "source", "s", "", "chain-id of the source")
 	blockSyncCmd.Flags().StringVar(&registryUrl, "registry-url", utils.DefaultRegistryURL, "URL to fetch latest KYVE Source-Registry")
 
 	blockSyncCmd.Flags().StringVar(&blockPoolId, "block-pool-id", "", "pool-id of the block-sync pool")
 
+	blockSyncCmd.Flags().Int64Var(&prefetchWorkers, "prefetch-workers", utils.DefaultPrefetchWorkers, "number of bundles which are downloaded in parallel ahead of the block execution")
+	blockSyncCmd.Flags().Int64Var(&prefetchMaxMemory, "prefetch-max-memory", utils.DefaultPrefetchMaxMemory, "maximum memory in MB used for holding prefetched compressed bundles")
+
+	blockSyncCmd.Flags().Int64Var(&progressInterval, "progress-interval", utils.DefaultProgressInterval, "interval in seconds in which the sync progress is logged, zero disables the progress logs")
+	blockSyncCmd.Flags().BoolVar(&progressBar, "progress-bar", false, "show a progress bar at the bottom of the terminal")
+
 	blockSyncCmd.Flags().Int64VarP(&targetHeight, "target-height", "t", 0, "target height (including)")
 
 	blockSyncCmd.Flags().BoolVar(&rpcServer, "rpc-server", false, "rpc server serving /status, /block and /block_results")
 	blockSyncCmd.Flags().Int64Var(&rpcServerPort, "rpc-server-port", utils.DefaultRpcServerPort, fmt.Sprintf("port for rpc server"))
 
+	blockSyncCmd.Flags().BoolVar(&controlApi, "control-api", false, "serve an api which reports the status of the sync and allows to pause, resume and change the target height")
+	blockSyncCmd.Flags().Int64Var(&controlApiPort, "control-api-port", utils.DefaultControlApiPort, "port for the control api")
+	blockSyncCmd.Flags().StringVar(&controlApiAddress, "control-api-address", utils.DefaultControlApiAddress, "address the control api listens on, the api is unauthenticated so only expose it to trusted networks")
+
+	blockSyncCmd.Flags().BoolVar(&metricsServer, "metrics", false, "serve prometheus metrics of the sync progress")
+	blockSyncCmd.Flags().Int64Var(&metricsPort, "metrics-port", utils.DefaultMetricsPort, "port for the prometheus metrics server")
+	blockSyncCmd.Flags().StringVar(&metricsAddress, "metrics-address", utils.DefaultMetricsAddress, "address the prometheus metrics server listens on")
+
 	blockSyncCmd.Flags().Int64Var(&backupInterval, "backup-interval", 0, "block interval to write backups of data directory")
 	blockSyncCmd.Flags().Int64Var(&backupKeepRecent, "backup-keep-recent", 3, "number of latest backups to be keep (0 to keep all backups)")
 	blockSyncCmd.Flags().StringVar(&backupCompression, "backup-compression", "", "compression type used for backups (\"tar.gz\",\"zip\")")
@@ -41,6 +65,9 @@ func init() {
 
 	blockSyncCmd.Flags().StringVarP(&appFlags, "app-flags", "f", "", "custom flags which are applied to the app binary start command. Example: --app-flags=\"--x-crisis-skip-assert-invariants,--iavl-disable-fastnode\"")
 
+	blockSyncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the plan of the sync and exit without syncing")
+	blockSyncCmd.Flags().StringVarP(&output, "output", "o", utils.OutputText, fmt.Sprintf("output format of the dry-run plan [\"%s\",\"%s\"]", utils.OutputText, utils.OutputJson))
+
 	blockSyncCmd.Flags().BoolVarP(&reset, "reset-all", "r", false, "reset this node's validator to genesis state")
 	blockSyncCmd.Flags().BoolVar(&optOut, "opt-out", false, "disable the collection of anonymous usage data")
 	blockSyncCmd.Flags().BoolVarP(&debug, "debug", "d", false, "show logs from tendermint app")
@@ -53,15 +80,39 @@ var blockSyncCmd = &cobra.Command{
 	Use:   "block-sync",
 	Short: "Start fast syncing blocks with KSYNC",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		chainRest = utils.GetChainRest(chainId, chainRest)
-		storageRest = strings.TrimSuffix(storageRest, "/")
+		if err := checkDryRunFlags(); err != nil {
+			return err
+		}
+
+		if err := initEndpoints(); err != nil {
+			return err
+		}
+		defer closeMirrorApiServer()
+
+		if err := initBundleCache(); err != nil {
+			return err
+		}
+
+		prefetchCfg := types.PrefetchConfig{
+			Workers:     prefetchWorkers,
+			MaxMemoryMB: prefetchMaxMemory,
+		}
+
+		progressCfg := types.ProgressConfig{
+			Interval: time.Duration(progressInterval) * time.Second,
+			Bar:      progressBar,
+		}
+
+		if upgradeBinaries != "" && mirrorDir != "" {
+			return errors.New("flag 'upgrade-binaries' requires the source registry and can not be used with 'mirror-dir'")
+		}
 
 		// if no binary was provided at least the home path needs to be defined
 		if binaryPath == "" && homePath == "" {
 			return errors.New("flag 'home' is required")
 		}
 
-		if binaryPath == "" {
+		if binaryPath == "" && upgradeBinaries == "" {
 			logger.Info().Msg("to start the syncing process, start your chain binary with --with-tendermint=false")
 		}
 
@@ -70,9 +121,9 @@ var blockSyncCmd = &cobra.Command{
 			logger.Info().Msgf("loaded home path \"%s\" from binary path", homePath)
 		}
 
-		defaultEngine := engines.EngineFactory(engine, homePath, rpcServerPort)
+		defaultEngine := engines.EngineFactory(engine, getEngineConfig())
 
-		if source == "" && blockPoolId == "" {
+		if source == "" && (blockPoolId == "" || upgradeBinaries != "") {
 			s, err := defaultEngine.GetChainId()
 			if err != nil {
 				return fmt.Errorf("failed to load chain-id from engine: %w", err)
@@ -81,12 +132,13 @@ var blockSyncCmd = &cobra.Command{
 			logger.Info().Msgf("loaded source \"%s\" from genesis file", source)
 		}
 
-		if engine == "" && binaryPath != "" {
+		// with upgrade binaries the engine is determined after the active upgrade is known
+		if engine == "" && binaryPath != "" && upgradeBinaries == "" {
 			engine = utils.GetEnginePathFromBinary(binaryPath)
 			logger.Info().Msgf("loaded engine \"%s\" from binary path", engine)
 		}
 
-		bId, _, err := sources.GetPoolIds(chainId, source, blockPoolId, "", registryUrl, true, false)
+		bId, _, err := getPoolIds(true, false)
 		if err != nil {
 			return fmt.Errorf("failed to load pool-ids: %w", err)
 		}
@@ -112,7 +164,8 @@ var blockSyncCmd = &cobra.Command{
 		}
 
 		// perform validation checks before booting state-sync process
-		if err := blocksync.PerformBlockSyncValidationChecks(chainRest, nil, &bId, continuationHeight, targetHeight, true, !y); err != nil {
+		blockSyncPlan, err := blocksync.PerformBlockSyncValidationChecks(chainRestGroup, nil, &bId, continuationHeight, targetHeight, true, !y && !dryRun)
+		if err != nil {
 			return fmt.Errorf("block-sync validation checks failed: %w", err)
 		}
 
@@ -120,15 +173,60 @@ var blockSyncCmd = &cobra.Command{
 			return fmt.Errorf("failed to close dbs in engine: %w", err)
 		}
 
-		if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, source, continuationHeight, !y); err != nil {
-			return fmt.Errorf("failed to check if binary has the recommended version: %w", err)
+		// the engine is resolved before the plan is printed, so the plan reports the engine which is used for
+		// the sync. With upgrade binaries this is the engine of the upgrade which is active at the continuation height
+		var consensusEngine types.Engine
+		var upgrades []types.BinaryUpgrade
+
+		if upgradeBinaries != "" {
+			upgrades, err = sources.GetBinaryUpgrades(registryUrl, source, upgradeBinaries, binaryPath, continuationHeight, targetHeight)
+			if err != nil {
+				return fmt.Errorf("failed to get binaries of upgrades: %w", err)
+			}
+
+			binaryPath = upgrades[0].BinaryPath
+			logger.Info().Msgf("using binary \"%s\" of upgrade \"%s\"", binaryPath, upgrades[0].Name)
+
+			if engine == "" {
+				engine = upgrades[0].Engine
+			}
+			if engine == "" {
+				engine = utils.GetEnginePathFromBinary(binaryPath)
+				logger.Info().Msgf("loaded engine \"%s\" from binary path", engine)
+			}
+
+			consensusEngine = engines.EngineFactory(engine, getEngineConfig())
+		} else {
+			consensusEngine, err = engines.EngineSourceFactory(engine, registryUrl, registrySource(), continuationHeight, getEngineConfig())
+			if err != nil {
+				return fmt.Errorf("failed to create consensus engine for source: %w", err)
+			}
 		}
 
-		consensusEngine, err := engines.EngineSourceFactory(engine, homePath, registryUrl, source, rpcServerPort, continuationHeight)
-		if err != nil {
-			return fmt.Errorf("failed to create consensus engine for source: %w", err)
+		if dryRun {
+			return printPlan(types.SyncPlan{
+				SyncType:     "block-sync",
+				ChainId:      chainId,
+				Source:       source,
+				Engine:       consensusEngine.GetName(),
+				BinaryPath:   binaryPath,
+				HomePath:     homePath,
+				BlockPoolId:  &bId,
+				Height:       continuationHeight - 1,
+				TargetHeight: targetHeight,
+				BlockSync:    blockSyncPlan,
+			})
+		}
+
+		startMetricsServer()
+		startControlApiServer()
+
+		if upgradeBinaries == "" {
+			if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, registrySource(), continuationHeight, !y); err != nil {
+				return fmt.Errorf("failed to check if binary has the recommended version: %w", err)
+			}
 		}
 
-		return blocksync.StartBlockSyncWithBinary(consensusEngine, binaryPath, homePath, chainId, chainRest, storageRest, nil, &bId, targetHeight, backupCfg, appFlags, rpcServer, optOut, debug)
+		return blocksync.StartBlockSyncWithBinary(cmd.Context(), consensusEngine, binaryPath, homePath, chainId, chainRestGroup, storageRest, nil, &bId, &prefetchCfg, &progressCfg, targetHeight, backupCfg, upgrades, appFlags, rpcServer, optOut, debug)
 	},
 }
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadConfig sets all flags of the command which were not given on the command line from the
+// KSYNC_* environment variables or the config file. The config file can contain flags for all
+// commands on the top level, flags for a single command in a section with the name of the command
+// and profiles which overwrite both, for example:
+//
+//	binary = "/root/go/bin/kyved"
+//
+//	[block-sync]
+//	target-height = 1000000
+//
+//	[profiles.kaon-1]
+//	chain-id = "kaon-1"
+//
+//	[profiles.kaon-1.block-sync]
+//	target-height = 2000000
+//
+// A flag given on the command line has the highest priority, followed by the environment
+// variable, the command section of the profile, the profile, the command section and the top level
+func loadConfig(cmd *cobra.Command) (string, error) {
+	env := viper.New()
+	env.SetEnvPrefix("KSYNC")
+	env.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	env.AutomaticEnv()
+
+	if !cmd.Flags().Changed("config") && env.IsSet("config") {
+		configFile = env.GetString("config")
+	}
+
+	if !cmd.Flags().Changed("profile") && env.IsSet("profile") {
+		profile = env.GetString("profile")
+	}
+
+	config := viper.New()
+
+	if configFile != "" {
+		config.SetConfigFile(configFile)
+	} else {
+		// ksync.toml, ksync.yaml and ksync.json are looked up in the working directory and in ~/.ksync
+		config.SetConfigName("ksync")
+		config.AddConfigPath(".")
+		if home, err := os.UserHomeDir(); err == nil {
+			config.AddConfigPath(filepath.Join(home, ".ksync"))
+		}
+	}
+
+	if err := config.ReadInConfig(); err != nil {
+		if !errors.As(err, &viper.ConfigFileNotFoundError{}) {
+			return "", fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		// without a config file only the environment variables are applied
+		config = viper.New()
+	}
+
+	if profile != "" && !config.IsSet(fmt.Sprintf("profiles.%s", profile)) {
+		return "", fmt.Errorf("profile %s not found in config file", profile)
+	}
+
+	if err := checkConfigSection(cmd, config, cmd.Name()); err != nil {
+		return "", err
+	}
+
+	if profile != "" {
+		if err := checkConfigSection(cmd, config, fmt.Sprintf("profiles.%s.%s", profile, cmd.Name())); err != nil {
+			return "", err
+		}
+	}
+
+	var flagErr error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flagErr != nil || flag.Changed || flag.Name == "help" || flag.Name == "config" || flag.Name == "profile" {
+			return
+		}
+
+		keys := []string{fmt.Sprintf("%s.%s", cmd.Name(), flag.Name), flag.Name}
+		if profile != "" {
+			keys = append([]string{
+				fmt.Sprintf("profiles.%s.%s.%s", profile, cmd.Name(), flag.Name),
+				fmt.Sprintf("profiles.%s.%s", profile, flag.Name),
+			}, keys...)
+		}
+
+		value, found := env.Get(flag.Name), env.IsSet(flag.Name)
+
+		for _, key := range keys {
+			if found {
+				break
+			}
+
+			value, found = config.Get(key), config.IsSet(key)
+		}
+
+		if !found {
+			return
+		}
+
+		if err := cmd.Flags().Set(flag.Name, configValueToString(value)); err != nil {
+			flagErr = fmt.Errorf("invalid value for flag --%s in config: %w", flag.Name, err)
+		}
+	})
+
+	return config.ConfigFileUsed(), flagErr
+}
+
+// checkConfigSection returns an error if the section of the command contains keys which
+// are no flags of the command, this way typos in the config file do not get ignored
+func checkConfigSection(cmd *cobra.Command, config *viper.Viper, section string) error {
+	for key := range config.GetStringMap(section) {
+		if cmd.Flags().Lookup(key) == nil {
+			return fmt.Errorf("unknown flag %s in section %s of config file", key, section)
+		}
+	}
+
+	return nil
+}
+
+// configValueToString converts a value of the config file to the string representation of the flag
+func configValueToString(value interface{}) string {
+	if values, ok := value.([]interface{}); ok {
+		parts := make([]string, 0, len(values))
+		for _, v := range values {
+			parts = append(parts, fmt.Sprint(v))
+		}
+		return strings.Join(parts, ",")
+	}
+
+	return fmt.Sprint(value)
+}
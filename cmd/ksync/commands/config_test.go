@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `
+binary = "top"
+chain-id = "top"
+home = "top"
+source = "top"
+target-height = 1
+
+[block-sync]
+chain-id = "section"
+target-height = 2
+
+[profiles.kaon-1]
+chain-id = "profile"
+target-height = 3
+
+[profiles.kaon-1.block-sync]
+target-height = 4
+`
+
+type testFlags struct {
+	binary       string
+	chainId      string
+	home         string
+	source       string
+	targetHeight int64
+}
+
+// newConfigTestCmd writes the config file and returns a block-sync command with a subset of its flags
+func newConfigTestCmd(t *testing.T, config, configProfile string) (*cobra.Command, *testFlags) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ksync.toml")
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %s", err)
+	}
+
+	prevConfigFile, prevProfile := configFile, profile
+	t.Cleanup(func() {
+		configFile, profile = prevConfigFile, prevProfile
+	})
+	configFile, profile = path, configProfile
+
+	flags := &testFlags{}
+
+	cmd := &cobra.Command{Use: "block-sync"}
+	cmd.Flags().StringVar(&flags.binary, "binary", "", "")
+	cmd.Flags().StringVar(&flags.chainId, "chain-id", "", "")
+	cmd.Flags().StringVar(&flags.home, "home", "", "")
+	cmd.Flags().StringVar(&flags.source, "source", "", "")
+	cmd.Flags().Int64Var(&flags.targetHeight, "target-height", 0, "")
+
+	return cmd, flags
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	cmd, flags := newConfigTestCmd(t, testConfig, "kaon-1")
+
+	if err := cmd.Flags().Set("binary", "cli"); err != nil {
+		t.Fatalf("failed to set flag: %s", err)
+	}
+	t.Setenv("KSYNC_HOME", "env")
+
+	if _, err := loadConfig(cmd); err != nil {
+		t.Fatalf("failed to load config: %s", err)
+	}
+
+	if flags.binary != "cli" {
+		t.Errorf("expected the command line to win over the config file, got %s", flags.binary)
+	}
+
+	if flags.home != "env" {
+		t.Errorf("expected the environment variable to win over the config file, got %s", flags.home)
+	}
+
+	if flags.targetHeight != 4 {
+		t.Errorf("expected the command section of the profile to win, got %d", flags.targetHeight)
+	}
+
+	if flags.chainId != "profile" {
+		t.Errorf("expected the profile to win over the command section, got %s", flags.chainId)
+	}
+
+	if flags.source != "top" {
+		t.Errorf("expected the top level to be applied, got %s", flags.source)
+	}
+}
+
+func TestLoadConfigWithoutProfile(t *testing.T) {
+	cmd, flags := newConfigTestCmd(t, testConfig, "")
+
+	if _, err := loadConfig(cmd); err != nil {
+		t.Fatalf("failed to load config: %s", err)
+	}
+
+	if flags.targetHeight != 2 || flags.chainId != "section" {
+		t.Errorf("expected the command section to win over the top level, got %d and %s", flags.targetHeight, flags.chainId)
+	}
+
+	if flags.binary != "top" {
+		t.Errorf("expected the top level to be applied, got %s", flags.binary)
+	}
+}
+
+func TestLoadConfigRejectsUnknownKeys(t *testing.T) {
+	cmd, _ := newConfigTestCmd(t, "[block-sync]\ntarget-hieght = 1\n", "")
+
+	if _, err := loadConfig(cmd); err == nil {
+		t.Errorf("expected unknown flag in command section to be rejected")
+	}
+}
+
+func TestLoadConfigRejectsUnknownProfile(t *testing.T) {
+	cmd, _ := newConfigTestCmd(t, testConfig, "mainnet")
+
+	if _, err := loadConfig(cmd); err == nil {
+		t.Errorf("expected unknown profile to be rejected")
+	}
+}
@@ -20,6 +20,7 @@ var enginesCmd = &cobra.Command{
 		fmt.Printf("%s - %s\n", utils.EngineTendermintV34, "github.com/tendermint/tendermint v0.34.x")
 		fmt.Printf("%s - %s\n", utils.EngineCometBFTV37, "github.com/cometbft/cometbft v0.37.x")
 		fmt.Printf("%s - %s\n", utils.EngineCometBFTV38, "github.com/cometbft/cometbft v0.38.x")
+		fmt.Printf("%s - %s\n", utils.EngineCometBFTV1, "github.com/cometbft/cometbft v1.x")
 		fmt.Printf("%s - %s\n", utils.EngineCelestiaCoreV34, "github.com/celestiaorg/celestia-core v0.34.x-celestia")
 		return nil
 	},
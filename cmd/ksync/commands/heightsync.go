@@ -8,22 +8,29 @@ import (
 	"github.com/KYVENetwork/ksync/engines"
 	"github.com/KYVENetwork/ksync/heightsync"
 	"github.com/KYVENetwork/ksync/sources"
+	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 	"github.com/spf13/cobra"
-	"strings"
 )
 
 func init() {
 	heightSyncCmd.Flags().StringVarP(&engine, "engine", "e", "", fmt.Sprintf("consensus engine of the binary by default %s is used, list all engines with \"ksync engines\"", utils.DefaultEngine))
 
 	heightSyncCmd.Flags().StringVarP(&binaryPath, "binary", "b", "", "binary path of node to be synced, if not provided the binary has to be started externally with --with-tendermint=false")
+	heightSyncCmd.Flags().StringVar(&abciTransport, "abci-transport", "", fmt.Sprintf("transport of the ABCI connection to the app [\"%s\",\"%s\"], by default detected from the proxy_app address and the abci setting of the config.toml", utils.AbciTransportSocket, utils.AbciTransportGrpc))
+	heightSyncCmd.Flags().BoolVar(&verifyResults, "verify-results", false, "compare the results hash and the app hash of the app with the next block after every block and stop with a divergence report on a mismatch")
 
 	heightSyncCmd.Flags().StringVarP(&homePath, "home", "h", "", "home directory")
 
 	heightSyncCmd.Flags().StringVarP(&chainId, "chain-id", "c", utils.DefaultChainId, fmt.Sprintf("KYVE chain id [\"%s\",\"%s\",\"%s\"]", utils.ChainIdMainnet, utils.ChainIdKaon, utils.ChainIdKorellia))
 
-	heightSyncCmd.Flags().StringVar(&chainRest, "chain-rest", "", "rest endpoint for KYVE chain")
-	heightSyncCmd.Flags().StringVar(&storageRest, "storage-rest", "", "storage endpoint for requesting bundle data")
+	heightSyncCmd.Flags().StringVar(&chainRest, "chain-rest", "", "rest endpoint for KYVE chain, multiple endpoints can be given comma separated for failover")
+	heightSyncCmd.Flags().StringVar(&storageRest, "storage-rest", "", "storage endpoint for requesting bundle data, supports http(s)://, file:// and s3:// endpoints, multiple endpoints can be given comma separated for failover")
+	heightSyncCmd.Flags().StringVar(&mirrorDir, "mirror-dir", "", "directory of a bundle mirror created with \"ksync mirror\", replaces the KYVE chain and storage endpoints")
+
+	heightSyncCmd.Flags().BoolVar(&bundleCache, "bundle-cache", true, "cache downloaded bundles on disk so they can be reused by following syncs, disable with --bundle-cache=false")
+	heightSyncCmd.Flags().StringVar(&bundleCacheDir, "bundle-cache-dir", "", "directory of the bundle cache (default = ~/.ksync/cache)")
+	heightSyncCmd.Flags().Int64Var(&bundleCacheSize, "bundle-cache-size", utils.DefaultBundleCacheSize, "maximum size of the bundle cache in MB, least recently used bundles get evicted first")
 
 	heightSyncCmd.Flags().StringVarP(&source, "source", "s", "", "chain-id of the source")
 	heightSyncCmd.Flags().StringVar(&registryUrl, "registry-url", utils.DefaultRegistryURL, "URL to fetch latest KYVE Source-Registry")
@@ -31,10 +38,24 @@ func init() {
 	heightSyncCmd.Flags().StringVar(&snapshotPoolId, "snapshot-pool-id", "", "pool-id of the state-sync pool")
 	heightSyncCmd.Flags().StringVar(&blockPoolId, "block-pool-id", "", "pool-id of the block-sync pool")
 
+	heightSyncCmd.Flags().Int64Var(&prefetchWorkers, "prefetch-workers", utils.DefaultPrefetchWorkers, "number of bundles which are downloaded in parallel ahead of the block execution")
+	heightSyncCmd.Flags().Int64Var(&prefetchMaxMemory, "prefetch-max-memory", utils.DefaultPrefetchMaxMemory, "maximum memory in MB used for holding prefetched compressed bundles")
+
+	heightSyncCmd.Flags().BoolVar(&controlApi, "control-api", false, "serve an api which reports the status of the sync and allows to pause, resume and change the target height")
+	heightSyncCmd.Flags().Int64Var(&controlApiPort, "control-api-port", utils.DefaultControlApiPort, "port for the control api")
+	heightSyncCmd.Flags().StringVar(&controlApiAddress, "control-api-address", utils.DefaultControlApiAddress, "address the control api listens on, the api is unauthenticated so only expose it to trusted networks")
+
+	heightSyncCmd.Flags().BoolVar(&metricsServer, "metrics", false, "serve prometheus metrics of the sync progress")
+	heightSyncCmd.Flags().Int64Var(&metricsPort, "metrics-port", utils.DefaultMetricsPort, "port for the prometheus metrics server")
+	heightSyncCmd.Flags().StringVar(&metricsAddress, "metrics-address", utils.DefaultMetricsAddress, "address the prometheus metrics server listens on")
+
 	heightSyncCmd.Flags().StringVarP(&appFlags, "app-flags", "f", "", "custom flags which are applied to the app binary start command. Example: --app-flags=\"--x-crisis-skip-assert-invariants,--iavl-disable-fastnode\"")
 
 	heightSyncCmd.Flags().Int64VarP(&targetHeight, "target-height", "t", 0, "target height (including), if not specified it will sync to the latest available block height")
 
+	heightSyncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the plan of the sync and exit without syncing")
+	heightSyncCmd.Flags().StringVarP(&output, "output", "o", utils.OutputText, fmt.Sprintf("output format of the dry-run plan [\"%s\",\"%s\"]", utils.OutputText, utils.OutputJson))
+
 	heightSyncCmd.Flags().BoolVarP(&reset, "reset-all", "r", false, "reset this node's validator to genesis state")
 	heightSyncCmd.Flags().BoolVar(&optOut, "opt-out", false, "disable the collection of anonymous usage data")
 	heightSyncCmd.Flags().BoolVarP(&debug, "debug", "d", false, "show logs from tendermint app")
@@ -47,8 +68,23 @@ var heightSyncCmd = &cobra.Command{
 	Use:   "height-sync",
 	Short: "Sync fast to any height with state- and block-sync",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		chainRest = utils.GetChainRest(chainId, chainRest)
-		storageRest = strings.TrimSuffix(storageRest, "/")
+		if err := checkDryRunFlags(); err != nil {
+			return err
+		}
+
+		if err := initEndpoints(); err != nil {
+			return err
+		}
+		defer closeMirrorApiServer()
+
+		if err := initBundleCache(); err != nil {
+			return err
+		}
+
+		prefetchCfg := types.PrefetchConfig{
+			Workers:     prefetchWorkers,
+			MaxMemoryMB: prefetchMaxMemory,
+		}
 
 		// if no binary was provided at least the home path needs to be defined
 		if binaryPath == "" && homePath == "" {
@@ -69,7 +105,7 @@ var heightSyncCmd = &cobra.Command{
 			logger.Info().Msgf("Loaded engine \"%s\" from binary path", engine)
 		}
 
-		defaultEngine := engines.EngineFactory(engine, homePath, rpcServerPort)
+		defaultEngine := engines.EngineFactory(engine, getEngineConfig())
 
 		if source == "" && blockPoolId == "" && snapshotPoolId == "" {
 			s, err := defaultEngine.GetChainId()
@@ -80,7 +116,7 @@ var heightSyncCmd = &cobra.Command{
 			logger.Info().Msgf("Loaded source \"%s\" from genesis file", source)
 		}
 
-		bId, sId, err := sources.GetPoolIds(chainId, source, blockPoolId, snapshotPoolId, registryUrl, true, true)
+		bId, sId, err := getPoolIds(true, true)
 		if err != nil {
 			return fmt.Errorf("failed to load pool-ids: %w", err)
 		}
@@ -95,7 +131,7 @@ var heightSyncCmd = &cobra.Command{
 			return fmt.Errorf("failed to open dbs in engine: %w", err)
 		}
 
-		_, _, blockEndHeight, err := blocksyncHelpers.GetBlockBoundaries(chainRest, nil, &bId)
+		_, _, blockEndHeight, err := blocksyncHelpers.GetBlockBoundaries(chainRestGroup, nil, &bId)
 		if err != nil {
 			return fmt.Errorf("failed to get block boundaries: %w", err)
 		}
@@ -107,11 +143,16 @@ var heightSyncCmd = &cobra.Command{
 		}
 
 		// perform validation checks before booting state-sync process
-		snapshotBundleId, snapshotHeight, err := heightsync.PerformHeightSyncValidationChecks(defaultEngine, chainRest, sId, &bId, targetHeight, !y)
+		stateSyncPlan, blockSyncPlan, err := heightsync.PerformHeightSyncValidationChecks(defaultEngine, chainRestGroup, sId, &bId, targetHeight, !y && !dryRun)
 		if err != nil {
 			return fmt.Errorf("height-sync validation checks failed: %w", err)
 		}
 
+		snapshotBundleId, snapshotHeight := int64(0), int64(0)
+		if stateSyncPlan != nil {
+			snapshotBundleId, snapshotHeight = stateSyncPlan.SnapshotBundleId, stateSyncPlan.SnapshotHeight
+		}
+
 		continuationHeight := snapshotHeight
 		if continuationHeight == 0 {
 			c, err := defaultEngine.GetContinuationHeight()
@@ -121,23 +162,46 @@ var heightSyncCmd = &cobra.Command{
 			continuationHeight = c
 		}
 
-		if err := blocksync.PerformBlockSyncValidationChecks(chainRest, nil, &bId, continuationHeight, targetHeight, true, false); err != nil {
+		if _, err := blocksync.PerformBlockSyncValidationChecks(chainRestGroup, nil, &bId, continuationHeight, targetHeight, true, false); err != nil {
 			return fmt.Errorf("block-sync validation checks failed: %w", err)
 		}
 
+		height := defaultEngine.GetHeight()
+
 		if err := defaultEngine.CloseDBs(); err != nil {
 			return fmt.Errorf("failed to close dbs in engine: %w", err)
 		}
 
-		if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, source, continuationHeight, !y); err != nil {
-			return fmt.Errorf("failed to check if binary has the recommended version: %w", err)
-		}
-
-		consensusEngine, err := engines.EngineSourceFactory(engine, homePath, registryUrl, source, rpcServerPort, continuationHeight)
+		// the engine is resolved before the plan is printed, so the plan reports the engine which is used for the sync
+		consensusEngine, err := engines.EngineSourceFactory(engine, registryUrl, registrySource(), continuationHeight, getEngineConfig())
 		if err != nil {
 			return fmt.Errorf("failed to create consensus engine for source: %w", err)
 		}
 
-		return heightsync.StartHeightSyncWithBinary(consensusEngine, binaryPath, homePath, chainId, chainRest, storageRest, sId, &bId, targetHeight, snapshotBundleId, snapshotHeight, appFlags, optOut, debug)
+		if dryRun {
+			return printPlan(types.SyncPlan{
+				SyncType:       "height-sync",
+				ChainId:        chainId,
+				Source:         source,
+				Engine:         consensusEngine.GetName(),
+				BinaryPath:     binaryPath,
+				HomePath:       homePath,
+				BlockPoolId:    &bId,
+				SnapshotPoolId: &sId,
+				Height:         height,
+				TargetHeight:   targetHeight,
+				StateSync:      stateSyncPlan,
+				BlockSync:      blockSyncPlan,
+			})
+		}
+
+		startMetricsServer()
+		startControlApiServer()
+
+		if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, registrySource(), continuationHeight, !y); err != nil {
+			return fmt.Errorf("failed to check if binary has the recommended version: %w", err)
+		}
+
+		return heightsync.StartHeightSyncWithBinary(cmd.Context(), consensusEngine, binaryPath, homePath, chainId, chainRestGroup, storageRest, sId, &bId, &prefetchCfg, targetHeight, snapshotBundleId, snapshotHeight, appFlags, optOut, debug)
 	},
 }
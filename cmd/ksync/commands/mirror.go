@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/collectors/bundles"
+	"github.com/KYVENetwork/ksync/mirror"
+	"github.com/KYVENetwork/ksync/sources"
+	"github.com/KYVENetwork/ksync/utils"
+	"github.com/spf13/cobra"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	mirrorCmd.Flags().StringVar(&mirrorDir, "mirror-dir", "", "directory where the bundles and the bundle index are stored")
+	if err := mirrorCmd.MarkFlagRequired("mirror-dir"); err != nil {
+		panic(fmt.Errorf("flag 'mirror-dir' should be required: %w", err))
+	}
+
+	mirrorCmd.Flags().StringVarP(&chainId, "chain-id", "c", utils.DefaultChainId, fmt.Sprintf("KYVE chain id [\"%s\",\"%s\",\"%s\"]", utils.ChainIdMainnet, utils.ChainIdKaon, utils.ChainIdKorellia))
+
+	mirrorCmd.Flags().StringVar(&chainRest, "chain-rest", "", "rest endpoint for KYVE chain, multiple endpoints can be given comma separated for failover")
+	mirrorCmd.Flags().StringVar(&storageRest, "storage-rest", "", "storage endpoint for requesting bundle data, supports http(s)://, file:// and s3:// endpoints, multiple endpoints can be given comma separated for failover")
+
+	mirrorCmd.Flags().StringVarP(&source, "source", "s", "", "chain-id of the source")
+	mirrorCmd.Flags().StringVar(&registryUrl, "registry-url", utils.DefaultRegistryURL, "URL to fetch latest KYVE Source-Registry")
+
+	mirrorCmd.Flags().StringVar(&blockPoolId, "block-pool-id", "", "pool-id of the block-sync pool")
+	mirrorCmd.Flags().StringVar(&snapshotPoolId, "snapshot-pool-id", "", "pool-id of the state-sync pool, if specified the state-sync pool gets mirrored instead of the block-sync pool")
+
+	mirrorCmd.Flags().Int64Var(&startHeight, "start-height", 0, "first height which should be mirrored")
+	mirrorCmd.Flags().Int64VarP(&targetHeight, "target-height", "t", 0, "last height which should be mirrored, if not specified all bundles up to the latest height are mirrored")
+
+	RootCmd.AddCommand(mirrorCmd)
+}
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror",
+	Short: "Download bundles of a pool into a local directory for offline syncing",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		chainRestGroup = utils.GetChainRest(chainId, chainRest)
+		storageRest = strings.TrimSuffix(storageRest, "/")
+		bundles.InitBundleIndex(chainId)
+
+		if targetHeight > 0 && startHeight > targetHeight {
+			return fmt.Errorf("start height %d is bigger than target height %d", startHeight, targetHeight)
+		}
+
+		// only one pool is mirrored at a time, the state-sync pool if explicitly
+		// requested and the block-sync pool otherwise
+		if snapshotPoolId != "" {
+			sId, err := strconv.ParseInt(snapshotPoolId, 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse snapshot pool id %s: %w", snapshotPoolId, err)
+			}
+
+			return mirror.StartMirror(cmd.Context(), chainId, chainRestGroup, storageRest, sId, startHeight, targetHeight, mirrorDir)
+		}
+
+		bId, _, err := sources.GetPoolIds(chainId, source, blockPoolId, "", registryUrl, true, false)
+		if err != nil {
+			return fmt.Errorf("failed to load pool-ids: %w", err)
+		}
+
+		return mirror.StartMirror(cmd.Context(), chainId, chainRestGroup, storageRest, bId, startHeight, targetHeight, mirrorDir)
+	},
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+)
+
+// checkDryRunFlags returns an error if the dry-run flags can not be applied
+func checkDryRunFlags() error {
+	if output != utils.OutputText && output != utils.OutputJson {
+		return fmt.Errorf("output format %s not supported, use \"%s\" or \"%s\"", output, utils.OutputText, utils.OutputJson)
+	}
+
+	// the plan is based on the current state of the node which would be lost with a reset
+	if dryRun && reset {
+		return fmt.Errorf("--dry-run can not be combined with --reset-all")
+	}
+
+	return nil
+}
+
+// printPlan prints the plan of the sync in the output format
+func printPlan(plan types.SyncPlan) error {
+	if output == utils.OutputJson {
+		out, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal plan: %w", err)
+		}
+
+		fmt.Println(string(out))
+		return nil
+	}
+
+	logger.Info().Msg(fmt.Sprintf("dry-run plan of %s for chain %s with engine %s and binary %s, node is at height %d", plan.SyncType, plan.ChainId, plan.Engine, plan.BinaryPath, plan.Height))
+
+	if plan.StateSync != nil {
+		logger.Info().Msg(fmt.Sprintf("apply snapshot at height %d from bundle %d of snapshot pool %d, available snapshots are from height %d to %d", plan.StateSync.SnapshotHeight, plan.StateSync.SnapshotBundleId, *plan.SnapshotPoolId, plan.StateSync.PoolStartHeight, plan.StateSync.PoolEndHeight))
+	}
+
+	if plan.BlockSync != nil {
+		from := "block rpc"
+		if plan.BlockPoolId != nil {
+			from = fmt.Sprintf("block pool %d", *plan.BlockPoolId)
+		}
+
+		logger.Info().Msg(fmt.Sprintf("sync %d blocks from height %d to %d from %s, available blocks are from height %d to %d", plan.BlockSync.Blocks, plan.BlockSync.StartHeight, plan.BlockSync.EndHeight, from, plan.BlockSync.PoolStartHeight, plan.BlockSync.PoolEndHeight))
+	}
+
+	return nil
+}
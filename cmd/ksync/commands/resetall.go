@@ -28,7 +28,7 @@ var resetCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		utils.TrackResetEvent(optOut)
 
-		if err := engines.EngineFactory(engine, homePath, rpcServerPort).ResetAll(keepAddrBook); err != nil {
+		if err := engines.EngineFactory(engine, getEngineConfig()).ResetAll(keepAddrBook); err != nil {
 			return fmt.Errorf("failed to reset tendermint application: %w", err)
 		}
 
@@ -1,27 +1,59 @@
 package commands
 
 import (
+	"context"
+	"fmt"
+	"github.com/KYVENetwork/ksync/collectors/bundles"
+	"github.com/KYVENetwork/ksync/metrics"
+	"github.com/KYVENetwork/ksync/server"
+	"github.com/KYVENetwork/ksync/sources"
+	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
 var (
 	engine               string
 	binaryPath           string
+	abciTransport        string
+	verifyResults        bool
+	upgradeBinaries      string
 	homePath             string
 	chainId              string
 	chainRest            string
+	chainRestGroup       *utils.EndpointGroup
 	storageRest          string
 	blockRpc             string
 	snapshotPoolId       string
 	blockPoolId          string
 	startHeight          int64
+	prefetchWorkers      int64
+	prefetchMaxMemory    int64
+	bundleCache          bool
+	bundleCacheDir       string
+	bundleCacheSize      int64
+	mirrorDir            string
+	mirrorApiServer      *server.MirrorApiServer
 	targetHeight         int64
 	rpcServer            bool
 	rpcServerPort        int64
 	snapshotPort         int64
+	progressInterval     int64
+	progressBar          bool
+	controlApi           bool
+	controlApiPort       int64
+	controlApiAddress    string
+	metricsServer        bool
+	metricsPort          int64
+	metricsAddress       string
 	blockRpcReqTimeout   int64
+	blockRpcConcurrency  int64
+	blockRpcBatchSize    int64
+	blockRpcRateLimit    int64
 	source               string
 	pruning              bool
 	keepSnapshots        bool
@@ -37,6 +69,13 @@ var (
 	optOut               bool
 	debug                bool
 	y                    bool
+	logFormat            string
+	logLevel             string
+	logFile              string
+	configFile           string
+	dryRun               bool
+	output               string
+	profile              string
 )
 
 var (
@@ -47,12 +86,33 @@ var (
 var RootCmd = &cobra.Command{
 	Use:   "ksync",
 	Short: "Fast Sync validated and archived blocks from KYVE to every Tendermint based Blockchain Application",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		configFileUsed, err := loadConfig(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		// stdout only contains the json output, so the logs are written to stderr
+		if err := utils.ConfigureLogger(logFormat, logLevel, logFile, output == utils.OutputJson); err != nil {
+			return err
+		}
+
+		if configFileUsed != "" {
+			logger.Info().Msg(fmt.Sprintf("loaded config file %s", configFileUsed))
+		}
+
+		// the flags are valid at this point, so errors of the command itself do not print the usage
+		cmd.SilenceUsage = true
+
+		return nil
+	},
 }
 
 func Execute() {
 	backupCmd.Flags().SortFlags = false
 	blockSyncCmd.Flags().SortFlags = false
 	heightSyncCmd.Flags().SortFlags = false
+	mirrorCmd.Flags().SortFlags = false
 	pruneCmd.Flags().SortFlags = false
 	resetCmd.Flags().SortFlags = false
 	servesnapshotsCmd.Flags().SortFlags = false
@@ -63,7 +123,150 @@ func Execute() {
 	// overwrite help command so we can use -h as a shortcut
 	RootCmd.PersistentFlags().BoolP("help", "", false, "help for this command")
 
-	if err := RootCmd.Execute(); err != nil {
+	RootCmd.PersistentFlags().StringVar(&logFormat, "log-format", utils.LogFormatConsole, fmt.Sprintf("format of the log output [\"%s\",\"%s\"]", utils.LogFormatConsole, utils.LogFormatJson))
+	RootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "minimum level of the log output [\"debug\",\"info\",\"warn\",\"error\"]")
+	RootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "file the log output is additionally appended to")
+
+	RootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file with the flags of the commands, by default ksync.toml, ksync.yaml or ksync.json is looked up in the working directory and in ~/.ksync")
+	RootCmd.PersistentFlags().StringVar(&profile, "profile", "", "profile of the config file which is applied, for example the chain which is synced")
+
+	// commands get canceled on SIGINT and SIGTERM so they can shut down gracefully,
+	// a second signal terminates KSYNC immediately
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		logger.Info().Msg("received shutdown signal, stopping gracefully. Send signal again to force exit")
+		cancel()
+
+		<-signals
+		logger.Error().Msg("received second shutdown signal, exiting immediately")
+
+		// the binary runs in its own process group and would keep running without KSYNC
+		utils.KillRunningProcesses()
 		os.Exit(1)
+	}()
+
+	if err := RootCmd.ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
+
+// initEndpoints resolves the KYVE chain and storage endpoints. If a bundle mirror is given it
+// replaces both endpoints, the chain id is taken from the mirror and since a mirror sync is
+// air-gapped the collection of usage data is disabled
+func initEndpoints() error {
+	chainRestGroup = utils.GetChainRest(chainId, chainRest)
+	storageRest = strings.TrimSuffix(storageRest, "/")
+
+	if mirrorDir != "" {
+		apiServer, mirrorEndpoint, err := server.StartMirrorApiServer(mirrorDir)
+		if err != nil {
+			return fmt.Errorf("failed to start mirror api server: %w", err)
+		}
+
+		mirrorApiServer = apiServer
+		chainRestGroup = utils.NewEndpointGroup([]string{mirrorEndpoint})
+		storageRest = fmt.Sprintf("%s/storage", mirrorEndpoint)
+		logger.Info().Msgf("serving bundle mirror \"%s\" on %s", mirrorDir, mirrorEndpoint)
+
+		if apiServer.ChainId() != "" {
+			chainId = apiServer.ChainId()
+		}
+
+		optOut = true
+	}
+
+	bundles.InitBundleIndex(chainId)
+	return nil
+}
+
+// closeMirrorApiServer stops the bundle mirror api server if one was started
+func closeMirrorApiServer() {
+	if mirrorApiServer == nil {
+		return
+	}
+
+	if err := mirrorApiServer.Close(); err != nil {
+		logger.Error().Msg(fmt.Sprintf("failed to close mirror api server: %s", err))
+	}
+}
+
+// initBundleCache enables the bundle cache unless it was disabled. Bundles of a local
+// mirror are not cached since they are already stored on disk and a dry-run downloads
+// no bundles, so the cache directory is not created for it
+func initBundleCache() error {
+	if !bundleCache || mirrorDir != "" || dryRun {
+		return nil
+	}
+
+	if err := bundles.InitBundleCache(bundleCacheDir, bundleCacheSize); err != nil {
+		return fmt.Errorf("failed to init bundle cache: %w", err)
+	}
+
+	return nil
+}
+
+// getPoolIds loads the pool ids from the bundle mirror if one is used and from the
+// flags or the source registry otherwise
+func getPoolIds(blockPoolRequired, snapshotPoolRequired bool) (int64, int64, error) {
+	if mirrorApiServer != nil {
+		return mirrorApiServer.GetPoolIds(blockPoolId, snapshotPoolId, blockPoolRequired, snapshotPoolRequired)
+	}
+
+	return sources.GetPoolIds(chainId, source, blockPoolId, snapshotPoolId, registryUrl, blockPoolRequired, snapshotPoolRequired)
+}
+
+// getEngineConfig returns the settings of the consensus engine from the flags
+func getEngineConfig() types.EngineConfig {
+	return types.EngineConfig{
+		HomePath:      homePath,
+		RpcServerPort: rpcServerPort,
+		AbciTransport: abciTransport,
+		VerifyResults: verifyResults,
+	}
+}
+
+// registrySource returns the source for lookups in the source registry, since a mirror
+// sync is air-gapped no source is returned if a bundle mirror is used
+func registrySource() string {
+	if mirrorDir != "" {
+		return ""
 	}
+
+	return source
+}
+
+// startMetricsServer serves the prometheus metrics in the background if the metrics server is enabled
+func startMetricsServer() {
+	if !metricsServer {
+		return
+	}
+
+	go func() {
+		if err := metrics.StartMetricsServer(metricsAddress, metricsPort); err != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to start metrics server: %s", err))
+		}
+	}()
+
+	logger.Info().Msg(fmt.Sprintf("serving metrics on %s:%d under /metrics", metricsAddress, metricsPort))
+}
+
+// startControlApiServer serves the control api of the running sync in the background if it is enabled
+func startControlApiServer() {
+	if !controlApi {
+		return
+	}
+
+	go func() {
+		if err := server.StartControlApiServer(controlApiAddress, controlApiPort); err != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to start control api server: %s", err))
+		}
+	}()
+
+	logger.Info().Msg(fmt.Sprintf("serving control api on %s:%d", controlApiAddress, controlApiPort))
 }
@@ -16,13 +16,15 @@ func init() {
 	serveBlocksCmd.Flags().StringVarP(&engine, "engine", "e", "", fmt.Sprintf("consensus engine of the binary by default %s is used, list all engines with \"ksync engines\"", utils.DefaultEngine))
 
 	serveBlocksCmd.Flags().StringVarP(&binaryPath, "binary", "b", "", "binary path of node to be synced")
+	serveBlocksCmd.Flags().StringVar(&abciTransport, "abci-transport", "", fmt.Sprintf("transport of the ABCI connection to the app [\"%s\",\"%s\"], by default detected from the proxy_app address and the abci setting of the config.toml", utils.AbciTransportSocket, utils.AbciTransportGrpc))
+	serveBlocksCmd.Flags().BoolVar(&verifyResults, "verify-results", false, "compare the results hash and the app hash of the app with the next block after every block and stop with a divergence report on a mismatch")
 	if err := serveBlocksCmd.MarkFlagRequired("binary"); err != nil {
 		panic(fmt.Errorf("flag 'binary' should be required: %w", err))
 	}
 
 	serveBlocksCmd.Flags().StringVarP(&homePath, "home", "h", "", "home directory")
 
-	serveBlocksCmd.Flags().StringVar(&blockRpc, "block-rpc", "", "rpc endpoint of the source node to sync blocks from")
+	serveBlocksCmd.Flags().StringVar(&blockRpc, "block-rpc", "", "rpc endpoint of the source node to sync blocks from, multiple endpoints can be given comma separated and heights are routed to the nodes which have them")
 	if err := serveBlocksCmd.MarkFlagRequired("block-rpc"); err != nil {
 		panic(fmt.Errorf("flag 'block-rpc' should be required: %w", err))
 	}
@@ -32,13 +34,30 @@ func init() {
 	serveBlocksCmd.Flags().Int64VarP(&targetHeight, "target-height", "t", 0, "the height at which KSYNC will exit once reached")
 
 	serveBlocksCmd.Flags().Int64Var(&blockRpcReqTimeout, "block-rpc-req-timeout", utils.RequestBlocksTimeoutMS, "port where the block api server will be started")
+	serveBlocksCmd.Flags().Int64Var(&blockRpcConcurrency, "block-rpc-concurrency", utils.DefaultBlockRpcConcurrency, "number of parallel requests to the block rpc")
+	serveBlocksCmd.Flags().Int64Var(&blockRpcBatchSize, "block-rpc-batch-size", utils.DefaultBlockRpcBatchSize, "number of blocks requested at once if the block rpc supports JSON-RPC batch requests")
+	serveBlocksCmd.Flags().Int64Var(&blockRpcRateLimit, "block-rpc-rate-limit", 0, "maximum number of requests per second to the block rpc, no limit if zero")
 
 	serveBlocksCmd.Flags().BoolVar(&rpcServer, "rpc-server", true, "rpc server serving /status, /block and /block_results")
 	serveBlocksCmd.Flags().Int64Var(&rpcServerPort, "rpc-server-port", utils.DefaultRpcServerPort, "port where the rpc server will be started")
 
+	serveBlocksCmd.Flags().Int64Var(&progressInterval, "progress-interval", utils.DefaultProgressInterval, "interval in seconds in which the sync progress is logged, zero disables the progress logs")
+	serveBlocksCmd.Flags().BoolVar(&progressBar, "progress-bar", false, "show a progress bar at the bottom of the terminal")
+
+	serveBlocksCmd.Flags().BoolVar(&controlApi, "control-api", false, "serve an api which reports the status of the sync and allows to pause, resume and change the target height")
+	serveBlocksCmd.Flags().Int64Var(&controlApiPort, "control-api-port", utils.DefaultControlApiPort, "port for the control api")
+	serveBlocksCmd.Flags().StringVar(&controlApiAddress, "control-api-address", utils.DefaultControlApiAddress, "address the control api listens on, the api is unauthenticated so only expose it to trusted networks")
+
+	serveBlocksCmd.Flags().BoolVar(&metricsServer, "metrics", false, "serve prometheus metrics of the sync progress")
+	serveBlocksCmd.Flags().Int64Var(&metricsPort, "metrics-port", utils.DefaultMetricsPort, "port for the prometheus metrics server")
+	serveBlocksCmd.Flags().StringVar(&metricsAddress, "metrics-address", utils.DefaultMetricsAddress, "address the prometheus metrics server listens on")
+
 	serveBlocksCmd.Flags().StringVarP(&source, "source", "s", "", "chain-id of the source")
 	serveBlocksCmd.Flags().StringVar(&registryUrl, "registry-url", utils.DefaultRegistryURL, "URL to fetch latest KYVE Source-Registry")
 
+	serveBlocksCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the plan of the sync and exit without syncing")
+	serveBlocksCmd.Flags().StringVarP(&output, "output", "o", utils.OutputText, fmt.Sprintf("output format of the dry-run plan [\"%s\",\"%s\"]", utils.OutputText, utils.OutputJson))
+
 	serveBlocksCmd.Flags().BoolVarP(&reset, "reset-all", "r", false, "reset this node's validator to genesis state")
 	serveBlocksCmd.Flags().BoolVar(&optOut, "opt-out", false, "disable the collection of anonymous usage data")
 	serveBlocksCmd.Flags().BoolVarP(&debug, "debug", "d", false, "show logs from tendermint app")
@@ -51,12 +70,28 @@ var serveBlocksCmd = &cobra.Command{
 	Use:   "serve-blocks",
 	Short: "Start fast syncing blocks from RPC endpoints with KSYNC",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		chainRest = ""
+		if err := checkDryRunFlags(); err != nil {
+			return err
+		}
+
+		if blockRpcRateLimit < 0 || blockRpcRateLimit > int64(time.Second) {
+			return fmt.Errorf("block rpc rate limit %d is out of range, it has to be between 0 and %d requests per second", blockRpcRateLimit, int64(time.Second))
+		}
+
+		chainRestGroup = nil
 		storageRest = ""
 
 		blockRpcConfig := types.BlockRpcConfig{
-			Endpoint:       blockRpc,
+			Endpoints:      utils.ParseEndpoints(blockRpc),
 			RequestTimeout: time.Duration(blockRpcReqTimeout * int64(time.Millisecond)),
+			Concurrency:    blockRpcConcurrency,
+			BatchSize:      blockRpcBatchSize,
+			RateLimit:      blockRpcRateLimit,
+		}
+
+		progressCfg := types.ProgressConfig{
+			Interval: time.Duration(progressInterval) * time.Second,
+			Bar:      progressBar,
 		}
 
 		// if no home path was given get the default one
@@ -69,7 +104,11 @@ var serveBlocksCmd = &cobra.Command{
 			logger.Info().Msgf("Loaded engine \"%s\" from binary path", engine)
 		}
 
-		defaultEngine := engines.EngineFactory(engine, homePath, rpcServerPort)
+		// diverging tx results are compared with the block results of the block rpcs
+		engineConfig := getEngineConfig()
+		engineConfig.BlockRpcConfig = &blockRpcConfig
+
+		defaultEngine := engines.EngineFactory(engine, engineConfig)
 
 		if source == "" && blockPoolId == "" {
 			s, err := defaultEngine.GetChainId()
@@ -101,7 +140,8 @@ var serveBlocksCmd = &cobra.Command{
 		}
 
 		// perform validation checks before booting block-sync process
-		if err := blocksync.PerformBlockSyncValidationChecks(chainRest, &blockRpcConfig, nil, continuationHeight, targetHeight, true, !y); err != nil {
+		blockSyncPlan, err := blocksync.PerformBlockSyncValidationChecks(chainRestGroup, &blockRpcConfig, nil, continuationHeight, targetHeight, true, !y && !dryRun)
+		if err != nil {
 			return fmt.Errorf("block-sync validation checks failed: %w", err)
 		}
 
@@ -109,15 +149,33 @@ var serveBlocksCmd = &cobra.Command{
 			return fmt.Errorf("failed to close dbs in engine: %w", err)
 		}
 
-		if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, source, continuationHeight, !y); err != nil {
-			return fmt.Errorf("failed to check if binary has the recommended version: %w", err)
-		}
-
-		consensusEngine, err := engines.EngineSourceFactory(engine, homePath, registryUrl, source, rpcServerPort, continuationHeight)
+		// the engine is resolved before the plan is printed, so the plan reports the engine which is used for the sync
+		consensusEngine, err := engines.EngineSourceFactory(engine, registryUrl, source, continuationHeight, engineConfig)
 		if err != nil {
 			return fmt.Errorf("failed to create consensus engine for source: %w", err)
 		}
 
-		return blocksync.StartBlockSyncWithBinary(consensusEngine, binaryPath, homePath, chainId, chainRest, storageRest, &blockRpcConfig, nil, targetHeight, backupCfg, appFlags, rpcServer, optOut, debug)
+		if dryRun {
+			return printPlan(types.SyncPlan{
+				SyncType:     "serve-blocks",
+				ChainId:      chainId,
+				Source:       source,
+				Engine:       consensusEngine.GetName(),
+				BinaryPath:   binaryPath,
+				HomePath:     homePath,
+				Height:       continuationHeight - 1,
+				TargetHeight: targetHeight,
+				BlockSync:    blockSyncPlan,
+			})
+		}
+
+		startMetricsServer()
+		startControlApiServer()
+
+		if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, source, continuationHeight, !y); err != nil {
+			return fmt.Errorf("failed to check if binary has the recommended version: %w", err)
+		}
+
+		return blocksync.StartBlockSyncWithBinary(cmd.Context(), consensusEngine, binaryPath, homePath, chainId, chainRestGroup, storageRest, &blockRpcConfig, nil, nil, &progressCfg, targetHeight, backupCfg, nil, appFlags, rpcServer, optOut, debug)
 	},
 }
@@ -6,15 +6,17 @@ import (
 	"github.com/KYVENetwork/ksync/engines"
 	"github.com/KYVENetwork/ksync/servesnapshots"
 	"github.com/KYVENetwork/ksync/sources"
+	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 	"github.com/spf13/cobra"
-	"strings"
 )
 
 func init() {
 	servesnapshotsCmd.Flags().StringVarP(&engine, "engine", "e", "", fmt.Sprintf("consensus engine of the binary by default %s is used, list all engines with \"ksync engines\"", utils.DefaultEngine))
 
 	servesnapshotsCmd.Flags().StringVarP(&binaryPath, "binary", "b", "", "binary path of node to be synced")
+	servesnapshotsCmd.Flags().StringVar(&abciTransport, "abci-transport", "", fmt.Sprintf("transport of the ABCI connection to the app [\"%s\",\"%s\"], by default detected from the proxy_app address and the abci setting of the config.toml", utils.AbciTransportSocket, utils.AbciTransportGrpc))
+	servesnapshotsCmd.Flags().BoolVar(&verifyResults, "verify-results", false, "compare the results hash and the app hash of the app with the next block after every block and stop with a divergence report on a mismatch")
 	if err := servesnapshotsCmd.MarkFlagRequired("binary"); err != nil {
 		panic(fmt.Errorf("flag 'binary' should be required: %w", err))
 	}
@@ -23,8 +25,13 @@ func init() {
 
 	servesnapshotsCmd.Flags().StringVarP(&chainId, "chain-id", "c", utils.DefaultChainId, fmt.Sprintf("KYVE chain id [\"%s\",\"%s\",\"%s\"]", utils.ChainIdMainnet, utils.ChainIdKaon, utils.ChainIdKorellia))
 
-	servesnapshotsCmd.Flags().StringVar(&chainRest, "chain-rest", "", "rest endpoint for KYVE chain")
-	servesnapshotsCmd.Flags().StringVar(&storageRest, "storage-rest", "", "storage endpoint for requesting bundle data")
+	servesnapshotsCmd.Flags().StringVar(&chainRest, "chain-rest", "", "rest endpoint for KYVE chain, multiple endpoints can be given comma separated for failover")
+	servesnapshotsCmd.Flags().StringVar(&storageRest, "storage-rest", "", "storage endpoint for requesting bundle data, supports http(s)://, file:// and s3:// endpoints, multiple endpoints can be given comma separated for failover")
+	servesnapshotsCmd.Flags().StringVar(&mirrorDir, "mirror-dir", "", "directory of a bundle mirror created with \"ksync mirror\", replaces the KYVE chain and storage endpoints")
+
+	servesnapshotsCmd.Flags().BoolVar(&bundleCache, "bundle-cache", true, "cache downloaded bundles on disk so they can be reused by following syncs, disable with --bundle-cache=false")
+	servesnapshotsCmd.Flags().StringVar(&bundleCacheDir, "bundle-cache-dir", "", "directory of the bundle cache (default = ~/.ksync/cache)")
+	servesnapshotsCmd.Flags().Int64Var(&bundleCacheSize, "bundle-cache-size", utils.DefaultBundleCacheSize, "maximum size of the bundle cache in MB, least recently used bundles get evicted first")
 
 	servesnapshotsCmd.Flags().StringVarP(&source, "source", "s", "", "chain-id of the source")
 	servesnapshotsCmd.Flags().StringVar(&registryUrl, "registry-url", utils.DefaultRegistryURL, "URL to fetch latest KYVE Source-Registry")
@@ -32,11 +39,22 @@ func init() {
 	servesnapshotsCmd.Flags().StringVar(&snapshotPoolId, "snapshot-pool-id", "", "pool-id of the state-sync pool")
 	servesnapshotsCmd.Flags().StringVar(&blockPoolId, "block-pool-id", "", "pool-id of the block-sync pool")
 
+	servesnapshotsCmd.Flags().Int64Var(&prefetchWorkers, "prefetch-workers", utils.DefaultPrefetchWorkers, "number of bundles which are downloaded in parallel ahead of the block execution")
+	servesnapshotsCmd.Flags().Int64Var(&prefetchMaxMemory, "prefetch-max-memory", utils.DefaultPrefetchMaxMemory, "maximum memory in MB used for holding prefetched compressed bundles")
+
 	servesnapshotsCmd.Flags().Int64Var(&snapshotPort, "snapshot-port", utils.DefaultSnapshotServerPort, "port for snapshot server")
 
 	servesnapshotsCmd.Flags().BoolVar(&rpcServer, "rpc-server", false, "rpc server serving /status, /block and /block_results")
 	servesnapshotsCmd.Flags().Int64Var(&rpcServerPort, "rpc-server-port", utils.DefaultRpcServerPort, "port for rpc server")
 
+	servesnapshotsCmd.Flags().BoolVar(&controlApi, "control-api", false, "serve an api which reports the status of the sync and allows to pause, resume and change the target height")
+	servesnapshotsCmd.Flags().Int64Var(&controlApiPort, "control-api-port", utils.DefaultControlApiPort, "port for the control api")
+	servesnapshotsCmd.Flags().StringVar(&controlApiAddress, "control-api-address", utils.DefaultControlApiAddress, "address the control api listens on, the api is unauthenticated so only expose it to trusted networks")
+
+	servesnapshotsCmd.Flags().BoolVar(&metricsServer, "metrics", false, "serve prometheus metrics of the sync progress")
+	servesnapshotsCmd.Flags().Int64Var(&metricsPort, "metrics-port", utils.DefaultMetricsPort, "port for the prometheus metrics server")
+	servesnapshotsCmd.Flags().StringVar(&metricsAddress, "metrics-address", utils.DefaultMetricsAddress, "address the prometheus metrics server listens on")
+
 	servesnapshotsCmd.Flags().Int64Var(&startHeight, "start-height", 0, "start creating snapshots at this height. note that pruning should be false when using start height")
 	servesnapshotsCmd.Flags().Int64VarP(&targetHeight, "target-height", "t", 0, "the height at which KSYNC will exit once reached")
 
@@ -57,8 +75,26 @@ var servesnapshotsCmd = &cobra.Command{
 	Use:   "serve-snapshots",
 	Short: "Serve snapshots for running KYVE state-sync pools",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		chainRest = utils.GetChainRest(chainId, chainRest)
-		storageRest = strings.TrimSuffix(storageRest, "/")
+		if err := checkDryRunFlags(); err != nil {
+			return err
+		}
+
+		startMetricsServer()
+		startControlApiServer()
+
+		if err := initEndpoints(); err != nil {
+			return err
+		}
+		defer closeMirrorApiServer()
+
+		if err := initBundleCache(); err != nil {
+			return err
+		}
+
+		prefetchCfg := types.PrefetchConfig{
+			Workers:     prefetchWorkers,
+			MaxMemoryMB: prefetchMaxMemory,
+		}
 
 		// if no home path was given get the default one
 		if homePath == "" {
@@ -70,7 +106,7 @@ var servesnapshotsCmd = &cobra.Command{
 			logger.Info().Msgf("Loaded engine \"%s\" from binary path", engine)
 		}
 
-		defaultEngine := engines.EngineFactory(engine, homePath, rpcServerPort)
+		defaultEngine := engines.EngineFactory(engine, getEngineConfig())
 
 		if source == "" && blockPoolId == "" && snapshotPoolId == "" {
 			s, err := defaultEngine.GetChainId()
@@ -81,7 +117,7 @@ var servesnapshotsCmd = &cobra.Command{
 			logger.Info().Msgf("Loaded source \"%s\" from genesis file", source)
 		}
 
-		bId, sId, err := sources.GetPoolIds(chainId, source, blockPoolId, snapshotPoolId, registryUrl, true, true)
+		bId, sId, err := getPoolIds(true, true)
 		if err != nil {
 			return fmt.Errorf("failed to load pool-ids: %w", err)
 		}
@@ -97,7 +133,7 @@ var servesnapshotsCmd = &cobra.Command{
 		}
 
 		// perform validation checks before booting state-sync process
-		snapshotBundleId, snapshotHeight, err := servesnapshots.PerformServeSnapshotsValidationChecks(defaultEngine, chainRest, sId, bId, startHeight, targetHeight)
+		snapshotBundleId, snapshotHeight, err := servesnapshots.PerformServeSnapshotsValidationChecks(defaultEngine, chainRestGroup, sId, bId, startHeight, targetHeight)
 		if err != nil {
 			return fmt.Errorf("serve-snapshots validation checks failed: %w", err)
 		}
@@ -112,25 +148,25 @@ var servesnapshotsCmd = &cobra.Command{
 			continuationHeight = c
 		}
 
-		if err := blocksync.PerformBlockSyncValidationChecks(chainRest, nil, &bId, continuationHeight, targetHeight, true, false); err != nil {
+		if _, err := blocksync.PerformBlockSyncValidationChecks(chainRestGroup, nil, &bId, continuationHeight, targetHeight, true, false); err != nil {
 			return fmt.Errorf("block-sync validation checks failed: %w", err)
 		}
 
-		utils.TrackServeSnapshotsEvent(defaultEngine, chainId, chainRest, storageRest, snapshotPort, rpcServer, rpcServerPort, startHeight, pruning, keepSnapshots, debug, optOut)
+		utils.TrackServeSnapshotsEvent(defaultEngine, chainId, chainRestGroup, storageRest, snapshotPort, rpcServer, rpcServerPort, startHeight, pruning, keepSnapshots, debug, optOut)
 
 		if err := defaultEngine.CloseDBs(); err != nil {
 			return fmt.Errorf("failed to close dbs in engine: %w", err)
 		}
 
-		if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, source, continuationHeight, !y); err != nil {
+		if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, registrySource(), continuationHeight, !y); err != nil {
 			return fmt.Errorf("failed to check if binary has the recommended version: %w", err)
 		}
 
-		consensusEngine, err := engines.EngineSourceFactory(engine, homePath, registryUrl, source, rpcServerPort, continuationHeight)
+		consensusEngine, err := engines.EngineSourceFactory(engine, registryUrl, registrySource(), continuationHeight, getEngineConfig())
 		if err != nil {
 			return fmt.Errorf("failed to create consensus engine for source: %w", err)
 		}
 
-		return servesnapshots.StartServeSnapshotsWithBinary(consensusEngine, binaryPath, homePath, chainRest, storageRest, &bId, sId, targetHeight, height, snapshotBundleId, snapshotHeight, snapshotPort, appFlags, rpcServer, pruning, keepSnapshots, skipWaiting, debug)
+		return servesnapshots.StartServeSnapshotsWithBinary(cmd.Context(), consensusEngine, binaryPath, homePath, chainRestGroup, storageRest, &bId, &prefetchCfg, sId, targetHeight, height, snapshotBundleId, snapshotHeight, snapshotPort, appFlags, rpcServer, pruning, keepSnapshots, skipWaiting, debug)
 	},
 }
@@ -6,22 +6,28 @@ import (
 	"github.com/KYVENetwork/ksync/engines"
 	"github.com/KYVENetwork/ksync/sources"
 	"github.com/KYVENetwork/ksync/statesync"
+	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 	"github.com/spf13/cobra"
-	"strings"
 )
 
 func init() {
 	stateSyncCmd.Flags().StringVarP(&engine, "engine", "e", "", fmt.Sprintf("consensus engine of the binary by default %s is used, list all engines with \"ksync engines\"", utils.DefaultEngine))
 
 	stateSyncCmd.Flags().StringVarP(&binaryPath, "binary", "b", "", "binary path of node to be synced, if not provided the binary has to be started externally with --with-tendermint=false")
+	stateSyncCmd.Flags().StringVar(&abciTransport, "abci-transport", "", fmt.Sprintf("transport of the ABCI connection to the app [\"%s\",\"%s\"], by default detected from the proxy_app address and the abci setting of the config.toml", utils.AbciTransportSocket, utils.AbciTransportGrpc))
 
 	stateSyncCmd.Flags().StringVarP(&homePath, "home", "h", "", "home directory")
 
 	stateSyncCmd.Flags().StringVarP(&chainId, "chain-id", "c", utils.DefaultChainId, fmt.Sprintf("KYVE chain id [\"%s\",\"%s\",\"%s\"]", utils.ChainIdMainnet, utils.ChainIdKaon, utils.ChainIdKorellia))
 
-	stateSyncCmd.Flags().StringVar(&chainRest, "chain-rest", "", "rest endpoint for KYVE chain")
-	stateSyncCmd.Flags().StringVar(&storageRest, "storage-rest", "", "storage endpoint for requesting bundle data")
+	stateSyncCmd.Flags().StringVar(&chainRest, "chain-rest", "", "rest endpoint for KYVE chain, multiple endpoints can be given comma separated for failover")
+	stateSyncCmd.Flags().StringVar(&storageRest, "storage-rest", "", "storage endpoint for requesting bundle data, supports http(s)://, file:// and s3:// endpoints, multiple endpoints can be given comma separated for failover")
+	stateSyncCmd.Flags().StringVar(&mirrorDir, "mirror-dir", "", "directory of a bundle mirror created with \"ksync mirror\", replaces the KYVE chain and storage endpoints")
+
+	stateSyncCmd.Flags().BoolVar(&bundleCache, "bundle-cache", true, "cache downloaded bundles on disk so they can be reused by following syncs, disable with --bundle-cache=false")
+	stateSyncCmd.Flags().StringVar(&bundleCacheDir, "bundle-cache-dir", "", "directory of the bundle cache (default = ~/.ksync/cache)")
+	stateSyncCmd.Flags().Int64Var(&bundleCacheSize, "bundle-cache-size", utils.DefaultBundleCacheSize, "maximum size of the bundle cache in MB, least recently used bundles get evicted first")
 
 	stateSyncCmd.Flags().StringVarP(&source, "source", "s", "", "chain-id of the source")
 	stateSyncCmd.Flags().StringVar(&registryUrl, "registry-url", utils.DefaultRegistryURL, "URL to fetch latest KYVE Source-Registry")
@@ -32,6 +38,9 @@ func init() {
 
 	stateSyncCmd.Flags().Int64VarP(&targetHeight, "target-height", "t", 0, "snapshot height, if not specified it will use the latest available snapshot height")
 
+	stateSyncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the plan of the sync and exit without syncing")
+	stateSyncCmd.Flags().StringVarP(&output, "output", "o", utils.OutputText, fmt.Sprintf("output format of the dry-run plan [\"%s\",\"%s\"]", utils.OutputText, utils.OutputJson))
+
 	stateSyncCmd.Flags().BoolVarP(&reset, "reset-all", "r", false, "reset this node's validator to genesis state")
 	stateSyncCmd.Flags().BoolVar(&optOut, "opt-out", false, "disable the collection of anonymous usage data")
 	stateSyncCmd.Flags().BoolVarP(&debug, "debug", "d", false, "show logs from tendermint app")
@@ -44,8 +53,18 @@ var stateSyncCmd = &cobra.Command{
 	Use:   "state-sync",
 	Short: "Apply a state-sync snapshot",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		chainRest = utils.GetChainRest(chainId, chainRest)
-		storageRest = strings.TrimSuffix(storageRest, "/")
+		if err := checkDryRunFlags(); err != nil {
+			return err
+		}
+
+		if err := initEndpoints(); err != nil {
+			return err
+		}
+		defer closeMirrorApiServer()
+
+		if err := initBundleCache(); err != nil {
+			return err
+		}
 
 		// if no binary was provided at least the home path needs to be defined
 		if binaryPath == "" && homePath == "" {
@@ -66,7 +85,7 @@ var stateSyncCmd = &cobra.Command{
 			logger.Info().Msgf("Loaded engine \"%s\" from binary path", engine)
 		}
 
-		defaultEngine := engines.EngineFactory(engine, homePath, rpcServerPort)
+		defaultEngine := engines.EngineFactory(engine, getEngineConfig())
 
 		if source == "" && snapshotPoolId == "" {
 			s, err := defaultEngine.GetChainId()
@@ -77,7 +96,7 @@ var stateSyncCmd = &cobra.Command{
 			logger.Info().Msgf("Loaded source \"%s\" from genesis file", source)
 		}
 
-		_, sId, err := sources.GetPoolIds(chainId, source, "", snapshotPoolId, registryUrl, false, true)
+		_, sId, err := getPoolIds(false, true)
 		if err != nil {
 			return fmt.Errorf("failed to load pool-ids: %w", err)
 		}
@@ -89,20 +108,37 @@ var stateSyncCmd = &cobra.Command{
 		}
 
 		// perform validation checks before booting state-sync process
-		snapshotBundleId, snapshotHeight, err := statesync.PerformStateSyncValidationChecks(chainRest, sId, targetHeight, !y)
+		stateSyncPlan, err := statesync.PerformStateSyncValidationChecks(chainRestGroup, sId, targetHeight, !y && !dryRun)
 		if err != nil {
 			return fmt.Errorf("state-sync validation checks failed: %w", err)
 		}
 
-		if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, source, snapshotHeight, !y); err != nil {
-			return fmt.Errorf("failed to check if binary has the recommended version: %w", err)
-		}
+		snapshotBundleId, snapshotHeight := stateSyncPlan.SnapshotBundleId, stateSyncPlan.SnapshotHeight
 
-		consensusEngine, err := engines.EngineSourceFactory(engine, homePath, registryUrl, source, rpcServerPort, snapshotHeight)
+		// the engine is resolved before the plan is printed, so the plan reports the engine which is used for the sync
+		consensusEngine, err := engines.EngineSourceFactory(engine, registryUrl, registrySource(), snapshotHeight, getEngineConfig())
 		if err != nil {
 			return fmt.Errorf("failed to create consensus engine for source: %w", err)
 		}
 
-		return statesync.StartStateSyncWithBinary(consensusEngine, binaryPath, chainId, chainRest, storageRest, sId, targetHeight, snapshotBundleId, snapshotHeight, appFlags, optOut, debug)
+		if dryRun {
+			return printPlan(types.SyncPlan{
+				SyncType:       "state-sync",
+				ChainId:        chainId,
+				Source:         source,
+				Engine:         consensusEngine.GetName(),
+				BinaryPath:     binaryPath,
+				HomePath:       homePath,
+				SnapshotPoolId: &sId,
+				TargetHeight:   targetHeight,
+				StateSync:      stateSyncPlan,
+			})
+		}
+
+		if err := sources.IsBinaryRecommendedVersion(binaryPath, registryUrl, registrySource(), snapshotHeight, !y); err != nil {
+			return fmt.Errorf("failed to check if binary has the recommended version: %w", err)
+		}
+
+		return statesync.StartStateSyncWithBinary(cmd.Context(), consensusEngine, binaryPath, chainId, chainRestGroup, storageRest, sId, targetHeight, snapshotBundleId, snapshotHeight, appFlags, optOut, debug)
 	},
 }
@@ -1,12 +1,15 @@
 package blocks
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/KYVENetwork/ksync/collectors/bundles"
 	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
-	tmjson "github.com/tendermint/tendermint/libs/json"
+	"io"
+	"math"
 	"strconv"
 	"time"
 )
@@ -15,191 +18,340 @@ var (
 	logger = utils.KsyncLogger("collector")
 )
 
-// getPaginationKeyForBlockHeight gets the pagination key right for the bundle so the StartBlockCollector can
-// directly start at the correct bundle. Therefore, it does not need to search through all the bundles until
-// it finds the correct one
-func getPaginationKeyForBlockHeight(chainRest string, blockPool types.PoolResponse, height int64) (string, error) {
-	finalizedBundle, err := bundles.GetFinalizedBundleForBlockHeight(chainRest, blockPool, height)
-	if err != nil {
-		return "", fmt.Errorf("failed to get finalized bundle for block height %d: %w", height, err)
+// StartBlockCollector collects blocks starting from the continuation height and sends them in order to the
+// item channel. It stops once the context is canceled
+func StartBlockCollector(ctx context.Context, itemCh chan<- types.DataItem, errorCh chan<- error, chainRest *utils.EndpointGroup, storageRest string, blockRpcConfig *types.BlockRpcConfig, blockPool *types.PoolResponse, prefetchCfg *types.PrefetchConfig, resumeBundleId *int64, continuationHeight, targetHeight int64, mustExit bool) {
+	if blockRpcConfig == nil {
+		startBlockCollectorFromBundles(ctx, itemCh, errorCh, chainRest, storageRest, *blockPool, prefetchCfg, resumeBundleId, continuationHeight, targetHeight, mustExit)
+	} else {
+		startBlockCollectorFromRpc(ctx, itemCh, errorCh, *blockRpcConfig, continuationHeight, targetHeight, mustExit)
 	}
+}
 
-	bundleId, err := strconv.ParseInt(finalizedBundle.Id, 10, 64)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse bundle id %s: %w", finalizedBundle.Id, err)
+// sendError hands the error to the executor unless the collector got canceled
+func sendError(ctx context.Context, errorCh chan<- error, err error) {
+	select {
+	case <-ctx.Done():
+	case errorCh <- err:
 	}
+}
 
-	// if bundleId is zero we start from the beginning, meaning the paginationKey should be empty
-	if bundleId == 0 {
-		return "", nil
-	}
+// getStartBundle returns the finalized bundle containing the continuation height. If a bundle id to
+// resume from is given, it and the following bundles are checked first, since the checkpoint the id
+// was taken from can be a few blocks behind the continuation height
+func getStartBundle(chainRest *utils.EndpointGroup, blockPool types.PoolResponse, index *bundles.BundleIndex, resumeBundleId *int64, continuationHeight int64) (*types.FinalizedBundle, error) {
+	if resumeBundleId != nil {
+		for bundleId := *resumeBundleId; bundleId < *resumeBundleId+utils.CheckpointBundleLookahead; bundleId++ {
+			var finalizedBundle *types.FinalizedBundle
+			var err error
+
+			if indexed := index.Bundles(bundleId); len(indexed) > 0 {
+				finalizedBundle = &indexed[0]
+			} else if finalizedBundle, err = bundles.GetFinalizedBundleById(chainRest, blockPool.Pool.Id, bundleId); err != nil {
+				break
+			}
 
-	_, paginationKey, err := bundles.GetFinalizedBundlesPageWithOffset(chainRest, blockPool.Pool.Id, 1, bundleId-1, "", false)
-	if err != nil {
-		return "", fmt.Errorf("failed to get finalized bundles: %w", err)
+			if bundles.IsHeightInBundle(*finalizedBundle, continuationHeight) {
+				return finalizedBundle, nil
+			}
+
+			// the continuation height lies before this bundle, so no following bundle contains it
+			if toHeight, err := utils.ParseBlockHeightFromKey(finalizedBundle.ToKey); err != nil || toHeight > continuationHeight {
+				break
+			}
+		}
+
+		logger.Info().Msg(fmt.Sprintf("bundles following bundle %d do not contain continuation height %d, looking up bundle", *resumeBundleId, continuationHeight))
 	}
 
-	return paginationKey, nil
+	return bundles.GetFinalizedBundleForBlockHeight(chainRest, blockPool, continuationHeight)
 }
 
-func StartBlockCollector(itemCh chan<- types.DataItem, errorCh chan<- error, chainRest string, storageRest string, blockRpcConfig *types.BlockRpcConfig, blockPool *types.PoolResponse, continuationHeight, targetHeight int64, mustExit bool) {
-	if blockRpcConfig == nil {
-		startBlockCollectorFromBundles(itemCh, errorCh, chainRest, storageRest, *blockPool, continuationHeight, targetHeight, mustExit)
-	} else {
-		startBlockCollectorFromRpc(itemCh, errorCh, *blockRpcConfig, continuationHeight, targetHeight, mustExit)
+// scheduleFinalizedBundles walks through the finalized bundles of the block pool starting at the
+// continuation height and schedules them in the prefetcher. Bundles which are already in the local
+// bundle index are scheduled directly, all following bundles are requested from the KYVE REST and
+// added to the index
+func scheduleFinalizedBundles(prefetcher *bundlePrefetcher, chainRest *utils.EndpointGroup, blockPool types.PoolResponse, resumeBundleId *int64, continuationHeight int64, mustExit bool) {
+	index, err := bundles.GetBundleIndex(chainRest, blockPool.Pool.Id)
+	if err != nil {
+		prefetcher.fail(fmt.Errorf("failed to get bundle index: %w", err))
+		return
 	}
-}
 
-func startBlockCollectorFromBundles(itemCh chan<- types.DataItem, errorCh chan<- error, chainRest, storageRest string, blockPool types.PoolResponse, continuationHeight, targetHeight int64, mustExit bool) {
-	paginationKey, err := getPaginationKeyForBlockHeight(chainRest, blockPool, continuationHeight)
+	startBundle, err := getStartBundle(chainRest, blockPool, index, resumeBundleId, continuationHeight)
 	if err != nil {
-		errorCh <- fmt.Errorf("failed to get pagination key for continuation height %d: %w", continuationHeight, err)
+		prefetcher.fail(fmt.Errorf("failed to get finalized bundle for continuation height %d: %w", continuationHeight, err))
 		return
 	}
 
-BundleCollector:
-	for {
-		bundlesPage, nextKey, err := bundles.GetFinalizedBundlesPage(chainRest, blockPool.Pool.Id, utils.BundlesPageLimit, paginationKey, false)
-		if err != nil {
-			errorCh <- fmt.Errorf("failed to get finalized bundles page: %w", err)
-			return
-		}
+	nextBundleId, err := strconv.ParseInt(startBundle.Id, 10, 64)
+	if err != nil {
+		prefetcher.fail(fmt.Errorf("failed to parse bundle id %s: %w", startBundle.Id, err))
+		return
+	}
 
-		for _, finalizedBundle := range bundlesPage {
+	// schedule returns false if the prefetcher was stopped or failed
+	schedule := func(finalizedBundles []types.FinalizedBundle) bool {
+		for _, finalizedBundle := range finalizedBundles {
 			height, err := strconv.ParseInt(finalizedBundle.ToKey, 10, 64)
 			if err != nil {
-				errorCh <- fmt.Errorf("failed to parse bundle to key to int64: %w", err)
-				return
+				prefetcher.fail(fmt.Errorf("failed to parse bundle to key to int64: %w", err))
+				return false
 			}
 
-			if height < continuationHeight {
+			bundleId, err := strconv.ParseInt(finalizedBundle.Id, 10, 64)
+			if err != nil {
+				prefetcher.fail(fmt.Errorf("failed to parse bundle id %s: %w", finalizedBundle.Id, err))
+				return false
+			}
+
+			if bundleId < nextBundleId || height < continuationHeight {
 				continue
 			} else {
 				logger.Info().Msg(fmt.Sprintf("downloading bundle with storage id %s", finalizedBundle.StorageId))
 			}
 
-			deflated, err := bundles.GetDataFromFinalizedBundle(finalizedBundle, storageRest)
-			if err != nil {
-				errorCh <- fmt.Errorf("failed to get data from finalized bundle: %w", err)
-				return
+			if !prefetcher.schedule(finalizedBundle) {
+				return false
 			}
 
-			// parse bundle
-			var bundle types.Bundle
+			nextBundleId, continuationHeight = bundleId+1, height+1
+		}
 
-			if err := json.Unmarshal(deflated, &bundle); err != nil {
-				errorCh <- fmt.Errorf("failed to unmarshal tendermint bundle: %w", err)
-				return
-			}
+		return true
+	}
 
-			for _, dataItem := range bundle {
-				itemHeight, err := utils.ParseBlockHeightFromKey(dataItem.Key)
-				if err != nil {
-					errorCh <- fmt.Errorf("failed parse block height from key %s: %w", dataItem.Key, err)
-					return
-				}
+	if !schedule(index.Bundles(nextBundleId)) {
+		return
+	}
 
-				// skip blocks until we reach start height
-				if itemHeight < continuationHeight {
-					continue
-				}
+	// the first page starts right after the last scheduled bundle
+	paginationKey := ""
+
+	for {
+		offset := int64(0)
+		if paginationKey == "" {
+			offset = nextBundleId
+		}
 
-				// send raw data item executor
-				itemCh <- dataItem
+		bundlesPage, nextKey, err := bundles.GetFinalizedBundlesPageWithOffset(chainRest, blockPool.Pool.Id, utils.BundlesPageLimit, offset, paginationKey, false)
+		if err != nil {
+			prefetcher.fail(fmt.Errorf("failed to get finalized bundles page: %w", err))
+			return
+		}
 
-				// keep track of latest retrieved height
-				continuationHeight = itemHeight + 1
+		if err := index.Add(bundlesPage); err != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to add finalized bundles to index: %s", err))
+		}
 
-				// exit if mustExit is true and target height is reached
-				if mustExit && targetHeight > 0 && itemHeight >= targetHeight+1 {
-					break BundleCollector
-				}
-			}
+		if !schedule(bundlesPage) {
+			return
 		}
 
 		if nextKey == "" {
 			if mustExit {
 				// if there is no new page we do not continue
-				logger.Info().Msg("reached latest block on pool. Stopping block collector")
-				break
+				prefetcher.finish()
+				return
 			} else {
 				// if we are at the end of the page we continue and wait for
-				// new finalized bundles
-				time.Sleep(30 * time.Second)
+				// new finalized bundles until the prefetcher gets stopped
+				if err := utils.SleepWithContext(prefetcher.ctx, 30*time.Second); err != nil {
+					return
+				}
+				paginationKey = ""
 				continue
 			}
 		}
 
-		time.Sleep(utils.RequestTimeoutMS)
+		if err := utils.SleepWithContext(prefetcher.ctx, utils.RequestTimeoutMS*time.Millisecond); err != nil {
+			return
+		}
 		paginationKey = nextKey
 	}
 }
 
-// startBlockCollectorFromRpc starts the block collector from the block rpc (must be an archive node which has all blocks)
-func startBlockCollectorFromRpc(itemCh chan<- types.DataItem, errorCh chan<- error, blockRpcConfig types.BlockRpcConfig, continuationHeight, targetHeight int64, mustExit bool) {
-	//	make a for loop starting with the continuation height
-	//		- get the block from the block rpc
-	//		- send the block to the item channel
-	//		- if mustExit is true and target height is reached, break the loop
-	//		- increment the continuation height
+func startBlockCollectorFromBundles(ctx context.Context, itemCh chan<- types.DataItem, errorCh chan<- error, chainRest *utils.EndpointGroup, storageRest string, blockPool types.PoolResponse, prefetchCfg *types.PrefetchConfig, resumeBundleId *int64, continuationHeight, targetHeight int64, mustExit bool) {
+	// bundles are downloaded in parallel ahead of time, but are always
+	// received here in the order of their heights
+	prefetcher := newBundlePrefetcher(ctx, storageRest, prefetchCfg)
+	defer prefetcher.stop()
+
+	go scheduleFinalizedBundles(prefetcher, chainRest, blockPool, resumeBundleId, continuationHeight, mustExit)
+
 	for {
-		dataItem, err := retrieveBlockFromRpc(blockRpcConfig, continuationHeight)
+		var job prefetchJob
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			return
+		case job, ok = <-prefetcher.ordered:
+		}
+
+		if !ok {
+			break
+		}
+
+		var result prefetchResult
+
+		select {
+		case <-ctx.Done():
+			return
+		case result = <-job.result:
+		}
+
+		// the error is either from the download of the bundle or from the scheduler
+		if result.err != nil {
+			sendError(ctx, errorCh, result.err)
+			return
+		}
+
+		reachedTarget, err := streamBundle(ctx, itemCh, job.bundle, result.data, &continuationHeight, targetHeight, mustExit)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
 		if err != nil {
-			errorCh <- fmt.Errorf("failed to get block from rpc: %w", err)
+			sendError(ctx, errorCh, err)
+			return
+		}
+
+		if reachedTarget {
 			return
 		}
 
-		itemCh <- *dataItem
+		// free the memory of the bundle so the prefetcher can continue
+		prefetcher.limiter.release(job.index, int64(len(result.data)))
+	}
 
-		if mustExit && targetHeight > 0 && continuationHeight >= targetHeight+1 {
-			break
+	logger.Info().Msg("reached latest block on pool. Stopping block collector")
+}
+
+// streamBundle decompresses and decodes the bundle item by item and sends every block starting
+// from the continuation height to the executor. It returns true once the target height was reached
+func streamBundle(ctx context.Context, itemCh chan<- types.DataItem, finalizedBundle types.FinalizedBundle, data []byte, continuationHeight *int64, targetHeight int64, mustExit bool) (bool, error) {
+	reader, err := bundles.DecompressBundleStreamFromStorageProvider(finalizedBundle, bytes.NewReader(data))
+	if err != nil {
+		return false, fmt.Errorf("failed to decompress bundle: %w", err)
+	}
+	defer reader.Close()
+
+	decoder := bundles.NewBundleDecoder(reader)
+
+	for {
+		// skip blocks until we reach start height without decoding them
+		dataItem, err := decoder.Next(func(key string) bool {
+			itemHeight, err := utils.ParseBlockHeightFromKey(key)
+			return err == nil && itemHeight < *continuationHeight
+		})
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to decode tendermint bundle: %w", err)
+		}
+
+		itemHeight, err := utils.ParseBlockHeightFromKey(dataItem.Key)
+		if err != nil {
+			return false, fmt.Errorf("failed parse block height from key %s: %w", dataItem.Key, err)
+		}
+
+		// send raw data item executor
+		dataItem.BundleId = finalizedBundle.Id
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case itemCh <- *dataItem:
 		}
 
-		continuationHeight++
+		// keep track of latest retrieved height
+		*continuationHeight = itemHeight + 1
 
-		time.Sleep(blockRpcConfig.RequestTimeout)
+		// exit if mustExit is true and target height is reached
+		if mustExit && targetHeight > 0 && itemHeight >= targetHeight+1 {
+			return true, nil
+		}
 	}
 }
 
-func retrieveBlockFromBundle(chainRest, storageRest string, blockPool types.PoolResponse, height int64) (*types.DataItem, error) {
+func retrieveBlockFromBundle(ctx context.Context, chainRest *utils.EndpointGroup, storageRest string, blockPool types.PoolResponse, height int64) (*types.DataItem, error) {
 	finalizedBundle, err := bundles.GetFinalizedBundleForBlockHeight(chainRest, blockPool, height)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get finalized bundle for block height %d: %w", height, err)
 	}
 
-	deflated, err := bundles.GetDataFromFinalizedBundle(*finalizedBundle, storageRest)
+	reader, err := bundles.GetStreamFromFinalizedBundle(ctx, *finalizedBundle, storageRest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get data from finalized bundle: %w", err)
 	}
+	defer reader.Close()
+
+	// skip blocks until we reach the height without decoding them
+	dataItem, err := bundles.NewBundleDecoder(reader).Next(func(key string) bool {
+		itemHeight, err := utils.ParseBlockHeightFromKey(key)
+		return err == nil && itemHeight < height
+	})
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to find bundle with block height %d", height)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode tendermint bundle: %w", err)
+	}
 
-	// parse bundle
-	var bundle types.Bundle
-
-	if err := tmjson.Unmarshal(deflated, &bundle); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal tendermint bundle: %w", err)
+	if _, err := utils.ParseBlockHeightFromKey(dataItem.Key); err != nil {
+		return nil, fmt.Errorf("failed parse block height from key %s: %w", dataItem.Key, err)
 	}
 
-	for _, dataItem := range bundle {
-		itemHeight, err := utils.ParseBlockHeightFromKey(dataItem.Key)
-		if err != nil {
-			return nil, fmt.Errorf("failed parse block height from key %s: %w", dataItem.Key, err)
+	return dataItem, nil
+}
+
+// retrieveBlockFromRpc retrieves the block from the next block rpc in the rotation which can serve it
+func retrieveBlockFromRpc(blockRpcConfig types.BlockRpcConfig, height int64) (*types.DataItem, error) {
+	router := getRpcRouter(blockRpcConfig.Endpoints)
+	retries, failures := 0, 0
+	nodeFailures := make(blockFailures)
+
+	for {
+		node, ok := router.pick(height)
+		if !ok {
+			if retries >= utils.BackoffMaxRetries {
+				return nil, fmt.Errorf("no block rpc can serve block with height %d", height)
+			}
+
+			delaySec := math.Pow(2, float64(retries))
+			logger.Error().Msg(fmt.Sprintf("no block rpc can serve block with height %d, retrying in %d seconds", height, int(delaySec)))
+
+			time.Sleep(time.Duration(delaySec) * time.Second)
+			router.refresh()
+			retries++
+			continue
 		}
 
-		// skip blocks until we reach start height
-		if itemHeight < height {
+		dataItem, err := retrieveBlockFromEndpoint(node.endpoint, height)
+		if err != nil {
+			router.reportFailure(node.endpoint, err)
+			failures++
+			nodeFailures[node.endpoint]++
+
+			if router.exhausted(height, nodeFailures) {
+				return nil, fmt.Errorf("all block rpcs failed %d times to serve block with height %d: %w", utils.RpcBlockMaxFailures, height, err)
+			}
+
+			time.Sleep(retryDelay(failures))
 			continue
 		}
 
-		return &dataItem, nil
+		router.reportSuccess(node.endpoint)
+		return dataItem, nil
 	}
-
-	return nil, fmt.Errorf("failed to find bundle with block height %d", height)
 }
 
-func retrieveBlockFromRpc(blockRpcConfig types.BlockRpcConfig, height int64) (*types.DataItem, error) {
+// retrieveBlockFromEndpoint retrieves the block from a single block rpc without retrying
+func retrieveBlockFromEndpoint(endpoint string, height int64) (*types.DataItem, error) {
 	logger.Info().Msg(fmt.Sprintf("downloading block with height %d", height))
-	result, err := utils.GetFromUrlWithOptions(fmt.Sprintf("%s/block?height=%d", blockRpcConfig.Endpoint, height),
-		utils.GetFromUrlOptions{SkipTLSVerification: true, WithBackoff: true},
+	result, err := utils.GetFromUrlWithOptions(fmt.Sprintf("%s/block?height=%d", endpoint, height),
+		utils.GetFromUrlOptions{SkipTLSVerification: true},
 	)
 	if err != nil {
 		return nil, err
@@ -211,9 +363,9 @@ func retrieveBlockFromRpc(blockRpcConfig types.BlockRpcConfig, height int64) (*t
 	}, nil
 }
 
-func RetrieveBlock(chainRest, storageRest string, blockRpcConfig *types.BlockRpcConfig, blockPool *types.PoolResponse, height int64) (*types.DataItem, error) {
+func RetrieveBlock(ctx context.Context, chainRest *utils.EndpointGroup, storageRest string, blockRpcConfig *types.BlockRpcConfig, blockPool *types.PoolResponse, height int64) (*types.DataItem, error) {
 	if blockRpcConfig == nil {
-		return retrieveBlockFromBundle(chainRest, storageRest, *blockPool, height)
+		return retrieveBlockFromBundle(ctx, chainRest, storageRest, *blockPool, height)
 	}
 	return retrieveBlockFromRpc(*blockRpcConfig, height)
 }
@@ -0,0 +1,68 @@
+package blocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/collectors/bundles"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newBundlesServer serves finalized bundles of pool 1 which each contain ten blocks
+func newBundlesServer(t *testing.T, requests *atomic.Int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/kyve/v1/bundles/1/"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(types.FinalizedBundle{
+			Id:      fmt.Sprintf("%d", id),
+			FromKey: fmt.Sprintf("%d", id*10+1),
+			ToKey:   fmt.Sprintf("%d", id*10+10),
+		})
+	}))
+}
+
+func TestGetStartBundleResumesAfterCrash(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var requests atomic.Int64
+	server := newBundlesServer(t, &requests)
+	defer server.Close()
+
+	chainRest := utils.NewEndpointGroup([]string{server.URL})
+
+	var blockPool types.PoolResponse
+	blockPool.Pool.Id = 1
+
+	index, err := bundles.GetBundleIndex(chainRest, blockPool.Pool.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the checkpoint was saved in bundle 5 but the block store is at height 72 after a crash
+	resumeBundleId := int64(5)
+
+	finalizedBundle, err := getStartBundle(chainRest, blockPool, index, &resumeBundleId, 73)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if finalizedBundle.Id != "7" {
+		t.Fatalf("expected bundle 7, got bundle %s", finalizedBundle.Id)
+	}
+
+	if requests.Load() != 3 {
+		t.Fatalf("expected bundles 5 to 7 to be requested, got %d requests", requests.Load())
+	}
+}
@@ -0,0 +1,196 @@
+package blocks
+
+import (
+	"context"
+	"fmt"
+	"github.com/KYVENetwork/ksync/collectors/bundles"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"sync"
+)
+
+// prefetchJob is a single finalized bundle which gets downloaded by one of the
+// prefetch workers. The result channel is buffered so workers never block on it
+type prefetchJob struct {
+	index  int64
+	bundle types.FinalizedBundle
+	result chan prefetchResult
+}
+
+type prefetchResult struct {
+	data []byte
+	err  error
+}
+
+// memoryLimiter bounds the amount of bundle data which is held in memory
+// by the prefetcher. The bundle the consumer is currently waiting for is
+// always allowed to pass, else a single large bundle could block the
+// prefetcher forever
+type memoryLimiter struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	used   int64
+	max    int64
+	next   int64
+	closed bool
+}
+
+func newMemoryLimiter(max int64) *memoryLimiter {
+	limiter := &memoryLimiter{max: max}
+	limiter.cond = sync.NewCond(&limiter.mu)
+	return limiter
+}
+
+// acquire blocks until n bytes can be held for the bundle with the given index.
+// It returns false if the limiter got closed in the meantime
+func (limiter *memoryLimiter) acquire(index, n int64) bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	for !limiter.closed && limiter.used > 0 && limiter.used+n > limiter.max && index != limiter.next {
+		limiter.cond.Wait()
+	}
+
+	if limiter.closed {
+		return false
+	}
+
+	limiter.used += n
+	return true
+}
+
+// release frees n bytes and marks the bundle with the given index as consumed
+func (limiter *memoryLimiter) release(index, n int64) {
+	limiter.mu.Lock()
+	limiter.used -= n
+	limiter.next = index + 1
+	limiter.mu.Unlock()
+
+	limiter.cond.Broadcast()
+}
+
+func (limiter *memoryLimiter) close() {
+	limiter.mu.Lock()
+	limiter.closed = true
+	limiter.mu.Unlock()
+
+	limiter.cond.Broadcast()
+}
+
+// bundlePrefetcher downloads and verifies finalized bundles with a bounded number
+// of workers while still handing them out in the order they were scheduled. Bundles
+// are kept compressed and only get decompressed while the consumer streams them
+type bundlePrefetcher struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	storageRest string
+	jobs        chan prefetchJob
+	ordered     chan prefetchJob
+	limiter     *memoryLimiter
+	done        chan struct{}
+	once        sync.Once
+	index       int64
+}
+
+func newBundlePrefetcher(ctx context.Context, storageRest string, prefetchCfg *types.PrefetchConfig) *bundlePrefetcher {
+	workers, maxMemoryMB := int64(utils.DefaultPrefetchWorkers), int64(utils.DefaultPrefetchMaxMemory)
+	if prefetchCfg != nil {
+		workers, maxMemoryMB = prefetchCfg.Workers, prefetchCfg.MaxMemoryMB
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	// bundle downloads which are still in flight get canceled once the prefetcher stops
+	ctx, cancel := context.WithCancel(ctx)
+
+	prefetcher := &bundlePrefetcher{
+		ctx:         ctx,
+		cancel:      cancel,
+		storageRest: storageRest,
+		jobs:        make(chan prefetchJob),
+		ordered:     make(chan prefetchJob, workers),
+		limiter:     newMemoryLimiter(maxMemoryMB * 1024 * 1024),
+		done:        make(chan struct{}),
+	}
+
+	for i := int64(0); i < workers; i++ {
+		go prefetcher.startWorker()
+	}
+
+	return prefetcher
+}
+
+func (prefetcher *bundlePrefetcher) startWorker() {
+	for {
+		select {
+		case <-prefetcher.done:
+			return
+		case job := <-prefetcher.jobs:
+			data, err := bundles.GetRawDataFromFinalizedBundle(prefetcher.ctx, job.bundle, prefetcher.storageRest)
+			if err != nil {
+				err = fmt.Errorf("failed to get data from finalized bundle with storage id %s: %w", job.bundle.StorageId, err)
+			} else if !prefetcher.limiter.acquire(job.index, int64(len(data))) {
+				return
+			}
+
+			job.result <- prefetchResult{data: data, err: err}
+		}
+	}
+}
+
+// schedule hands the bundle to the next free worker and queues it for the consumer.
+// It returns false if the prefetcher was stopped
+func (prefetcher *bundlePrefetcher) schedule(bundle types.FinalizedBundle) bool {
+	job := prefetchJob{
+		index:  prefetcher.index,
+		bundle: bundle,
+		result: make(chan prefetchResult, 1),
+	}
+	prefetcher.index++
+
+	// queue the job first so the consumer always receives the bundles in order
+	select {
+	case <-prefetcher.done:
+		return false
+	case prefetcher.ordered <- job:
+	}
+
+	select {
+	case <-prefetcher.done:
+		return false
+	case prefetcher.jobs <- job:
+	}
+
+	return true
+}
+
+// fail queues an error which the consumer receives after all previously
+// scheduled bundles
+func (prefetcher *bundlePrefetcher) fail(err error) {
+	job := prefetchJob{
+		index:  prefetcher.index,
+		result: make(chan prefetchResult, 1),
+	}
+	job.result <- prefetchResult{err: err}
+
+	select {
+	case <-prefetcher.done:
+	case prefetcher.ordered <- job:
+	}
+}
+
+// finish signals the consumer that no more bundles will be scheduled
+func (prefetcher *bundlePrefetcher) finish() {
+	close(prefetcher.ordered)
+}
+
+// stop terminates all workers, bundles which are still in flight are dropped
+func (prefetcher *bundlePrefetcher) stop() {
+	prefetcher.once.Do(func() {
+		close(prefetcher.done)
+		prefetcher.cancel()
+		prefetcher.limiter.close()
+	})
+}
@@ -0,0 +1,183 @@
+package blocks
+
+import (
+	"context"
+	"fmt"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStorageServer serves the bundle data under its storage id, bundles with a lower
+// storage id take longer so they finish downloading after the following ones
+func newStorageServer(t *testing.T, bundleCount int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		time.Sleep(time.Duration(bundleCount-id) * 20 * time.Millisecond)
+		_, _ = w.Write([]byte(fmt.Sprintf("bundle %d", id)))
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newStorageBundle(id int) types.FinalizedBundle {
+	return types.FinalizedBundle{
+		Id:                fmt.Sprintf("%d", id),
+		StorageId:         fmt.Sprintf("%d", id),
+		StorageProviderId: "1",
+		DataHash:          utils.CreateSha256Checksum([]byte(fmt.Sprintf("bundle %d", id))),
+	}
+}
+
+func TestBundlePrefetcherKeepsOrder(t *testing.T) {
+	const bundleCount = 5
+
+	server := newStorageServer(t, bundleCount)
+
+	prefetcher := newBundlePrefetcher(context.Background(), server.URL, &types.PrefetchConfig{Workers: bundleCount, MaxMemoryMB: 1})
+	defer prefetcher.stop()
+
+	go func() {
+		for id := 0; id < bundleCount; id++ {
+			if !prefetcher.schedule(newStorageBundle(id)) {
+				return
+			}
+		}
+		prefetcher.finish()
+	}()
+
+	id := 0
+	for job := range prefetcher.ordered {
+		result := <-job.result
+		if result.err != nil {
+			t.Fatalf("failed to prefetch bundle %d: %s", id, result.err)
+		}
+
+		if string(result.data) != fmt.Sprintf("bundle %d", id) {
+			t.Fatalf("expected bundle %d, got %s", id, result.data)
+		}
+
+		prefetcher.limiter.release(job.index, int64(len(result.data)))
+		id++
+	}
+
+	if id != bundleCount {
+		t.Fatalf("expected %d bundles, got %d", bundleCount, id)
+	}
+}
+
+func TestBundlePrefetcherFailsAfterScheduledBundles(t *testing.T) {
+	server := newStorageServer(t, 1)
+
+	prefetcher := newBundlePrefetcher(context.Background(), server.URL, nil)
+	defer prefetcher.stop()
+
+	go func() {
+		if prefetcher.schedule(newStorageBundle(0)) {
+			prefetcher.fail(fmt.Errorf("failed to get finalized bundles page"))
+		}
+	}()
+
+	if result := <-(<-prefetcher.ordered).result; result.err != nil {
+		t.Fatalf("expected the scheduled bundle before the error, got %s", result.err)
+	}
+
+	if result := <-(<-prefetcher.ordered).result; result.err == nil {
+		t.Fatalf("expected the error after the scheduled bundle")
+	}
+}
+
+// acquireAsync acquires the memory in the background and reports if it was acquired
+func acquireAsync(limiter *memoryLimiter, index, n int64) <-chan bool {
+	acquired := make(chan bool, 1)
+	go func() {
+		acquired <- limiter.acquire(index, n)
+	}()
+	return acquired
+}
+
+func TestMemoryLimiterAccounting(t *testing.T) {
+	limiter := newMemoryLimiter(10)
+
+	if !limiter.acquire(0, 8) {
+		t.Fatalf("expected memory to be acquired")
+	}
+
+	// the second bundle does not fit next to the first one until it got consumed
+	acquired := acquireAsync(limiter, 1, 5)
+
+	select {
+	case <-acquired:
+		t.Fatalf("expected the limiter to block while the memory is exceeded")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.release(0, 8)
+
+	select {
+	case ok := <-acquired:
+		if !ok {
+			t.Fatalf("expected memory to be acquired after the release")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the limiter to unblock after the release")
+	}
+
+	if limiter.used != 5 {
+		t.Errorf("expected 5 used bytes, got %d", limiter.used)
+	}
+}
+
+func TestMemoryLimiterLetsNextBundlePass(t *testing.T) {
+	limiter := newMemoryLimiter(10)
+
+	if !limiter.acquire(1, 8) {
+		t.Fatalf("expected memory to be acquired")
+	}
+
+	// the bundle the consumer waits for passes even if it exceeds the limit,
+	// else the prefetcher would be stuck
+	select {
+	case ok := <-acquireAsync(limiter, 0, 20):
+		if !ok {
+			t.Fatalf("expected memory to be acquired")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the next bundle not to be blocked")
+	}
+
+	if limiter.used != 28 {
+		t.Errorf("expected 28 used bytes, got %d", limiter.used)
+	}
+}
+
+func TestMemoryLimiterClose(t *testing.T) {
+	limiter := newMemoryLimiter(10)
+
+	if !limiter.acquire(0, 10) {
+		t.Fatalf("expected memory to be acquired")
+	}
+
+	acquired := acquireAsync(limiter, 1, 5)
+	limiter.close()
+
+	select {
+	case ok := <-acquired:
+		if ok {
+			t.Fatalf("expected no memory to be acquired after the limiter was closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the limiter to unblock after it was closed")
+	}
+}
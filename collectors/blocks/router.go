@@ -0,0 +1,219 @@
+package blocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// routers are shared between all block retrievals from the same block rpcs, so the
+	// health state and the block ranges of the nodes are only tracked once
+	routersMu sync.Mutex
+	routers   = make(map[string]*rpcRouter)
+)
+
+// rpcNode is a single upstream block rpc together with the range of blocks it can serve
+type rpcNode struct {
+	endpoint       string
+	earliestHeight int64
+	latestHeight   int64
+	supportsBatch  bool
+	probed         bool
+	failures       int64
+	dropped        bool
+}
+
+// rpcRouter routes block heights to the block rpcs which actually have them, this way
+// a full history can be built from multiple pruned nodes which each only cover a part
+// of the chain. Nodes which keep failing are dropped from the rotation until they
+// respond to a status request again
+type rpcRouter struct {
+	mu    sync.Mutex
+	nodes []*rpcNode
+	next  int
+}
+
+// getRpcRouter returns the router for the given block rpcs, it is created on first use
+func getRpcRouter(endpoints []string) *rpcRouter {
+	routersMu.Lock()
+	defer routersMu.Unlock()
+
+	key := strings.Join(endpoints, ",")
+	if router, ok := routers[key]; ok {
+		return router
+	}
+
+	router := newRpcRouter(endpoints)
+	routers[key] = router
+	return router
+}
+
+func newRpcRouter(endpoints []string) *rpcRouter {
+	router := &rpcRouter{}
+
+	for _, endpoint := range endpoints {
+		router.nodes = append(router.nodes, &rpcNode{endpoint: endpoint})
+	}
+
+	router.refresh()
+	return router
+}
+
+// supportsBatchRequests probes once if the block rpc accepts JSON-RPC batch requests
+func supportsBatchRequests(endpoint string) bool {
+	body, err := json.Marshal([]rpcRequest{{JsonRpc: "2.0", Id: 0, Method: "status", Params: map[string]string{}}})
+	if err != nil {
+		return false
+	}
+
+	result, err := utils.PostToUrlWithOptions(endpoint, body, utils.GetFromUrlOptions{
+		SkipTLSVerification: true,
+	})
+	if err != nil {
+		return false
+	}
+
+	var responses []rpcResponse
+	if err := json.Unmarshal(result, &responses); err != nil {
+		return false
+	}
+
+	return len(responses) == 1 && responses[0].Error == nil
+}
+
+// refresh updates the block ranges of all nodes. Nodes which were dropped
+// get added back to the rotation if they respond again
+func (router *rpcRouter) refresh() {
+	for _, node := range router.nodes {
+		status, err := utils.GetStatusFromEndpoint(node.endpoint)
+
+		router.mu.Lock()
+		if err != nil {
+			if !node.dropped {
+				logger.Error().Msg(fmt.Sprintf("dropping block rpc %s from rotation: failed to get status: %s", node.endpoint, err))
+			}
+			node.dropped = true
+			router.mu.Unlock()
+			continue
+		}
+
+		if node.dropped {
+			logger.Info().Msg(fmt.Sprintf("adding block rpc %s back to rotation", node.endpoint))
+		}
+
+		node.earliestHeight = status.Result.SyncInfo.EarliestBlockHeight
+		node.latestHeight = status.Result.SyncInfo.LatestBlockHeight
+		node.failures = 0
+		node.dropped = false
+		probed := node.probed
+		router.mu.Unlock()
+
+		if !probed {
+			supportsBatch := supportsBatchRequests(node.endpoint)
+
+			router.mu.Lock()
+			node.supportsBatch = supportsBatch
+			node.probed = true
+			router.mu.Unlock()
+
+			logger.Info().Msg(fmt.Sprintf("found block rpc %s serving blocks from %d to %d (batch requests supported = %v)", node.endpoint, status.Result.SyncInfo.EarliestBlockHeight, status.Result.SyncInfo.LatestBlockHeight, supportsBatch))
+		}
+	}
+}
+
+// latestHeight returns the highest block height any node in the rotation can serve
+func (router *rpcRouter) latestHeight() (latestHeight int64) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	for _, node := range router.nodes {
+		if !node.dropped {
+			latestHeight = max(latestHeight, node.latestHeight)
+		}
+	}
+
+	return
+}
+
+// pick returns the next node in the rotation which has the block with the given height,
+// nodes are picked round-robin so the load is spread over all nodes covering the height
+func (router *rpcRouter) pick(height int64) (rpcNode, bool) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	for i := range router.nodes {
+		node := router.nodes[(router.next+i)%len(router.nodes)]
+
+		if !node.dropped && node.earliestHeight <= height && height <= node.latestHeight {
+			router.next = (router.next + i + 1) % len(router.nodes)
+			return *node, true
+		}
+	}
+
+	return rpcNode{}, false
+}
+
+func (router *rpcRouter) reportSuccess(endpoint string) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	for _, node := range router.nodes {
+		if node.endpoint == endpoint {
+			node.failures = 0
+		}
+	}
+}
+
+// reportFailure drops the node from the rotation once it failed too often in a row
+func (router *rpcRouter) reportFailure(endpoint string, err error) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	for _, node := range router.nodes {
+		if node.endpoint != endpoint {
+			continue
+		}
+
+		node.failures++
+		logger.Error().Msg(fmt.Sprintf("request to block rpc %s failed: %s", endpoint, err))
+
+		if !node.dropped && node.failures >= utils.RpcNodeMaxFailures {
+			logger.Error().Msg(fmt.Sprintf("dropping block rpc %s from rotation after %d failures", endpoint, node.failures))
+			node.dropped = true
+		}
+	}
+}
+
+// blockFailures counts the failed requests of every node for a single block height. Unlike
+// the failures of a node these are not reset once the node responds to a status request
+// again, so a node which serves its status but keeps failing the block is not retried forever
+type blockFailures map[string]int
+
+// exhausted returns true once every node covering the height failed utils.RpcBlockMaxFailures
+// times for it
+func (router *rpcRouter) exhausted(height int64, failures blockFailures) bool {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	for _, node := range router.nodes {
+		if node.earliestHeight <= height && height <= node.latestHeight && failures[node.endpoint] < utils.RpcBlockMaxFailures {
+			return false
+		}
+	}
+
+	return true
+}
+
+// retryDelay returns the delay before a failed request is retried on the next node, it
+// doubles with every failure in a row up to utils.RpcRetryMaxDelay
+func retryDelay(failures int) time.Duration {
+	delay := utils.RpcRetryBaseDelay
+	for i := 1; i < failures && delay < utils.RpcRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, utils.RpcRetryMaxDelay)
+}
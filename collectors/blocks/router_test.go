@@ -0,0 +1,167 @@
+package blocks
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newRpcServer serves the status of a block rpc which has the blocks from earliest to latest
+func newRpcServer(t *testing.T, earliestHeight, latestHeight int64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(fmt.Sprintf(`{"result":{"sync_info":{"earliest_block_height":"%d","latest_block_height":"%d"}}}`, earliestHeight, latestHeight)))
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetRpcRouterSharesRouters(t *testing.T) {
+	first := newRpcServer(t, 1, 100)
+	second := newRpcServer(t, 1, 100)
+
+	router := getRpcRouter([]string{first.URL, second.URL})
+
+	if getRpcRouter([]string{first.URL, second.URL}) != router {
+		t.Errorf("expected the router to be shared for the same block rpcs")
+	}
+
+	if getRpcRouter([]string{first.URL}) == router {
+		t.Errorf("expected a different router for different block rpcs")
+	}
+}
+
+func TestRpcRouterPickRoutesHeights(t *testing.T) {
+	lower := newRpcServer(t, 1, 100)
+	upper := newRpcServer(t, 101, 200)
+
+	router := newRpcRouter([]string{lower.URL, upper.URL})
+
+	if node, found := router.pick(50); !found || node.endpoint != lower.URL {
+		t.Errorf("expected height 50 to be routed to the lower node, got %s", node.endpoint)
+	}
+
+	if node, found := router.pick(150); !found || node.endpoint != upper.URL {
+		t.Errorf("expected height 150 to be routed to the upper node, got %s", node.endpoint)
+	}
+
+	if _, found := router.pick(300); found {
+		t.Errorf("expected no node for height 300")
+	}
+
+	if latestHeight := router.latestHeight(); latestHeight != 200 {
+		t.Errorf("expected latest height 200, got %d", latestHeight)
+	}
+}
+
+func TestRpcRouterPickRoundRobin(t *testing.T) {
+	first := newRpcServer(t, 1, 100)
+	second := newRpcServer(t, 1, 100)
+
+	router := newRpcRouter([]string{first.URL, second.URL})
+
+	picked := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		node, found := router.pick(50)
+		if !found {
+			t.Fatalf("expected a node for height 50")
+		}
+		picked[node.endpoint]++
+	}
+
+	if picked[first.URL] != 2 || picked[second.URL] != 2 {
+		t.Errorf("expected the nodes to be picked alternately, got %v", picked)
+	}
+}
+
+func TestRpcRouterReportFailureDropsNode(t *testing.T) {
+	first := newRpcServer(t, 1, 100)
+	second := newRpcServer(t, 1, 100)
+
+	router := newRpcRouter([]string{first.URL, second.URL})
+
+	for i := 0; i < utils.RpcNodeMaxFailures-1; i++ {
+		router.reportFailure(first.URL, fmt.Errorf("connection refused"))
+	}
+
+	// a success resets the failures in a row
+	router.reportSuccess(first.URL)
+
+	for i := 0; i < utils.RpcNodeMaxFailures-1; i++ {
+		router.reportFailure(first.URL, fmt.Errorf("connection refused"))
+	}
+
+	if !nodePicked(router, first.URL) {
+		t.Fatalf("expected the node to stay in the rotation below the maximum failures")
+	}
+
+	router.reportFailure(first.URL, fmt.Errorf("connection refused"))
+
+	if nodePicked(router, first.URL) {
+		t.Fatalf("expected the node to be dropped after %d failures", utils.RpcNodeMaxFailures)
+	}
+
+	// the node responds to the status request again
+	router.refresh()
+
+	if !nodePicked(router, first.URL) {
+		t.Fatalf("expected the node to be added back to the rotation")
+	}
+}
+
+// nodePicked returns whether the endpoint gets picked within one round of the rotation
+func nodePicked(router *rpcRouter, endpoint string) bool {
+	for range router.nodes {
+		if node, found := router.pick(50); found && node.endpoint == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRetryDelay(t *testing.T) {
+	for failures, expected := range map[int]int64{
+		0:   int64(utils.RpcRetryBaseDelay),
+		1:   int64(utils.RpcRetryBaseDelay),
+		2:   int64(2 * utils.RpcRetryBaseDelay),
+		3:   int64(4 * utils.RpcRetryBaseDelay),
+		100: int64(utils.RpcRetryMaxDelay),
+	} {
+		if delay := retryDelay(failures); int64(delay) != expected {
+			t.Errorf("expected delay %d after %d failures, got %d", expected, failures, delay)
+		}
+	}
+}
+
+func TestRpcRouterExhausted(t *testing.T) {
+	lower := newRpcServer(t, 1, 100)
+	upper := newRpcServer(t, 50, 200)
+
+	router := newRpcRouter([]string{lower.URL, upper.URL})
+	failures := make(blockFailures)
+
+	failures[lower.URL] = utils.RpcBlockMaxFailures
+
+	if router.exhausted(75, failures) {
+		t.Errorf("expected the upper node to still be tried for height 75")
+	}
+
+	if !router.exhausted(25, failures) {
+		t.Errorf("expected height 25 to be exhausted once the only node covering it failed")
+	}
+
+	// the failures for a height are kept even if the node reports a healthy status again
+	router.refresh()
+	failures[upper.URL] = utils.RpcBlockMaxFailures
+
+	if !router.exhausted(75, failures) {
+		t.Errorf("expected height 75 to be exhausted once all nodes covering it failed")
+	}
+}
@@ -0,0 +1,409 @@
+package blocks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rpcBatch is a range of block heights which gets downloaded by one of the rpc workers.
+// The result channel is buffered so workers never block on it
+type rpcBatch struct {
+	start  int64
+	end    int64
+	result chan rpcBatchResult
+}
+
+type rpcBatchResult struct {
+	items []types.DataItem
+	err   error
+}
+
+// rpcRequest is a single request of a JSON-RPC batch request
+type rpcRequest struct {
+	JsonRpc string            `json:"jsonrpc"`
+	Id      int64             `json:"id"`
+	Method  string            `json:"method"`
+	Params  map[string]string `json:"params"`
+}
+
+// rpcResponse is a single response of a JSON-RPC batch response, only the fields
+// needed for validation are decoded, the raw response is passed on to the engine
+type rpcResponse struct {
+	Id    int64 `json:"id"`
+	Error *struct {
+		Code    int64  `json:"code"`
+		Message string `json:"message"`
+		Data    string `json:"data"`
+	} `json:"error"`
+}
+
+// rpcFetcher downloads blocks from the block rpcs with a bounded number of workers
+// while still handing them out in order of their heights. If a rpc supports
+// JSON-RPC batch requests multiple blocks are requested at once
+type rpcFetcher struct {
+	blockRpcConfig types.BlockRpcConfig
+	router         *rpcRouter
+	batchSize      int64
+	limiter        <-chan time.Time
+	jobs           chan rpcBatch
+	ordered        chan rpcBatch
+	done           chan struct{}
+	once           sync.Once
+}
+
+func newRpcFetcher(blockRpcConfig types.BlockRpcConfig) *rpcFetcher {
+	concurrency, batchSize := blockRpcConfig.Concurrency, blockRpcConfig.BatchSize
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	fetcher := &rpcFetcher{
+		blockRpcConfig: blockRpcConfig,
+		router:         getRpcRouter(blockRpcConfig.Endpoints),
+		batchSize:      batchSize,
+		jobs:           make(chan rpcBatch),
+		ordered:        make(chan rpcBatch, concurrency),
+		done:           make(chan struct{}),
+	}
+
+	// the rate limit applies to all workers together
+	if blockRpcConfig.RateLimit > 0 {
+		// the ticker panics on a zero interval, which a rate limit above
+		// one request per nanosecond would round down to
+		interval := max(time.Second/time.Duration(blockRpcConfig.RateLimit), time.Nanosecond)
+
+		ticker := time.NewTicker(interval)
+		go func() {
+			<-fetcher.done
+			ticker.Stop()
+		}()
+		fetcher.limiter = ticker.C
+	}
+
+	for i := int64(0); i < concurrency; i++ {
+		go fetcher.startWorker()
+	}
+
+	return fetcher
+}
+
+// wait blocks until the rate limit allows the next request. It returns false if the fetcher was stopped
+func (fetcher *rpcFetcher) wait() bool {
+	if fetcher.limiter == nil {
+		return true
+	}
+
+	select {
+	case <-fetcher.done:
+		return false
+	case <-fetcher.limiter:
+		return true
+	}
+}
+
+func (fetcher *rpcFetcher) startWorker() {
+	for {
+		select {
+		case <-fetcher.done:
+			return
+		case batch := <-fetcher.jobs:
+			items, err := fetcher.fetch(batch)
+			batch.result <- rpcBatchResult{items: items, err: err}
+		}
+	}
+}
+
+// fetch downloads all blocks of the batch. Every part of the batch is requested from a node
+// which has it, if a node fails the request is retried on another node after a short delay
+func (fetcher *rpcFetcher) fetch(batch rpcBatch) ([]types.DataItem, error) {
+	items := make([]types.DataItem, 0, batch.end-batch.start+1)
+	height, retries, failures := batch.start, 0, 0
+	nodeFailures := make(blockFailures)
+
+	for height <= batch.end {
+		node, ok := fetcher.router.pick(height)
+		if !ok {
+			// no node in the rotation has the block, wait and check
+			// if dropped nodes are available again
+			if retries >= utils.BackoffMaxRetries {
+				return nil, fmt.Errorf("no block rpc can serve block with height %d", height)
+			}
+
+			delaySec := math.Pow(2, float64(retries))
+			logger.Error().Msg(fmt.Sprintf("no block rpc can serve block with height %d, retrying in %d seconds", height, int(delaySec)))
+
+			select {
+			case <-fetcher.done:
+				return nil, fmt.Errorf("block rpc fetcher stopped")
+			case <-time.After(time.Duration(delaySec) * time.Second):
+			}
+
+			fetcher.router.refresh()
+			retries++
+			continue
+		}
+
+		end := min(batch.end, node.latestHeight)
+
+		nodeItems, err := fetcher.fetchFromNode(node, height, end)
+		if err != nil {
+			fetcher.router.reportFailure(node.endpoint, err)
+			failures++
+			nodeFailures[node.endpoint]++
+
+			if fetcher.router.exhausted(height, nodeFailures) {
+				return nil, fmt.Errorf("all block rpcs failed %d times to serve block with height %d: %w", utils.RpcBlockMaxFailures, height, err)
+			}
+
+			select {
+			case <-fetcher.done:
+				return nil, fmt.Errorf("block rpc fetcher stopped")
+			case <-time.After(retryDelay(failures)):
+			}
+			continue
+		}
+
+		fetcher.router.reportSuccess(node.endpoint)
+
+		items = append(items, nodeItems...)
+		height, retries, failures = end+1, 0, 0
+		nodeFailures = make(blockFailures)
+	}
+
+	return items, nil
+}
+
+// fetchFromNode downloads all blocks from start to end (inclusive) from a single node
+func (fetcher *rpcFetcher) fetchFromNode(node rpcNode, start, end int64) ([]types.DataItem, error) {
+	if node.supportsBatch && start < end {
+		if !fetcher.wait() {
+			return nil, fmt.Errorf("block rpc fetcher stopped")
+		}
+
+		items, err := retrieveBlocksFromRpcBatch(node.endpoint, start, end)
+		time.Sleep(fetcher.blockRpcConfig.RequestTimeout)
+		return items, err
+	}
+
+	items := make([]types.DataItem, 0, end-start+1)
+
+	for height := start; height <= end; height++ {
+		if !fetcher.wait() {
+			return nil, fmt.Errorf("block rpc fetcher stopped")
+		}
+
+		dataItem, err := retrieveBlockFromEndpoint(node.endpoint, height)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, *dataItem)
+		time.Sleep(fetcher.blockRpcConfig.RequestTimeout)
+	}
+
+	return items, nil
+}
+
+// retrieveBlocksFromRpcBatch requests all blocks from start to end (inclusive) with a single JSON-RPC
+// batch request. Every response has the same format as the response of /block?height=N
+func retrieveBlocksFromRpcBatch(endpoint string, start, end int64) ([]types.DataItem, error) {
+	logger.Info().Msg(fmt.Sprintf("downloading blocks from height %d to %d", start, end))
+
+	requests := make([]rpcRequest, 0, end-start+1)
+	for height := start; height <= end; height++ {
+		requests = append(requests, rpcRequest{
+			JsonRpc: "2.0",
+			Id:      height,
+			Method:  "block",
+			Params:  map[string]string{"height": strconv.FormatInt(height, 10)},
+		})
+	}
+
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal batch request: %w", err)
+	}
+
+	result, err := utils.PostToUrlWithOptions(endpoint, body, utils.GetFromUrlOptions{
+		SkipTLSVerification: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rawResponses []json.RawMessage
+	if err := json.Unmarshal(result, &rawResponses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch response: %w", err)
+	}
+
+	// responses of a batch request are allowed to be in any order
+	values := make(map[int64][]byte)
+
+	for _, rawResponse := range rawResponses {
+		var response rpcResponse
+		if err := json.Unmarshal(rawResponse, &response); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal batch response: %w", err)
+		}
+
+		if response.Error != nil {
+			return nil, fmt.Errorf("failed to get block with height %d: %s %s", response.Id, response.Error.Message, response.Error.Data)
+		}
+
+		values[response.Id] = rawResponse
+	}
+
+	items := make([]types.DataItem, 0, len(requests))
+	for height := start; height <= end; height++ {
+		value, ok := values[height]
+		if !ok {
+			return nil, fmt.Errorf("batch response is missing block with height %d", height)
+		}
+
+		items = append(items, types.DataItem{
+			Key:   strconv.FormatInt(height, 10),
+			Value: value,
+		})
+	}
+
+	return items, nil
+}
+
+// schedule splits the heights starting from the continuation height into batches and queues
+// them for the workers. Batches are never scheduled beyond the latest height of the block rpcs
+func (fetcher *rpcFetcher) schedule(continuationHeight, targetHeight int64, mustExit bool) {
+	height, latestHeight, retries := continuationHeight, fetcher.router.latestHeight(), 0
+
+	for {
+		// the block after the target height is needed to apply the target height
+		if mustExit && targetHeight > 0 && height > targetHeight+1 {
+			close(fetcher.ordered)
+			return
+		}
+
+		if height > latestHeight {
+			fetcher.router.refresh()
+			latestHeight = fetcher.router.latestHeight()
+
+			// all nodes are dropped from the rotation if none of them is reachable
+			if latestHeight == 0 {
+				if retries >= utils.BackoffMaxRetries {
+					fetcher.fail(fmt.Errorf("failed to reach any block rpc"))
+					return
+				}
+				retries++
+			} else {
+				retries = 0
+			}
+
+			// wait for new blocks if we reached the latest height
+			if height > latestHeight {
+				select {
+				case <-fetcher.done:
+					return
+				case <-time.After(5 * time.Second):
+				}
+				continue
+			}
+		}
+
+		end := min(height+fetcher.batchSize-1, latestHeight)
+		if mustExit && targetHeight > 0 {
+			end = min(end, targetHeight+1)
+		}
+
+		batch := rpcBatch{
+			start:  height,
+			end:    end,
+			result: make(chan rpcBatchResult, 1),
+		}
+
+		// queue the batch first so the consumer always receives the blocks in order
+		select {
+		case <-fetcher.done:
+			return
+		case fetcher.ordered <- batch:
+		}
+
+		select {
+		case <-fetcher.done:
+			return
+		case fetcher.jobs <- batch:
+		}
+
+		height = end + 1
+	}
+}
+
+// fail queues an error which the consumer receives after all previously scheduled batches
+func (fetcher *rpcFetcher) fail(err error) {
+	batch := rpcBatch{result: make(chan rpcBatchResult, 1)}
+	batch.result <- rpcBatchResult{err: err}
+
+	select {
+	case <-fetcher.done:
+	case fetcher.ordered <- batch:
+	}
+}
+
+// stop terminates all workers, batches which are still in flight are dropped
+func (fetcher *rpcFetcher) stop() {
+	fetcher.once.Do(func() {
+		close(fetcher.done)
+	})
+}
+
+// startBlockCollectorFromRpc starts the block collector from the block rpcs (together they must cover all blocks)
+func startBlockCollectorFromRpc(ctx context.Context, itemCh chan<- types.DataItem, errorCh chan<- error, blockRpcConfig types.BlockRpcConfig, continuationHeight, targetHeight int64, mustExit bool) {
+	// blocks are downloaded in parallel ahead of time, but are always
+	// received here in the order of their heights
+	fetcher := newRpcFetcher(blockRpcConfig)
+	defer fetcher.stop()
+
+	go fetcher.schedule(continuationHeight, targetHeight, mustExit)
+
+	for {
+		var batch rpcBatch
+		var ok bool
+
+		select {
+		case <-ctx.Done():
+			return
+		case batch, ok = <-fetcher.ordered:
+		}
+
+		if !ok {
+			return
+		}
+
+		var result rpcBatchResult
+
+		select {
+		case <-ctx.Done():
+			return
+		case result = <-batch.result:
+		}
+
+		if result.err != nil {
+			sendError(ctx, errorCh, fmt.Errorf("failed to get block from rpc: %w", result.err))
+			return
+		}
+
+		for _, dataItem := range result.items {
+			select {
+			case <-ctx.Done():
+				return
+			case itemCh <- dataItem:
+			}
+		}
+	}
+}
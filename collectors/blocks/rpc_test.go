@@ -0,0 +1,19 @@
+package blocks
+
+import (
+	"github.com/KYVENetwork/ksync/types"
+	"testing"
+	"time"
+)
+
+func TestRpcFetcherRateLimitAboveOnePerNanosecond(t *testing.T) {
+	server := newRpcServer(t, 1, 100)
+
+	// the interval of the rate limit would be rounded down to zero
+	fetcher := newRpcFetcher(types.BlockRpcConfig{Endpoints: []string{server.URL}, RateLimit: 2 * int64(time.Second)})
+	defer fetcher.stop()
+
+	if !fetcher.wait() {
+		t.Errorf("expected the rate limit to allow the next request")
+	}
+}
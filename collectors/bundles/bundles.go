@@ -1,18 +1,23 @@
 package bundles
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
+	"github.com/KYVENetwork/ksync/collectors/storage"
+	"github.com/KYVENetwork/ksync/metrics"
 	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 	"github.com/tendermint/tendermint/libs/json"
+	"io"
 	"strconv"
+	"time"
 )
 
-func GetFinalizedBundlesPageWithOffset(restEndpoint string, poolId int64, paginationLimit, paginationOffset int64, paginationKey string, reverse bool) ([]types.FinalizedBundle, string, error) {
-	raw, err := utils.GetFromUrlWithBackoff(fmt.Sprintf(
-		"%s/kyve/v1/bundles/%d?pagination.limit=%d&pagination.offset=%d&pagination.key=%s&pagination.reverse=%v",
-		restEndpoint,
+func GetFinalizedBundlesPageWithOffset(chainRest *utils.EndpointGroup, poolId int64, paginationLimit, paginationOffset int64, paginationKey string, reverse bool) ([]types.FinalizedBundle, string, error) {
+	raw, err := chainRest.Get(fmt.Sprintf(
+		"/kyve/v1/bundles/%d?pagination.limit=%d&pagination.offset=%d&pagination.key=%s&pagination.reverse=%v",
 		poolId,
 		paginationLimit,
 		paginationOffset,
@@ -34,14 +39,13 @@ func GetFinalizedBundlesPageWithOffset(restEndpoint string, poolId int64, pagina
 	return bundlesResponse.FinalizedBundles, nextKey, nil
 }
 
-func GetFinalizedBundlesPage(restEndpoint string, poolId int64, paginationLimit int64, paginationKey string, reverse bool) ([]types.FinalizedBundle, string, error) {
-	return GetFinalizedBundlesPageWithOffset(restEndpoint, poolId, paginationLimit, 0, paginationKey, reverse)
+func GetFinalizedBundlesPage(chainRest *utils.EndpointGroup, poolId int64, paginationLimit int64, paginationKey string, reverse bool) ([]types.FinalizedBundle, string, error) {
+	return GetFinalizedBundlesPageWithOffset(chainRest, poolId, paginationLimit, 0, paginationKey, reverse)
 }
 
-func GetFinalizedBundleById(restEndpoint string, poolId int64, bundleId int64) (*types.FinalizedBundle, error) {
-	raw, err := utils.GetFromUrlWithBackoff(fmt.Sprintf(
-		"%s/kyve/v1/bundles/%d/%d",
-		restEndpoint,
+func GetFinalizedBundleById(chainRest *utils.EndpointGroup, poolId int64, bundleId int64) (*types.FinalizedBundle, error) {
+	raw, err := chainRest.Get(fmt.Sprintf(
+		"/kyve/v1/bundles/%d/%d",
 		poolId,
 		bundleId,
 	))
@@ -58,10 +62,9 @@ func GetFinalizedBundleById(restEndpoint string, poolId int64, bundleId int64) (
 	return &finalizedBundle, nil
 }
 
-func GetFinalizedBundleByIndex(restEndpoint string, poolId int64, index int64) (*types.FinalizedBundle, error) {
-	raw, err := utils.GetFromUrlWithBackoff(fmt.Sprintf(
-		"%s/kyve/v1/bundles/%d?index=%d",
-		restEndpoint,
+func GetFinalizedBundleByIndex(chainRest *utils.EndpointGroup, poolId int64, index int64) (*types.FinalizedBundle, error) {
+	raw, err := chainRest.Get(fmt.Sprintf(
+		"/kyve/v1/bundles/%d?index=%d",
 		poolId,
 		index,
 	))
@@ -82,26 +85,56 @@ func GetFinalizedBundleByIndex(restEndpoint string, poolId int64, index int64) (
 	return nil, fmt.Errorf("failed to find finalized bundle for index %d: %w", index, err)
 }
 
-func GetFinalizedBundleForBlockHeight(chainRest string, blockPool types.PoolResponse, height int64) (*types.FinalizedBundle, error) {
+// GetFinalizedBundleForBlockHeight returns the finalized bundle containing the given block height. The bundle
+// is looked up in the local bundle index first, only if the height is not indexed yet the KYVE REST is queried
+func GetFinalizedBundleForBlockHeight(chainRest *utils.EndpointGroup, blockPool types.PoolResponse, height int64) (*types.FinalizedBundle, error) {
+	index, err := GetBundleIndex(chainRest, blockPool.Pool.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bundle index: %w", err)
+	}
+
+	if finalizedBundle, found := index.Lookup(height); found {
+		return finalizedBundle, nil
+	}
+
 	startKey, err := strconv.ParseInt(blockPool.Pool.Data.StartKey, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse start key %s: %w", blockPool.Pool.Data.StartKey, err)
 	}
 
-	// index is height - startKey
-	return GetFinalizedBundleByIndex(chainRest, blockPool.Pool.Id, height-startKey)
+	// index is height - startKey if every bundle index holds exactly one block
+	finalizedBundle, err := GetFinalizedBundleByIndex(chainRest, blockPool.Pool.Id, height-startKey)
+	if err == nil && IsHeightInBundle(*finalizedBundle, height) {
+		if err := index.Add([]types.FinalizedBundle{*finalizedBundle}); err != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to add finalized bundle to index: %s", err))
+		}
+		return finalizedBundle, nil
+	}
+
+	// if the bundle can not be found that way it is searched by its bundle id
+	return index.Find(chainRest, blockPool.Pool.Data.TotalBundles, height)
 }
 
-func GetDataFromFinalizedBundle(bundle types.FinalizedBundle, storageRest string) ([]byte, error) {
-	// retrieve bundle from storage provider
-	data, err := RetrieveDataFromStorageProvider(bundle, storageRest)
+// IsHeightInBundle checks if the block height lies between the from and to key of the bundle
+func IsHeightInBundle(finalizedBundle types.FinalizedBundle, height int64) bool {
+	fromHeight, err := utils.ParseBlockHeightFromKey(finalizedBundle.FromKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve data from storage provider with storage id %s: %w", bundle.StorageId, err)
+		return false
 	}
 
-	// validate bundle with sha256 checksum
-	if utils.CreateSha256Checksum(data) != bundle.DataHash {
-		return nil, fmt.Errorf("found different sha256 checksum on bundle with storage id %s: expected = %s found = %s", bundle.StorageId, utils.CreateSha256Checksum(data), bundle.DataHash)
+	toHeight, err := utils.ParseBlockHeightFromKey(finalizedBundle.ToKey)
+	if err != nil {
+		return false
+	}
+
+	return fromHeight <= height && height <= toHeight
+}
+
+func GetDataFromFinalizedBundle(ctx context.Context, bundle types.FinalizedBundle, storageRest string) ([]byte, error) {
+	// retrieve bundle from cache or storage provider
+	data, err := GetRawDataFromFinalizedBundle(ctx, bundle, storageRest)
+	if err != nil {
+		return nil, err
 	}
 
 	// decompress bundle
@@ -113,40 +146,96 @@ func GetDataFromFinalizedBundle(bundle types.FinalizedBundle, storageRest string
 	return deflated, nil
 }
 
-func RetrieveDataFromStorageProvider(bundle types.FinalizedBundle, storageRest string) ([]byte, error) {
+// GetStreamFromFinalizedBundle returns a reader which decompresses the bundle data on the fly,
+// only the compressed bundle is held in memory
+func GetStreamFromFinalizedBundle(ctx context.Context, bundle types.FinalizedBundle, storageRest string) (io.ReadCloser, error) {
+	data, err := GetRawDataFromFinalizedBundle(ctx, bundle, storageRest)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := DecompressBundleStreamFromStorageProvider(bundle, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress bundle: %w", err)
+	}
+
+	return reader, nil
+}
+
+// GetRawDataFromFinalizedBundle returns the compressed bundle data from the bundle cache if it is available
+// there, else it gets downloaded from the storage provider, validated and stored in the cache
+func GetRawDataFromFinalizedBundle(ctx context.Context, bundle types.FinalizedBundle, storageRest string) ([]byte, error) {
+	if bundleCache != nil {
+		if data, found := bundleCache.Get(bundle.DataHash); found {
+			logger.Info().Msg(fmt.Sprintf("loaded bundle with storage id %s from cache", bundle.StorageId))
+			return data, nil
+		}
+	}
+
+	// retrieve bundle from storage provider
+	data, err := RetrieveDataFromStorageProvider(ctx, bundle, storageRest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve data from storage provider with storage id %s: %w", bundle.StorageId, err)
+	}
+
+	// validate bundle with sha256 checksum
+	if utils.CreateSha256Checksum(data) != bundle.DataHash {
+		return nil, fmt.Errorf("found different sha256 checksum on bundle with storage id %s: expected = %s found = %s", bundle.StorageId, bundle.DataHash, utils.CreateSha256Checksum(data))
+	}
+
+	if bundleCache != nil {
+		if err := bundleCache.Put(bundle.DataHash, data); err != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to store bundle with storage id %s in cache: %s", bundle.StorageId, err))
+		}
+	}
+
+	return data, nil
+}
+
+func RetrieveDataFromStorageProvider(ctx context.Context, bundle types.FinalizedBundle, storageRest string) ([]byte, error) {
 	id, err := strconv.ParseUint(bundle.StorageProviderId, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse uint from storage provider id: %w", err)
 	}
 
-	if storageRest != "" {
-		return utils.GetFromUrlWithBackoff(fmt.Sprintf("%s/%s", storageRest, bundle.StorageId))
+	provider, err := storage.GetStorageProvider(id, storageRest)
+	if err != nil {
+		return nil, err
 	}
 
-	switch id {
-	case 1:
-		return utils.GetFromUrlWithBackoff(fmt.Sprintf("%v/%s", utils.RestEndpointArweave, bundle.StorageId))
-	case 2:
-		return utils.GetFromUrlWithBackoff(fmt.Sprintf("%v/%s", utils.RestEndpointBundlr, bundle.StorageId))
-	case 3:
-		return utils.GetFromUrlWithBackoff(fmt.Sprintf("%v/%s", utils.RestEndpointKYVEStorage, bundle.StorageId))
-	case 4:
-		return utils.GetFromUrlWithBackoff(fmt.Sprintf("%v/%s", utils.RestEndpointTurboStorage, bundle.StorageId))
-	default:
-		return nil, fmt.Errorf("bundle has an invalid storage provider id %s. canceling sync", bundle.StorageProviderId)
+	start := time.Now()
+
+	data, err := provider.RetrieveBundle(ctx, bundle.StorageId)
+	if err != nil {
+		return nil, err
 	}
+
+	metrics.ObserveBundleDownload(provider.GetName(), len(data), time.Since(start))
+	return data, nil
 }
 
 func DecompressBundleFromStorageProvider(bundle types.FinalizedBundle, data []byte) ([]byte, error) {
+	reader, err := DecompressBundleStreamFromStorageProvider(bundle, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// DecompressBundleStreamFromStorageProvider returns a reader which decompresses the raw bundle
+// data on the fly with the compression used by the bundle
+func DecompressBundleStreamFromStorageProvider(bundle types.FinalizedBundle, data io.Reader) (io.ReadCloser, error) {
 	id, err := strconv.ParseUint(bundle.CompressionId, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse uint from compression id: %w", err)
 	}
 
-	switch id {
-	case 1:
-		return utils.DecompressGzip(data)
-	default:
-		return nil, fmt.Errorf("bundle has an invalid compression id %s. canceling sync", bundle.CompressionId)
+	reader, err := utils.NewDecompressionReader(id, data)
+	if err != nil {
+		return nil, fmt.Errorf("bundle has an invalid compression id %s: %w", bundle.CompressionId, err)
 	}
+
+	return reader, nil
 }
@@ -0,0 +1,274 @@
+package bundles
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	logger = utils.KsyncLogger("bundles")
+
+	// bundleCache is nil if no bundle cache was initialized, in this case
+	// all bundles are always downloaded from the storage provider
+	bundleCache *BundleCache
+)
+
+// BundleCache is a content-addressed on-disk cache for the raw bundle data returned
+// by the storage providers. Bundles are stored under their data hash, so the same
+// bundle is only stored once and can be shared between all sync runs. If the cache
+// exceeds its maximum size the least recently used bundles get evicted.
+type BundleCache struct {
+	mu      sync.Mutex
+	dir     string
+	maxSize int64
+
+	// the size and the last use of the cached bundles are tracked in memory, the lock
+	// is only held for this bookkeeping and not while bundles are read or written
+	entries map[string]*cacheEntry
+	size    int64
+	clock   int64
+}
+
+type cacheEntry struct {
+	size     int64
+	lastUsed int64
+}
+
+// InitBundleCache enables the bundle cache for all following bundle retrievals. If
+// cacheDir is empty the default cache directory in the .ksync directory is used
+func InitBundleCache(cacheDir string, maxSizeMB int64) error {
+	if cacheDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("could not find home directory: %w", err)
+		}
+
+		cacheDir = filepath.Join(home, ".ksync", "cache")
+	}
+
+	cache, err := NewBundleCache(cacheDir, maxSizeMB*1024*1024)
+	if err != nil {
+		return err
+	}
+
+	bundleCache = cache
+
+	logger.Info().Msg(fmt.Sprintf("using bundle cache in %s with a maximum size of %d MB", cacheDir, maxSizeMB))
+	return nil
+}
+
+// NewBundleCache opens the bundle cache in the given directory with a maximum size in bytes.
+// The bundles which are already in the cache are ordered by their modification time
+func NewBundleCache(dir string, maxSize int64) (*BundleCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create cache directory %s: %w", dir, err)
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	files := make([]os.FileInfo, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !isValidDataHash(dirEntry.Name()) {
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, info)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	cache := &BundleCache{
+		dir:     dir,
+		maxSize: maxSize,
+		entries: make(map[string]*cacheEntry, len(files)),
+	}
+
+	for _, file := range files {
+		cache.touch(file.Name(), file.Size())
+	}
+
+	cache.removeFiles(cache.evict())
+	return cache, nil
+}
+
+// isValidDataHash checks that the data hash is a hex encoded sha256 checksum, so it
+// can safely be used as file name in the cache directory
+func isValidDataHash(dataHash string) bool {
+	if len(dataHash) != 64 {
+		return false
+	}
+
+	for _, c := range dataHash {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (cache *BundleCache) path(dataHash string) string {
+	return filepath.Join(cache.dir, dataHash)
+}
+
+// touch marks the bundle as most recently used, the lock has to be held by the caller
+func (cache *BundleCache) touch(dataHash string, size int64) {
+	cache.clock++
+
+	if entry, ok := cache.entries[dataHash]; ok {
+		cache.size += size - entry.size
+		entry.size = size
+		entry.lastUsed = cache.clock
+		return
+	}
+
+	cache.entries[dataHash] = &cacheEntry{size: size, lastUsed: cache.clock}
+	cache.size += size
+}
+
+// forget removes the bundle from the bookkeeping, the lock has to be held by the caller
+func (cache *BundleCache) forget(dataHash string) {
+	if entry, ok := cache.entries[dataHash]; ok {
+		cache.size -= entry.size
+		delete(cache.entries, dataHash)
+	}
+}
+
+// evict removes the least recently used bundles from the bookkeeping until the cache fits
+// into its maximum size again and returns their data hashes, the lock has to be held by
+// the caller
+func (cache *BundleCache) evict() []string {
+	if cache.size <= cache.maxSize {
+		return nil
+	}
+
+	dataHashes := make([]string, 0, len(cache.entries))
+	for dataHash := range cache.entries {
+		dataHashes = append(dataHashes, dataHash)
+	}
+
+	sort.Slice(dataHashes, func(i, j int) bool {
+		return cache.entries[dataHashes[i]].lastUsed < cache.entries[dataHashes[j]].lastUsed
+	})
+
+	var evicted []string
+	for _, dataHash := range dataHashes {
+		if cache.size <= cache.maxSize {
+			break
+		}
+
+		cache.forget(dataHash)
+		evicted = append(evicted, dataHash)
+	}
+
+	return evicted
+}
+
+func (cache *BundleCache) removeFiles(dataHashes []string) {
+	for _, dataHash := range dataHashes {
+		if err := os.Remove(cache.path(dataHash)); err != nil && !os.IsNotExist(err) {
+			logger.Error().Msg(fmt.Sprintf("failed to evict bundle %s from cache: %s", dataHash, err))
+		}
+	}
+}
+
+// Get loads the bundle data with the given data hash from the cache. Bundles which
+// do not match their data hash anymore are removed from the cache
+func (cache *BundleCache) Get(dataHash string) ([]byte, bool) {
+	if !isValidDataHash(dataHash) {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(cache.path(dataHash))
+	if err != nil {
+		cache.mu.Lock()
+		cache.forget(dataHash)
+		cache.mu.Unlock()
+		return nil, false
+	}
+
+	if utils.CreateSha256Checksum(data) != dataHash {
+		logger.Error().Msg(fmt.Sprintf("found corrupted bundle with data hash %s in cache, removing it", dataHash))
+
+		cache.mu.Lock()
+		cache.forget(dataHash)
+		cache.mu.Unlock()
+
+		_ = os.Remove(cache.path(dataHash))
+		return nil, false
+	}
+
+	// the bundle may have been stored by another sync run, so it is also added
+	// to the bookkeeping here
+	cache.mu.Lock()
+	cache.touch(dataHash, int64(len(data)))
+	evicted := cache.evict()
+	cache.mu.Unlock()
+
+	cache.removeFiles(evicted)
+
+	// the modification time orders the bundles when the cache gets opened again
+	now := time.Now()
+	_ = os.Chtimes(cache.path(dataHash), now, now)
+
+	return data, true
+}
+
+// Put stores the bundle data under the given data hash and evicts the least recently
+// used bundles if the cache exceeds its maximum size
+func (cache *BundleCache) Put(dataHash string, data []byte) error {
+	if !isValidDataHash(dataHash) {
+		return fmt.Errorf("invalid data hash %s", dataHash)
+	}
+
+	if int64(len(data)) > cache.maxSize {
+		return nil
+	}
+
+	// write to a temporary file first so no partially written bundles
+	// end up in the cache
+	tmp, err := os.CreateTemp(cache.dir, fmt.Sprintf("%s-*.tmp", dataHash))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary cache file: %w", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write temporary cache file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to close temporary cache file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), cache.path(dataHash)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to move bundle into cache: %w", err)
+	}
+
+	cache.mu.Lock()
+	cache.touch(dataHash, int64(len(data)))
+	evicted := cache.evict()
+	cache.mu.Unlock()
+
+	cache.removeFiles(evicted)
+	return nil
+}
@@ -0,0 +1,145 @@
+package bundles
+
+import (
+	"bytes"
+	"github.com/KYVENetwork/ksync/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func putBundle(t *testing.T, cache *BundleCache, data []byte) string {
+	t.Helper()
+
+	dataHash := utils.CreateSha256Checksum(data)
+	if err := cache.Put(dataHash, data); err != nil {
+		t.Fatalf("failed to put bundle: %s", err)
+	}
+
+	return dataHash
+}
+
+func isCached(cache *BundleCache, dataHash string) bool {
+	_, err := os.Stat(cache.path(dataHash))
+	return err == nil
+}
+
+func TestBundleCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache, err := NewBundleCache(t.TempDir(), 30)
+	if err != nil {
+		t.Fatalf("failed to create cache: %s", err)
+	}
+
+	first := putBundle(t, cache, bytes.Repeat([]byte("a"), 10))
+	second := putBundle(t, cache, bytes.Repeat([]byte("b"), 10))
+	third := putBundle(t, cache, bytes.Repeat([]byte("c"), 10))
+
+	// reading the first bundle makes the second one the least recently used
+	if _, found := cache.Get(first); !found {
+		t.Fatalf("expected first bundle to be cached")
+	}
+
+	fourth := putBundle(t, cache, bytes.Repeat([]byte("d"), 10))
+
+	if isCached(cache, second) {
+		t.Errorf("expected second bundle to be evicted")
+	}
+
+	for _, dataHash := range []string{first, third, fourth} {
+		if !isCached(cache, dataHash) {
+			t.Errorf("expected bundle %s to be cached", dataHash)
+		}
+	}
+
+	if cache.size != 30 {
+		t.Errorf("expected cache size of 30, got %d", cache.size)
+	}
+}
+
+func TestBundleCacheSkipsBundlesLargerThanCache(t *testing.T) {
+	cache, err := NewBundleCache(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("failed to create cache: %s", err)
+	}
+
+	dataHash := putBundle(t, cache, bytes.Repeat([]byte("a"), 11))
+
+	if isCached(cache, dataHash) {
+		t.Errorf("expected bundle larger than the cache to be skipped")
+	}
+}
+
+func TestBundleCacheRemovesCorruptedBundles(t *testing.T) {
+	cache, err := NewBundleCache(t.TempDir(), 1024)
+	if err != nil {
+		t.Fatalf("failed to create cache: %s", err)
+	}
+
+	data := []byte("bundle data")
+	dataHash := putBundle(t, cache, data)
+
+	if cached, found := cache.Get(dataHash); !found || !bytes.Equal(cached, data) {
+		t.Fatalf("expected bundle to be read back from cache")
+	}
+
+	if err := os.WriteFile(cache.path(dataHash), []byte("corrupted"), 0o644); err != nil {
+		t.Fatalf("failed to corrupt bundle: %s", err)
+	}
+
+	if _, found := cache.Get(dataHash); found {
+		t.Errorf("expected corrupted bundle not to be returned")
+	}
+
+	if isCached(cache, dataHash) {
+		t.Errorf("expected corrupted bundle to be removed")
+	}
+
+	if cache.size != 0 {
+		t.Errorf("expected cache size of 0, got %d", cache.size)
+	}
+}
+
+func TestBundleCacheRejectsInvalidDataHashes(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := NewBundleCache(filepath.Join(dir, "cache"), 1024)
+	if err != nil {
+		t.Fatalf("failed to create cache: %s", err)
+	}
+
+	if err := cache.Put("../outside", []byte("data")); err == nil {
+		t.Errorf("expected invalid data hash to be rejected")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "outside")); err == nil {
+		t.Errorf("expected no file outside of the cache directory")
+	}
+
+	if _, found := cache.Get("../outside"); found {
+		t.Errorf("expected invalid data hash not to be found")
+	}
+}
+
+func TestBundleCacheLoadsExistingBundles(t *testing.T) {
+	dir := t.TempDir()
+
+	cache, err := NewBundleCache(dir, 1024)
+	if err != nil {
+		t.Fatalf("failed to create cache: %s", err)
+	}
+
+	dataHash := putBundle(t, cache, []byte("bundle data"))
+
+	reopened, err := NewBundleCache(dir, 1024)
+	if err != nil {
+		t.Fatalf("failed to reopen cache: %s", err)
+	}
+
+	if reopened.size != int64(len("bundle data")) {
+		t.Errorf("expected cache size of %d, got %d", len("bundle data"), reopened.size)
+	}
+
+	if _, found := reopened.Get(dataHash); !found {
+		t.Errorf("expected bundle to be found after reopening the cache")
+	}
+}
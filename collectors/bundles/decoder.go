@@ -0,0 +1,117 @@
+package bundles
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/types"
+	"io"
+)
+
+// skippedValue is used to step over data item values which are not needed
+// without allocating or parsing them
+type skippedValue struct{}
+
+func (*skippedValue) UnmarshalJSON([]byte) error {
+	return nil
+}
+
+// BundleDecoder decodes the data items of a decompressed bundle one at a time
+// directly from the stream, so a bundle never has to be fully held in memory
+type BundleDecoder struct {
+	decoder *json.Decoder
+	started bool
+}
+
+func NewBundleDecoder(reader io.Reader) *BundleDecoder {
+	return &BundleDecoder{
+		decoder: json.NewDecoder(reader),
+	}
+}
+
+func (bundleDecoder *BundleDecoder) expectDelim(delim json.Delim) error {
+	token, err := bundleDecoder.decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected \"%s\" but found \"%v\"", delim, token)
+	}
+
+	return nil
+}
+
+// Next returns the next data item of the bundle and io.EOF once all data items were read.
+// If skip returns true for the key of a data item its value is not decoded and the item
+// is skipped entirely
+func (bundleDecoder *BundleDecoder) Next(skip func(key string) bool) (*types.DataItem, error) {
+	if !bundleDecoder.started {
+		if err := bundleDecoder.expectDelim('['); err != nil {
+			return nil, fmt.Errorf("failed to read bundle start: %w", err)
+		}
+		bundleDecoder.started = true
+	}
+
+	for bundleDecoder.decoder.More() {
+		if err := bundleDecoder.expectDelim('{'); err != nil {
+			return nil, fmt.Errorf("failed to read data item start: %w", err)
+		}
+
+		var item types.DataItem
+		hasKey, skipped := false, false
+
+		for bundleDecoder.decoder.More() {
+			token, err := bundleDecoder.decoder.Token()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read data item field: %w", err)
+			}
+
+			switch token {
+			case "key":
+				if err := bundleDecoder.decoder.Decode(&item.Key); err != nil {
+					return nil, fmt.Errorf("failed to decode data item key: %w", err)
+				}
+
+				hasKey = true
+				skipped = skip != nil && skip(item.Key)
+			case "value":
+				// the value can only be skipped if the key was already read,
+				// else we have to keep it until we know the key
+				if hasKey && skipped {
+					if err := bundleDecoder.decoder.Decode(&skippedValue{}); err != nil {
+						return nil, fmt.Errorf("failed to skip data item value: %w", err)
+					}
+					continue
+				}
+
+				if err := bundleDecoder.decoder.Decode(&item.Value); err != nil {
+					return nil, fmt.Errorf("failed to decode data item value: %w", err)
+				}
+			default:
+				if err := bundleDecoder.decoder.Decode(&skippedValue{}); err != nil {
+					return nil, fmt.Errorf("failed to skip data item field %v: %w", token, err)
+				}
+			}
+		}
+
+		if err := bundleDecoder.expectDelim('}'); err != nil {
+			return nil, fmt.Errorf("failed to read data item end: %w", err)
+		}
+
+		if !hasKey {
+			return nil, fmt.Errorf("found data item without key")
+		}
+
+		if skipped {
+			continue
+		}
+
+		return &item, nil
+	}
+
+	if err := bundleDecoder.expectDelim(']'); err != nil {
+		return nil, fmt.Errorf("failed to read bundle end: %w", err)
+	}
+
+	return nil, io.EOF
+}
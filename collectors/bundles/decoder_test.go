@@ -0,0 +1,79 @@
+package bundles
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBundleDecoderDecodesItemsInOrder(t *testing.T) {
+	// the value of the second item comes before its key and unknown fields are ignored
+	bundle := `[
+		{"key": "1", "value": {"block": 1}},
+		{"value": {"block": 2}, "key": "2", "unknown": [1, 2]},
+		{"key": "3", "value": {"block": 3}}
+	]`
+
+	decoder := NewBundleDecoder(strings.NewReader(bundle))
+
+	for _, expected := range []string{"1", "2", "3"} {
+		item, err := decoder.Next(nil)
+		if err != nil {
+			t.Fatalf("failed to decode item %s: %s", expected, err)
+		}
+
+		if item.Key != expected {
+			t.Fatalf("expected item %s, got item %s", expected, item.Key)
+		}
+
+		if string(item.Value) != `{"block": `+expected+`}` {
+			t.Errorf("expected value of item %s, got %s", expected, item.Value)
+		}
+	}
+
+	if _, err := decoder.Next(nil); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF after the last item, got %v", err)
+	}
+}
+
+func TestBundleDecoderSkipsItems(t *testing.T) {
+	bundle := `[
+		{"key": "1", "value": {"block": 1}},
+		{"value": {"block": 2}, "key": "2"},
+		{"key": "3", "value": "invalid"},
+		{"key": "4", "value": {"block": 4}}
+	]`
+
+	decoder := NewBundleDecoder(strings.NewReader(bundle))
+
+	skip := func(key string) bool {
+		return key != "4"
+	}
+
+	item, err := decoder.Next(skip)
+	if err != nil {
+		t.Fatalf("failed to decode item: %s", err)
+	}
+
+	if item.Key != "4" {
+		t.Fatalf("expected items before item 4 to be skipped, got item %s", item.Key)
+	}
+
+	if _, err := decoder.Next(skip); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF after the last item, got %v", err)
+	}
+}
+
+func TestBundleDecoderRejectsInvalidBundles(t *testing.T) {
+	for name, bundle := range map[string]string{
+		"no array":     `{"key": "1", "value": {}}`,
+		"missing key":  `[{"value": {}}]`,
+		"invalid item": `["1"]`,
+		"truncated":    `[{"key": "1", "value": {"block"`,
+	} {
+		if _, err := NewBundleDecoder(strings.NewReader(bundle)).Next(nil); err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("expected bundle with %s to be rejected, got %v", name, err)
+		}
+	}
+}
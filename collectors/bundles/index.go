@@ -0,0 +1,381 @@
+package bundles
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var (
+	bundleIndexesMu = sync.Mutex{}
+
+	// bundleIndexes contains the already loaded bundle indexes by their file path
+	bundleIndexes = map[string]*BundleIndex{}
+
+	// bundleIndexChainId is the id of the KYVE chain the indexed pools belong to
+	bundleIndexChainId string
+)
+
+// InitBundleIndex sets the KYVE chain of the indexed pools. Pool ids are only unique per
+// chain, so the indexes are separated by the chain id and not by the endpoint they were
+// built from, which changes between runs for local mirrors or custom endpoints
+func InitBundleIndex(chainId string) {
+	bundleIndexesMu.Lock()
+	defer bundleIndexesMu.Unlock()
+
+	bundleIndexChainId = chainId
+}
+
+// indexEntry is a finalized bundle together with its parsed block range
+type indexEntry struct {
+	id     int64
+	from   int64
+	to     int64
+	bundle types.FinalizedBundle
+}
+
+// BundleIndex is a locally persisted index of the finalized bundles of a block pool which maps
+// block heights to the bundles containing them. The index can contain any ranges of bundles, so
+// a sync which starts in the middle of the pool only indexes the bundles it actually requested.
+// The index is stored as JSON lines, so new bundles can simply be appended.
+type BundleIndex struct {
+	mu      sync.Mutex
+	path    string
+	poolId  int64
+	entries map[int64]indexEntry
+
+	// ids are the ids of the indexed bundles in ascending order
+	ids []int64
+}
+
+// GetBundleIndex loads the bundle index of the block pool on the given chain. Indexes are stored
+// in the .ksync directory and are shared between all sync runs
+func GetBundleIndex(chainRest *utils.EndpointGroup, poolId int64) (*BundleIndex, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("could not find home directory: %w", err)
+	}
+
+	bundleIndexesMu.Lock()
+	defer bundleIndexesMu.Unlock()
+
+	// without a chain id the indexes are separated by the chain endpoint
+	chain := bundleIndexChainId
+	if chain == "" {
+		chain = chainRest.Primary()
+		if u, err := url.Parse(chain); err == nil && u.Host != "" {
+			chain = u.Host
+		}
+	}
+	chain = strings.NewReplacer("/", "_", ":", "_").Replace(chain)
+
+	path := filepath.Join(home, ".ksync", "index", chain, fmt.Sprintf("%d.jsonl", poolId))
+
+	if index, ok := bundleIndexes[path]; ok {
+		return index, nil
+	}
+
+	index, err := loadBundleIndex(path, poolId)
+	if err != nil {
+		return nil, err
+	}
+
+	bundleIndexes[path] = index
+	return index, nil
+}
+
+func newIndexEntry(bundle types.FinalizedBundle) (indexEntry, error) {
+	id, err := strconv.ParseInt(bundle.Id, 10, 64)
+	if err != nil {
+		return indexEntry{}, fmt.Errorf("failed to parse bundle id %s: %w", bundle.Id, err)
+	}
+
+	from, err := utils.ParseBlockHeightFromKey(bundle.FromKey)
+	if err != nil {
+		return indexEntry{}, fmt.Errorf("failed to parse from key %s: %w", bundle.FromKey, err)
+	}
+
+	to, err := utils.ParseBlockHeightFromKey(bundle.ToKey)
+	if err != nil {
+		return indexEntry{}, fmt.Errorf("failed to parse to key %s: %w", bundle.ToKey, err)
+	}
+
+	if to < from {
+		return indexEntry{}, fmt.Errorf("found to key %s below from key %s on bundle %s", bundle.ToKey, bundle.FromKey, bundle.Id)
+	}
+
+	return indexEntry{id: id, from: from, to: to, bundle: bundle}, nil
+}
+
+// lockFile locks the index file exclusively, so multiple sync runs which share the
+// index never interleave their appends
+func lockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
+
+func loadBundleIndex(path string, poolId int64) (*BundleIndex, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("could not create index directory: %w", err)
+	}
+
+	index := &BundleIndex{path: path, poolId: poolId, entries: map[int64]indexEntry{}}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle index: %w", err)
+	}
+	defer file.Close()
+
+	if err := lockFile(file); err != nil {
+		return nil, fmt.Errorf("failed to lock bundle index: %w", err)
+	}
+
+	// only the valid prefix of the index is used, an interrupted
+	// write can only ever corrupt the last line
+	var valid int64
+	reader := bufio.NewReader(file)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+
+		var bundle types.FinalizedBundle
+		if err := json.Unmarshal(line, &bundle); err != nil {
+			break
+		}
+
+		entry, err := newIndexEntry(bundle)
+		if err != nil {
+			break
+		}
+
+		index.insert(entry)
+		valid += int64(len(line))
+	}
+
+	if err := file.Truncate(valid); err != nil {
+		return nil, fmt.Errorf("failed to truncate bundle index: %w", err)
+	}
+
+	return index, nil
+}
+
+// insert adds the entry to the index if it is not indexed yet, the lock has to be held by the caller
+func (index *BundleIndex) insert(entry indexEntry) bool {
+	if _, ok := index.entries[entry.id]; ok {
+		return false
+	}
+
+	index.entries[entry.id] = entry
+
+	i := sort.Search(len(index.ids), func(i int) bool {
+		return index.ids[i] > entry.id
+	})
+
+	index.ids = append(index.ids, 0)
+	copy(index.ids[i+1:], index.ids[i:])
+	index.ids[i] = entry.id
+
+	return true
+}
+
+// Add appends the finalized bundles to the index, bundles which are already indexed are ignored
+func (index *BundleIndex) Add(finalizedBundles []types.FinalizedBundle) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	var lines []byte
+
+	for _, bundle := range finalizedBundles {
+		entry, err := newIndexEntry(bundle)
+		if err != nil {
+			return err
+		}
+
+		if !index.insert(entry) {
+			continue
+		}
+
+		line, err := json.Marshal(bundle)
+		if err != nil {
+			return fmt.Errorf("failed to marshal finalized bundle: %w", err)
+		}
+
+		lines = append(append(lines, line...), '\n')
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	file, err := os.OpenFile(index.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle index: %w", err)
+	}
+	defer file.Close()
+
+	if err := lockFile(file); err != nil {
+		return fmt.Errorf("failed to lock bundle index: %w", err)
+	}
+
+	if _, err := file.Write(lines); err != nil {
+		return fmt.Errorf("failed to write bundle index: %w", err)
+	}
+
+	return nil
+}
+
+// lookup returns the entry which contains the given height, the lock has to be held by the caller
+func (index *BundleIndex) lookup(height int64) (indexEntry, bool) {
+	if len(index.ids) == 0 {
+		return indexEntry{}, false
+	}
+
+	// the bundles of a pool usually all have the same size, so the bundle id
+	// is derived from the height first before the index gets searched
+	first := index.entries[index.ids[0]]
+	if size := first.to - first.from + 1; size > 0 && height >= first.from {
+		if entry, ok := index.entries[first.id+(height-first.from)/size]; ok && entry.from <= height && height <= entry.to {
+			return entry, true
+		}
+	}
+
+	i := sort.Search(len(index.ids), func(i int) bool {
+		return index.entries[index.ids[i]].to >= height
+	})
+
+	if i == len(index.ids) || index.entries[index.ids[i]].from > height {
+		return indexEntry{}, false
+	}
+
+	return index.entries[index.ids[i]], true
+}
+
+// Lookup returns the finalized bundle which contains the block with the given height
+func (index *BundleIndex) Lookup(height int64) (*types.FinalizedBundle, bool) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	entry, found := index.lookup(height)
+	if !found {
+		return nil, false
+	}
+
+	return &entry.bundle, true
+}
+
+// Bundles returns the indexed finalized bundles which directly follow each other starting
+// from the given bundle id
+func (index *BundleIndex) Bundles(fromId int64) []types.FinalizedBundle {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	var finalizedBundles []types.FinalizedBundle
+	for id := fromId; ; id++ {
+		entry, ok := index.entries[id]
+		if !ok {
+			return finalizedBundles
+		}
+		finalizedBundles = append(finalizedBundles, entry.bundle)
+	}
+}
+
+// searchRange returns the range of bundle ids which can contain the given height based on
+// the indexed bundles and the bundle id which most likely contains it
+func (index *BundleIndex) searchRange(height, totalBundles int64) (lo, hi, guess int64) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	lo, hi = 0, totalBundles-1
+	var prev, next *indexEntry
+
+	for _, id := range index.ids {
+		entry := index.entries[id]
+		if entry.to < height {
+			prev = &entry
+			lo = id + 1
+		} else if entry.from > height {
+			next = &entry
+			hi = id - 1
+			break
+		}
+	}
+
+	guess = lo + (hi-lo)/2
+
+	// with bundles of the same size the neighbouring bundles tell which bundle contains the height
+	if prev != nil {
+		guess = prev.id + floorDiv(height-prev.from, prev.to-prev.from+1)
+	} else if next != nil {
+		guess = next.id + floorDiv(height-next.from, next.to-next.from+1)
+	}
+
+	return lo, hi, min(max(guess, lo), hi)
+}
+
+// floorDiv divides and rounds towards negative infinity, so heights below a bundle
+// resolve to the bundle which contains them
+func floorDiv(a, b int64) int64 {
+	if a%b != 0 && (a < 0) != (b < 0) {
+		return a/b - 1
+	}
+	return a / b
+}
+
+// Find returns the finalized bundle which contains the block with the given height. If the height
+// is not indexed yet the bundle is searched by its id among the finalized bundles of the pool,
+// starting with the id derived from the neighbouring indexed bundles. All requested bundles are
+// added to the index, so the pool never has to be walked from the first bundle
+func (index *BundleIndex) Find(chainRest *utils.EndpointGroup, totalBundles, height int64) (*types.FinalizedBundle, error) {
+	if finalizedBundle, found := index.Lookup(height); found {
+		return finalizedBundle, nil
+	}
+
+	lo, hi, guess := index.searchRange(height, totalBundles)
+
+	for attempt := 0; lo <= hi; attempt++ {
+		finalizedBundle, err := GetFinalizedBundleById(chainRest, index.poolId, guess)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get finalized bundle %d: %w", guess, err)
+		}
+
+		entry, err := newIndexEntry(*finalizedBundle)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := index.Add([]types.FinalizedBundle{*finalizedBundle}); err != nil {
+			return nil, fmt.Errorf("failed to add finalized bundle to index: %w", err)
+		}
+
+		switch {
+		case height < entry.from:
+			hi = entry.id - 1
+		case height > entry.to:
+			lo = entry.id + 1
+		default:
+			return finalizedBundle, nil
+		}
+
+		// the next guess is derived from the size of the requested bundle, every other
+		// guess halves the range so the number of requests stays logarithmic
+		guess = lo + (hi-lo)/2
+		if attempt%2 == 0 {
+			guess = min(max(entry.id+floorDiv(height-entry.from, entry.to-entry.from+1), lo), hi)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to find finalized bundle for block height %d", height)
+}
@@ -0,0 +1,193 @@
+package bundles
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newFinalizedBundle returns bundle id of a pool in which every bundle contains ten blocks
+func newFinalizedBundle(id int64) types.FinalizedBundle {
+	return types.FinalizedBundle{
+		Id:      fmt.Sprintf("%d", id),
+		FromKey: fmt.Sprintf("%d", id*10+1),
+		ToKey:   fmt.Sprintf("%d", id*10+10),
+	}
+}
+
+// newBundlesServer serves the finalized bundles of pool 1 by their id
+func newBundlesServer(t *testing.T, requests *atomic.Int64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/kyve/v1/bundles/1/"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(newFinalizedBundle(id))
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestBundleIndexMidPoolStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.jsonl")
+
+	index, err := loadBundleIndex(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a sync starting in the middle of the pool adds pages which do not start at bundle zero
+	page := make([]types.FinalizedBundle, 0, 10)
+	for id := int64(500); id < 510; id++ {
+		page = append(page, newFinalizedBundle(id))
+	}
+
+	if err := index.Add(page); err != nil {
+		t.Fatal(err)
+	}
+
+	if indexed := index.Bundles(500); len(indexed) != 10 {
+		t.Fatalf("expected 10 bundles starting at bundle 500, got %d", len(indexed))
+	}
+
+	finalizedBundle, found := index.Lookup(5055)
+	if !found || finalizedBundle.Id != "505" {
+		t.Fatalf("expected height 5055 to be in bundle 505, got %v", finalizedBundle)
+	}
+
+	if _, found := index.Lookup(4000); found {
+		t.Fatalf("expected height 4000 not to be indexed")
+	}
+
+	reloaded, err := loadBundleIndex(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if indexed := reloaded.Bundles(500); len(indexed) != 10 {
+		t.Fatalf("expected 10 bundles after reloading the index, got %d", len(indexed))
+	}
+}
+
+func TestBundleIndexFindDerivesBundleIdFromHeight(t *testing.T) {
+	var requests atomic.Int64
+	server := newBundlesServer(t, &requests)
+
+	index, err := loadBundleIndex(filepath.Join(t.TempDir(), "1.jsonl"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := index.Add([]types.FinalizedBundle{newFinalizedBundle(500)}); err != nil {
+		t.Fatal(err)
+	}
+
+	finalizedBundle, err := index.Find(utils.NewEndpointGroup([]string{server.URL}), 100_000, 800_005)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if finalizedBundle.Id != "80000" {
+		t.Fatalf("expected bundle 80000, got bundle %s", finalizedBundle.Id)
+	}
+
+	if requests.Load() != 1 {
+		t.Fatalf("expected the bundle id to be derived from the indexed bundle, got %d requests", requests.Load())
+	}
+
+	if _, found := index.Lookup(800_005); !found {
+		t.Fatalf("expected the found bundle to be indexed")
+	}
+}
+
+func TestBundleIndexFindSearchesEmptyIndex(t *testing.T) {
+	var requests atomic.Int64
+	server := newBundlesServer(t, &requests)
+
+	index, err := loadBundleIndex(filepath.Join(t.TempDir(), "1.jsonl"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finalizedBundle, err := index.Find(utils.NewEndpointGroup([]string{server.URL}), 100_000, 123_456)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if finalizedBundle.Id != "12345" {
+		t.Fatalf("expected bundle 12345, got bundle %s", finalizedBundle.Id)
+	}
+
+	if requests.Load() > 3 {
+		t.Fatalf("expected the search to use the size of the first requested bundle, got %d requests", requests.Load())
+	}
+}
+
+func TestBundleIndexTruncatesCorruptedTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "1.jsonl")
+
+	var valid []byte
+	for id := int64(0); id < 2; id++ {
+		line, err := json.Marshal(newFinalizedBundle(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid = append(append(valid, line...), '\n')
+	}
+
+	// an interrupted append leaves a partially written last line behind
+	partial, err := json.Marshal(newFinalizedBundle(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, append(valid, partial[:len(partial)/2]...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := loadBundleIndex(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if indexed := index.Bundles(0); len(indexed) != 2 {
+		t.Fatalf("expected 2 bundles, got %d", len(indexed))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != string(valid) {
+		t.Fatalf("expected index to be truncated to the valid lines, got %q", data)
+	}
+
+	// bundles added after the truncation start on a new line
+	if err := index.Add([]types.FinalizedBundle{newFinalizedBundle(2)}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := loadBundleIndex(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if indexed := reloaded.Bundles(0); len(indexed) != 3 {
+		t.Fatalf("expected 3 bundles after reloading the index, got %d", len(indexed))
+	}
+}
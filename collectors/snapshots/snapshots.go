@@ -11,12 +11,12 @@ import (
 // FindNearestSnapshotBundleIdByHeight takes a targetHeight and returns the bundle id with the according snapshot
 // height of the available snapshot. If no complete snapshot is available at the targetHeight this method returns
 // the bundleId and snapshotHeight of the nearest snapshot below the targetHeight.
-func FindNearestSnapshotBundleIdByHeight(restEndpoint string, poolId int64, targetHeight int64) (snapshotBundleId int64, snapshotHeight int64, err error) {
+func FindNearestSnapshotBundleIdByHeight(chainRest *utils.EndpointGroup, poolId int64, targetHeight int64) (snapshotBundleId int64, snapshotHeight int64, err error) {
 	paginationKey := ""
 
 	for {
 		// we iterate in reverse through the pages since mostly live snapshots at the end of the bundles range are used
-		bundlesPage, nextKey, pageErr := bundles.GetFinalizedBundlesPage(restEndpoint, poolId, utils.BundlesPageLimit, paginationKey, true)
+		bundlesPage, nextKey, pageErr := bundles.GetFinalizedBundlesPage(chainRest, poolId, utils.BundlesPageLimit, paginationKey, true)
 		if pageErr != nil {
 			return snapshotBundleId, snapshotHeight, fmt.Errorf("failed to retrieve finalized bundles: %w", pageErr)
 		}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"strings"
+)
+
+// FailoverProvider retrieves bundles from multiple storage endpoints serving the
+// same bundles. Requests go to the healthiest endpoint and immediately fail over
+// to the next one on errors
+type FailoverProvider struct {
+	group     *utils.EndpointGroup
+	providers map[string]StorageProvider
+}
+
+func newFailoverProvider(endpoints []string) (*FailoverProvider, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no storage endpoint specified")
+	}
+
+	providers := make(map[string]StorageProvider)
+
+	for _, endpoint := range endpoints {
+		provider, err := newStorageProviderFromEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		providers[endpoint] = provider
+	}
+
+	return &FailoverProvider{
+		group:     utils.NewEndpointGroup(endpoints),
+		providers: providers,
+	}, nil
+}
+
+func (provider *FailoverProvider) GetName() string {
+	var names []string
+	for _, endpoint := range provider.group.GetEndpoints() {
+		names = append(names, provider.providers[endpoint].GetName())
+	}
+
+	return strings.Join(names, ",")
+}
+
+func (provider *FailoverProvider) RetrieveBundle(ctx context.Context, storageId string) ([]byte, error) {
+	return provider.group.Do(ctx, func(endpoint string) ([]byte, error) {
+		return provider.providers[endpoint].RetrieveBundle(ctx, storageId)
+	})
+}
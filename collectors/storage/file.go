@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// FileProvider retrieves bundles from a local directory where every bundle
+// is stored in a file named after its storage id
+type FileProvider struct {
+	dir string
+}
+
+func NewFileProvider(dir string) *FileProvider {
+	return &FileProvider{dir: dir}
+}
+
+func newFileProviderFromUrl(endpoint *url.URL) (StorageProvider, error) {
+	// support relative paths like "file://bundles" where the
+	// first path element is parsed as the host
+	dir := filepath.Join(endpoint.Host, endpoint.Path)
+	if dir == "" {
+		return nil, fmt.Errorf("no directory specified")
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find bundle directory %s: %w", dir, err)
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	return NewFileProvider(dir), nil
+}
+
+func (provider *FileProvider) GetName() string {
+	return "file"
+}
+
+func (provider *FileProvider) RetrieveBundle(ctx context.Context, storageId string) ([]byte, error) {
+	// storage ids are never allowed to escape the bundle directory
+	if storageId != filepath.Base(storageId) {
+		return nil, fmt.Errorf("invalid storage id %s", storageId)
+	}
+
+	return os.ReadFile(filepath.Join(provider.dir, storageId))
+}
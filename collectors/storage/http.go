@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"net/url"
+	"strings"
+)
+
+// HttpProvider retrieves bundles from plain HTTP gateways like arweave.net
+// where the bundle is served under <endpoint>/<storage-id>
+type HttpProvider struct {
+	name        string
+	endpoint    string
+	withBackoff bool
+}
+
+func NewHttpProvider(name, endpoint string) *HttpProvider {
+	return &HttpProvider{
+		name:        name,
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		withBackoff: true,
+	}
+}
+
+func newHttpProviderFromUrl(endpoint *url.URL) (StorageProvider, error) {
+	// custom endpoints are always used through a failover provider
+	// which takes care of retries, so we only try once here
+	provider := NewHttpProvider(endpoint.Host, endpoint.String())
+	provider.withBackoff = false
+	return provider, nil
+}
+
+func (provider *HttpProvider) GetName() string {
+	return provider.name
+}
+
+func (provider *HttpProvider) RetrieveBundle(ctx context.Context, storageId string) ([]byte, error) {
+	return utils.GetFromUrlWithContext(ctx, fmt.Sprintf("%s/%s", provider.endpoint, storageId), utils.GetFromUrlOptions{
+		SkipTLSVerification: true,
+		WithBackoff:         provider.withBackoff,
+	})
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpProviderCancelsBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	// the first retry would only happen after one second
+	if _, err := NewHttpProvider("test", server.URL).RetrieveBundle(ctx, "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the retrieval to be canceled, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the backoff to be canceled, took %s", elapsed)
+	}
+}
+
+func TestHttpProviderCancelsRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	if _, err := NewHttpProvider("test", server.URL).RetrieveBundle(ctx, "1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the download to be canceled, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// S3Provider retrieves bundles from an S3-compatible object store. The storage
+// endpoint has the format "s3://<bucket>/<prefix>" and can be configured with the
+// query parameters "endpoint", "region" and "path-style", e.g.
+// "s3://bundles/osmosis?endpoint=http://localhost:9000&path-style=true" for a local
+// MinIO instance. Credentials are loaded from the default AWS credential chain
+// (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, ~/.aws/credentials, ...)
+type S3Provider struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func newS3ProviderFromUrl(endpoint *url.URL) (StorageProvider, error) {
+	if endpoint.Host == "" {
+		return nil, fmt.Errorf("no bucket specified")
+	}
+
+	query := endpoint.Query()
+
+	region := query.Get("region")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load s3 config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(options *s3.Options) {
+		if e := query.Get("endpoint"); e != "" {
+			options.BaseEndpoint = aws.String(e)
+		}
+
+		options.UsePathStyle = query.Get("path-style") == "true"
+	})
+
+	return &S3Provider{
+		client: client,
+		bucket: endpoint.Host,
+		prefix: strings.Trim(endpoint.Path, "/"),
+	}, nil
+}
+
+func (provider *S3Provider) GetName() string {
+	return "s3"
+}
+
+func (provider *S3Provider) RetrieveBundle(ctx context.Context, storageId string) ([]byte, error) {
+	key := path.Join(provider.prefix, storageId)
+
+	object, err := provider.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(provider.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s from bucket %s: %w", key, provider.bucket, err)
+	}
+	defer object.Body.Close()
+
+	return io.ReadAll(object.Body)
+}
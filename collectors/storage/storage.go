@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"net/url"
+	"sync"
+)
+
+// StorageProvider is an interface defining common behaviour for each backend
+// KSYNC can retrieve the raw bundle data from
+type StorageProvider interface {
+	// GetName gets the name of the storage provider
+	GetName() string
+
+	// RetrieveBundle retrieves the raw (still compressed) bundle data
+	// stored under the storage id
+	RetrieveBundle(ctx context.Context, storageId string) ([]byte, error)
+}
+
+// StorageProviderFactory creates a storage provider from a custom storage
+// endpoint like "s3://bucket/prefix" or "file:///path/to/bundles"
+type StorageProviderFactory func(endpoint *url.URL) (StorageProvider, error)
+
+var (
+	mu = sync.Mutex{}
+
+	// providers contains the storage providers which are registered on KYVE,
+	// they get selected by the storage provider id of the finalized bundle
+	providers = map[uint64]StorageProvider{
+		1: NewHttpProvider("arweave", utils.RestEndpointArweave),
+		2: NewHttpProvider("bundlr", utils.RestEndpointBundlr),
+		3: NewHttpProvider("kyve-storage", utils.RestEndpointKYVEStorage),
+		4: NewHttpProvider("turbo", utils.RestEndpointTurboStorage),
+	}
+
+	// factories contains the storage provider backends which can be selected
+	// by the uri scheme of a custom storage endpoint
+	factories = map[string]StorageProviderFactory{
+		"http":  newHttpProviderFromUrl,
+		"https": newHttpProviderFromUrl,
+		"file":  newFileProviderFromUrl,
+		"s3":    newS3ProviderFromUrl,
+	}
+
+	// endpoints caches the storage providers created for custom storage
+	// endpoints, so clients, sessions and endpoint health can be reused
+	endpoints = map[string]StorageProvider{}
+)
+
+// RegisterStorageProvider registers a storage provider for the given KYVE storage provider id
+func RegisterStorageProvider(storageProviderId uint64, provider StorageProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	providers[storageProviderId] = provider
+}
+
+// RegisterStorageProviderFactory registers a storage provider backend for the given uri scheme
+func RegisterStorageProviderFactory(scheme string, factory StorageProviderFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	factories[scheme] = factory
+}
+
+// GetStorageProvider returns the storage provider for a finalized bundle. If custom storage
+// endpoints are given (comma separated) the backends are selected by their uri scheme, else
+// the storage provider which is registered for the storage provider id of the bundle is used
+func GetStorageProvider(storageProviderId uint64, storageRest string) (StorageProvider, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if storageRest == "" {
+		provider, ok := providers[storageProviderId]
+		if !ok {
+			return nil, fmt.Errorf("bundle has an invalid storage provider id %d. canceling sync", storageProviderId)
+		}
+
+		return provider, nil
+	}
+
+	if provider, ok := endpoints[storageRest]; ok {
+		return provider, nil
+	}
+
+	provider, err := newFailoverProvider(utils.ParseEndpoints(storageRest))
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints[storageRest] = provider
+	return provider, nil
+}
+
+// newStorageProviderFromEndpoint creates the storage provider backend for a single custom storage endpoint
+func newStorageProviderFromEndpoint(storageRest string) (StorageProvider, error) {
+	endpoint, err := url.Parse(storageRest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse storage endpoint %s: %w", storageRest, err)
+	}
+
+	factory, ok := factories[endpoint.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("storage endpoint %s has an unsupported scheme \"%s\"", storageRest, endpoint.Scheme)
+	}
+
+	provider, err := factory(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage provider for %s: %w", storageRest, err)
+	}
+
+	return provider, nil
+}
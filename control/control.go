@@ -0,0 +1,234 @@
+package control
+
+import (
+	"context"
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"sync"
+	"time"
+)
+
+const (
+	PhaseIdle      = "idle"
+	PhaseBootstrap = "bootstrap"
+	PhaseStateSync = "state-sync"
+	PhaseBlockSync = "block-sync"
+	PhaseBackup    = "backup"
+)
+
+var (
+	logger = utils.KsyncLogger("control")
+
+	mu     sync.Mutex
+	status = Status{Phase: PhaseIdle, RecentErrors: []SyncError{}}
+
+	// resumeCh is closed once a paused sync gets resumed
+	resumeCh chan struct{}
+
+	// pendingTargetHeight is the target height which was requested but not yet picked up by the sync
+	pendingTargetHeight *int64
+
+	// validateTargetHeight checks if the running sync can change to the target height, nil
+	// if the running sync does not support changing the target height
+	validateTargetHeight func(targetHeight int64) error
+)
+
+func init() {
+	utils.SetErrorLogHandler(recordError)
+}
+
+// SyncError is an error which got logged during the sync
+type SyncError struct {
+	Time    time.Time `json:"time"`
+	Module  string    `json:"module"`
+	Message string    `json:"message"`
+}
+
+// Status describes what the running sync is currently doing
+type Status struct {
+	Phase          string      `json:"phase"`
+	Paused         bool        `json:"paused"`
+	Height         int64       `json:"height"`
+	TargetHeight   int64       `json:"target_height"`
+	Engine         string      `json:"engine"`
+	BlockPoolId    *int64      `json:"block_pool_id"`
+	SnapshotPoolId *int64      `json:"snapshot_pool_id"`
+	RecentErrors   []SyncError `json:"recent_errors"`
+}
+
+// GetStatus returns a copy of the current status
+func GetStatus() Status {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current := status
+	current.RecentErrors = append([]SyncError{}, status.RecentErrors...)
+	return current
+}
+
+func SetPhase(phase string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status.Phase = phase
+}
+
+func SetHeight(height int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status.Height = height
+}
+
+func SetTargetHeight(targetHeight int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status.TargetHeight = targetHeight
+}
+
+func SetEngine(engine string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status.Engine = engine
+}
+
+func SetBlockPoolId(blockPoolId *int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status.BlockPoolId = blockPoolId
+}
+
+func SetSnapshotPoolId(snapshotPoolId int64) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status.SnapshotPoolId = &snapshotPoolId
+}
+
+func recordError(module, message string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	status.RecentErrors = append(status.RecentErrors, SyncError{
+		Time:    time.Now(),
+		Module:  module,
+		Message: message,
+	})
+
+	if len(status.RecentErrors) > utils.ControlApiRecentErrors {
+		status.RecentErrors = status.RecentErrors[len(status.RecentErrors)-utils.ControlApiRecentErrors:]
+	}
+}
+
+// Pause pauses the sync before the next block or snapshot chunk gets applied
+func Pause() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !status.Paused {
+		status.Paused = true
+		resumeCh = make(chan struct{})
+	}
+}
+
+// Resume continues a paused sync
+func Resume() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if status.Paused {
+		status.Paused = false
+		close(resumeCh)
+	}
+}
+
+// WaitWhilePaused blocks as long as the sync is paused. It returns the error of the
+// context if the context gets canceled while waiting
+func WaitWhilePaused(ctx context.Context) error {
+	mu.Lock()
+	paused, resume, height := status.Paused, resumeCh, status.Height
+	mu.Unlock()
+
+	if !paused {
+		return nil
+	}
+
+	logger.Info().Msg(fmt.Sprintf("paused sync at height %d", height))
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resume:
+		logger.Info().Msg(fmt.Sprintf("resumed sync at height %d", height))
+		return nil
+	}
+}
+
+// EnableTargetHeightChange allows changing the target height of the running sync, the validate
+// function rejects target heights which the sync can not reach
+func EnableTargetHeightChange(validate func(targetHeight int64) error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	validateTargetHeight = validate
+}
+
+// ChangeTargetHeight requests a new target height, the sync picks it up before the next block.
+// A target height of zero is rejected, since a running sync has to stop at a height which is
+// available and the latest available height is only known to the validate function
+func ChangeTargetHeight(targetHeight int64) error {
+	mu.Lock()
+	validate, phase, height := validateTargetHeight, status.Phase, status.Height
+	mu.Unlock()
+
+	if validate == nil {
+		return fmt.Errorf("changing the target height is not supported in phase %s", phase)
+	}
+
+	if targetHeight <= 0 {
+		return fmt.Errorf("target height has to be greater than zero")
+	}
+
+	if targetHeight < height {
+		return fmt.Errorf("target height %d is below the current height %d", targetHeight, height)
+	}
+
+	// the validate callback and the logger run without holding the lock since the
+	// logger records errors in the status which would deadlock otherwise
+	if err := validate(targetHeight); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	pendingTargetHeight = &targetHeight
+	status.TargetHeight = targetHeight
+	mu.Unlock()
+
+	logger.Info().Msg(fmt.Sprintf("requested change of target height to %d", targetHeight))
+	return nil
+}
+
+// HasPendingTargetHeight returns whether a new target height was requested
+func HasPendingTargetHeight() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return pendingTargetHeight != nil
+}
+
+// TakePendingTargetHeight returns the requested target height and marks it as applied
+func TakePendingTargetHeight() (int64, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if pendingTargetHeight == nil {
+		return 0, false
+	}
+
+	targetHeight := *pendingTargetHeight
+	pendingTargetHeight = nil
+	return targetHeight, true
+}
@@ -0,0 +1,42 @@
+package control
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestChangeTargetHeight(t *testing.T) {
+	if err := ChangeTargetHeight(100); err == nil {
+		t.Errorf("expected target height change to be rejected while it is not enabled")
+	}
+
+	SetHeight(50)
+	EnableTargetHeightChange(func(targetHeight int64) error {
+		if targetHeight > 200 {
+			return fmt.Errorf("requested target height is %d but last available block on pool is 200", targetHeight)
+		}
+		return nil
+	})
+	t.Cleanup(func() {
+		EnableTargetHeightChange(nil)
+		TakePendingTargetHeight()
+	})
+
+	for _, targetHeight := range []int64{-1, 0, 49, 201} {
+		if err := ChangeTargetHeight(targetHeight); err == nil {
+			t.Errorf("expected target height %d to be rejected", targetHeight)
+		}
+	}
+
+	if HasPendingTargetHeight() {
+		t.Fatalf("expected no pending target height after rejected changes")
+	}
+
+	if err := ChangeTargetHeight(150); err != nil {
+		t.Fatalf("failed to change target height: %s", err)
+	}
+
+	if targetHeight, ok := TakePendingTargetHeight(); !ok || targetHeight != 150 {
+		t.Errorf("expected pending target height 150, got %d", targetHeight)
+	}
+}
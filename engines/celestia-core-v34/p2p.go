@@ -1,36 +1,33 @@
 package celestia_core_v34
 
 import (
-	"fmt"
 	bc "github.com/KYVENetwork/celestia-core/blockchain"
 	bcv0 "github.com/KYVENetwork/celestia-core/blockchain/v0"
 	tmLog "github.com/KYVENetwork/celestia-core/libs/log"
 	"github.com/KYVENetwork/celestia-core/p2p"
 	bcproto "github.com/KYVENetwork/celestia-core/proto/celestiacore/blockchain"
+	tmproto "github.com/KYVENetwork/celestia-core/proto/celestiacore/types"
 	"github.com/KYVENetwork/celestia-core/version"
-	log "github.com/KYVENetwork/ksync/utils"
-	"reflect"
+	"github.com/KYVENetwork/ksync/engines/core"
+	"github.com/gogo/protobuf/proto"
 )
 
 const (
 	BlockchainChannel = byte(0x40)
 )
 
-var (
-	logger = log.KsyncLogger("p2p")
-)
+// BlockPeer serves the block and the next block to the node
+type BlockPeer = core.BlockPeer[Block, PartSet, *tmproto.Block]
 
 type BlockchainReactor struct {
 	p2p.BaseReactor
 
-	block     *Block
-	nextBlock *Block
+	peer *BlockPeer
 }
 
-func NewBlockchainReactor(block *Block, nextBlock *Block) *BlockchainReactor {
+func NewBlockchainReactor(peer *BlockPeer) *BlockchainReactor {
 	bcR := &BlockchainReactor{
-		block:     block,
-		nextBlock: nextBlock,
+		peer: peer,
 	}
 	bcR.BaseReactor = *p2p.NewBaseReactor("BlockchainReactor", bcR)
 	return bcR
@@ -44,88 +41,43 @@ func (bcR *BlockchainReactor) GetChannels() []*p2p.ChannelDescriptor {
 			SendQueueCapacity:   1000,
 			RecvBufferCapacity:  50 * 4096,
 			RecvMessageCapacity: bc.MaxMsgSize,
-			MessageType:         &bcproto.Message{},
 		},
 	}
 }
 
-func (bcR *BlockchainReactor) sendStatusToPeer(src p2p.Peer) (queued bool) {
-	msgBytes, err := bc.EncodeMsg(&bcproto.StatusResponse{
-		Base:   bcR.block.Height,
-		Height: bcR.block.Height + 1})
-	if err != nil {
-		logger.Error().Str("could not convert msg to protobuf", err.Error())
-		return
-	}
-
-	logger.Info().Int64("base", bcR.block.Height).Int64("height", bcR.block.Height+1).Msg("Sent status to peer")
-
-	return src.Send(BlockchainChannel, msgBytes)
-}
-
-func (bcR *BlockchainReactor) sendBlockToPeer(msg *bcproto.BlockRequest, src p2p.Peer) (queued bool) {
-	if msg.Height == bcR.block.Height {
-		bl, err := bcR.block.ToProto()
-		if err != nil {
-			logger.Error().Str("could not convert msg to protobuf", err.Error())
-			return false
-		}
-
-		msgBytes, err := bc.EncodeMsg(&bcproto.BlockResponse{Block: bl})
-		if err != nil {
-			logger.Error().Str("could not marshal msg", err.Error())
-			return false
-		}
-
-		logger.Info().Msg(fmt.Sprintf("sent block with height %d to peer", bcR.block.Height))
-
-		return src.TrySend(BlockchainChannel, msgBytes)
-	}
-
-	if msg.Height == bcR.nextBlock.Height {
-		bl, err := bcR.nextBlock.ToProto()
-		if err != nil {
-			logger.Error().Str("could not convert msg to protobuf", err.Error())
-			return false
-		}
-
-		msgBytes, err := bc.EncodeMsg(&bcproto.BlockResponse{Block: bl})
-		if err != nil {
-			logger.Error().Str("could not marshal msg", err.Error())
-			return false
-		}
-
-		logger.Info().Msg(fmt.Sprintf("sent block with height %d to peer", bcR.nextBlock.Height))
-
-		return src.TrySend(BlockchainChannel, msgBytes)
-	}
-
-	logger.Error().Msg(fmt.Sprintf("peer asked for different block, expected = %d,%d, requested %d", bcR.block.Height, bcR.nextBlock.Height, msg.Height))
-	return false
-}
-
 func (bcR *BlockchainReactor) Receive(chID byte, src p2p.Peer, msgBytes []byte) {
 	msg, err := bc.DecodeMsg(msgBytes)
 	if err != nil {
-		logger.Error().Msgf("Error decoding message", fmt.Sprintf("src: %s", src), fmt.Sprintf("chId: %b", chID), err)
+		bcR.Logger.Error("Error decoding message", "src", src, "chId", chID, "err", err)
 		bcR.Switch.StopPeerForError(src, err)
 		return
 	}
 
 	switch msg := msg.(type) {
 	case *bcproto.StatusRequest:
-		logger.Info().Msg("Incoming status request")
-		bcR.sendStatusToPeer(src)
+		base, height := bcR.peer.Status()
+		bcR.send(src, &bcproto.StatusResponse{Base: base, Height: height})
 	case *bcproto.BlockRequest:
-		logger.Info().Int64("height", msg.Height).Msg("Incoming block request")
-		bcR.sendBlockToPeer(msg, src)
+		if bl, ok := bcR.peer.Block(msg.Height); ok {
+			bcR.send(src, &bcproto.BlockResponse{Block: bl})
+		}
 	case *bcproto.StatusResponse:
-		logger.Info().Int64("base", msg.Base).Int64("height", msg.Height).Msgf("Incoming status response")
+		bcR.peer.StatusResponse(msg.Base, msg.Height)
 	default:
-		logger.Error().Msg(fmt.Sprintf("Unknown message type %v", reflect.TypeOf(msg)))
+		bcR.peer.UnknownMessage(msg)
 	}
 }
 
+func (bcR *BlockchainReactor) send(src p2p.Peer, msg proto.Message) {
+	msgBytes, err := bc.EncodeMsg(msg)
+	if err != nil {
+		bcR.Logger.Error("could not marshal msg", "err", err)
+		return
+	}
+
+	src.TrySend(BlockchainChannel, msgBytes)
+}
+
 func MakeNodeInfo(
 	config *Config,
 	nodeKey *p2p.NodeKey,
@@ -0,0 +1,542 @@
+package cometbft_v1
+
+import (
+	"context"
+	"fmt"
+	"github.com/KYVENetwork/ksync/engines/core"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	db "github.com/cometbft/cometbft-db"
+	tmProtoState "github.com/cometbft/cometbft/api/cometbft/state/v1"
+	cfg "github.com/cometbft/cometbft/config"
+	"github.com/cometbft/cometbft/crypto"
+	"github.com/cometbft/cometbft/crypto/ed25519"
+	"github.com/cometbft/cometbft/libs/json"
+	"github.com/cometbft/cometbft/mempool"
+	cometP2P "github.com/cometbft/cometbft/p2p"
+	"github.com/cometbft/cometbft/privval"
+	"github.com/cometbft/cometbft/proxy"
+	rpccore "github.com/cometbft/cometbft/rpc/core"
+	rpcserver "github.com/cometbft/cometbft/rpc/jsonrpc/server"
+	tmState "github.com/cometbft/cometbft/state"
+	tmStore "github.com/cometbft/cometbft/store"
+	tmTypes "github.com/cometbft/cometbft/types"
+	"net"
+	"net/http"
+)
+
+var (
+	cometLogger = CometLogger()
+)
+
+type Engine = core.Engine[Block, PartSet]
+
+func NewEngine(engineConfig types.EngineConfig) *Engine {
+	return core.NewEngine[Block, PartSet](&Adapter{}, engineConfig)
+}
+
+// Adapter implements the parts of the engine which are specific to CometBFT v1
+type Adapter struct {
+	config *cfg.Config
+
+	blockDB    db.DB
+	blockStore *tmStore.BlockStore
+
+	stateDB    db.DB
+	stateStore tmState.Store
+
+	genDoc           *GenesisDoc
+	privValidatorKey crypto.PubKey
+
+	state         tmState.State
+	proxyApp      proxy.AppConns
+	mempool       *mempool.Mempool
+	evidencePool  tmState.EvidencePool
+	blockExecutor *tmState.BlockExecutor
+}
+
+func (adapter *Adapter) GetName() string {
+	return utils.EngineCometBFTV1
+}
+
+func (adapter *Adapter) LoadConfig(homePath string) (*core.Config, error) {
+	config, err := LoadConfig(homePath)
+	if err != nil {
+		return nil, err
+	}
+
+	adapter.config = config
+
+	return &core.Config{
+		ProxyApp:               config.ProxyApp,
+		AbciTransport:          config.ABCI,
+		GenesisFile:            config.GenesisFile(),
+		DBDir:                  config.DBDir(),
+		AddrBookFile:           config.P2P.AddrBookFile(),
+		NodeKeyFile:            config.NodeKeyFile(),
+		PrivValidatorKeyFile:   config.PrivValidatorKeyFile(),
+		PrivValidatorStateFile: config.PrivValidatorStateFile(),
+		P2PListenAddress:       config.P2P.ListenAddress,
+	}, nil
+}
+
+func (adapter *Adapter) LoadGenesis() (string, int64, error) {
+	genDoc, err := LoadGenesisDoc(adapter.config)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return genDoc.ChainID, genDoc.InitialHeight, nil
+}
+
+func (adapter *Adapter) OpenDBs() error {
+	genDoc, err := LoadGenesisDoc(adapter.config)
+	if err != nil {
+		return fmt.Errorf("failed to load state and genDoc: %w", err)
+	}
+	adapter.genDoc = genDoc
+
+	privValidatorKey, err := privval.LoadFilePVEmptyState(
+		adapter.config.PrivValidatorKeyFile(),
+		adapter.config.PrivValidatorStateFile(),
+	).GetPubKey()
+	if err != nil {
+		return fmt.Errorf("failed to load validator key file: %w", err)
+	}
+	adapter.privValidatorKey = privValidatorKey
+
+	blockDB, blockStore, err := GetBlockstoreDBs(adapter.config)
+	if err != nil {
+		return fmt.Errorf("failed to open blockDB: %w", err)
+	}
+
+	adapter.blockDB = blockDB
+	adapter.blockStore = blockStore
+
+	stateDB, stateStore, err := GetStateDBs(adapter.config)
+	if err != nil {
+		return fmt.Errorf("failed to open stateDB: %w", err)
+	}
+
+	adapter.stateDB = stateDB
+	adapter.stateStore = stateStore
+
+	return nil
+}
+
+func (adapter *Adapter) CloseDBs() error {
+	if err := adapter.blockDB.Close(); err != nil {
+		return fmt.Errorf("failed to close blockDB: %w", err)
+	}
+
+	if err := adapter.stateDB.Close(); err != nil {
+		return fmt.Errorf("failed to close stateDB: %w", err)
+	}
+
+	return nil
+}
+
+func (adapter *Adapter) StartProxyApp(address, transport string) error {
+	if adapter.proxyApp != nil {
+		return fmt.Errorf("proxy app already started")
+	}
+
+	proxyApp, err := CreateAndStartProxyAppConns(adapter.config, address, transport)
+	if err != nil {
+		return err
+	}
+
+	adapter.proxyApp = proxyApp
+	return nil
+}
+
+func (adapter *Adapter) StopProxyApp() error {
+	if adapter.proxyApp == nil {
+		return fmt.Errorf("proxy app already stopped")
+	}
+
+	if err := adapter.proxyApp.Stop(); err != nil {
+		return err
+	}
+
+	adapter.proxyApp = nil
+	return nil
+}
+
+func (adapter *Adapter) DoHandshake() error {
+	state, err := tmState.NewStore(adapter.stateDB, tmState.StoreOptions{
+		DiscardABCIResponses: false,
+	}).LoadFromDBOrGenesisDoc(adapter.genDoc)
+	if err != nil {
+		return fmt.Errorf("failed to load state from genDoc: %w", err)
+	}
+
+	eventBus, err := CreateAndStartEventBus()
+	if err != nil {
+		return fmt.Errorf("failed to start event bus: %w", err)
+	}
+
+	info, err := adapter.proxyApp.Query().Info(context.Background(), proxy.InfoRequest)
+	if err != nil {
+		return fmt.Errorf("failed to get app info: %w", err)
+	}
+
+	appAhead, err := core.AppAheadOfStore(info.LastBlockHeight, info.LastBlockAppHash, state.LastBlockHeight, state.AppHash, adapter.GetHeight())
+	if err != nil {
+		return fmt.Errorf("failed to check app height: %w", err)
+	}
+
+	// if the app is ahead of the block store it has nothing to replay
+	if !appAhead {
+		if err := DoHandshake(adapter.stateStore, state, adapter.blockStore, adapter.genDoc, eventBus, adapter.proxyApp); err != nil {
+			return fmt.Errorf("failed to do handshake: %w", err)
+		}
+	}
+
+	state, err = adapter.stateStore.Load()
+	if err != nil {
+		return fmt.Errorf("failed to reload state: %w", err)
+	}
+
+	adapter.state = state
+
+	mempool := CreateMempool(adapter.config, adapter.proxyApp, state)
+
+	// the evidence pool of CometBFT v1 is internal, the blocks were already verified
+	// by the chain and the commits get verified again before a block is applied
+	adapter.mempool = &mempool
+	adapter.evidencePool = tmState.EmptyEvidencePool{}
+	adapter.blockExecutor = tmState.NewBlockExecutor(
+		adapter.stateStore,
+		cometLogger.With("module", "state"),
+		adapter.proxyApp.Consensus(),
+		mempool,
+		adapter.evidencePool,
+		adapter.blockStore,
+	)
+
+	return nil
+}
+
+func (adapter *Adapter) NewABCIClient(address, transport string) (core.ABCIClient, error) {
+	return NewABCIClient(address, transport)
+}
+
+func (adapter *Adapter) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (adapter *Adapter) Unmarshal(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
+}
+
+func (adapter *Adapter) BlockHeight(block *Block) int64 {
+	return block.Height
+}
+
+func (adapter *Adapter) MakePartSet(block *Block) (*PartSet, error) {
+	return block.MakePartSet(tmTypes.BlockPartSizeBytes)
+}
+
+func (adapter *Adapter) ValidateBlock(block *Block) error {
+	return adapter.blockExecutor.ValidateBlock(adapter.state, block)
+}
+
+func (adapter *Adapter) VerifyCommit(block *Block, blockParts *PartSet, nextBlock *Block) error {
+	blockId := tmTypes.BlockID{Hash: block.Hash(), PartSetHeader: blockParts.Header()}
+	return adapter.state.Validators.VerifyCommitLight(adapter.state.ChainID, blockId, block.Height, nextBlock.LastCommit)
+}
+
+func (adapter *Adapter) ApplyBlock(block *Block, blockParts *PartSet, nextBlock *Block) error {
+	blockId := tmTypes.BlockID{Hash: block.Hash(), PartSetHeader: blockParts.Header()}
+
+	// execute block against app
+	state, err := adapter.blockExecutor.ApplyBlock(adapter.state, blockId, block, block.Height)
+	if err != nil {
+		return err
+	}
+
+	adapter.state = state
+	return nil
+}
+
+func (adapter *Adapter) SaveBlock(block *Block, blockParts *PartSet, nextBlock *Block) {
+	adapter.blockStore.SaveBlock(block, blockParts, nextBlock.LastCommit)
+}
+
+func (adapter *Adapter) StateHeight() int64 {
+	return adapter.state.LastBlockHeight
+}
+
+func (adapter *Adapter) VerifyAppliedBlock(block *Block, blockParts *PartSet, nextBlock *Block) error {
+	blockId := tmTypes.BlockID{Hash: block.Hash(), PartSetHeader: blockParts.Header()}
+	if !blockId.Equals(adapter.state.LastBlockID) {
+		return fmt.Errorf("block id %s does not match block id %s of the state", blockId, adapter.state.LastBlockID)
+	}
+
+	return adapter.state.LastValidators.VerifyCommitLight(adapter.state.ChainID, blockId, block.Height, nextBlock.LastCommit)
+}
+
+func (adapter *Adapter) LoadBlockResults() (*core.BlockResults, error) {
+	response, err := adapter.stateStore.LoadLastFinalizeBlockResponse(adapter.state.LastBlockHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	txResults := make([]core.TxResult, 0, len(response.TxResults))
+	for index, txResult := range response.TxResults {
+		txResults = append(txResults, core.TxResult{
+			Index:     index,
+			Code:      txResult.Code,
+			Codespace: txResult.Codespace,
+			Log:       txResult.Log,
+			Data:      txResult.Data,
+			GasWanted: txResult.GasWanted,
+			GasUsed:   txResult.GasUsed,
+			Events:    toEvents(txResult.Events),
+		})
+	}
+
+	return &core.BlockResults{
+		AppHash:     adapter.state.AppHash,
+		ResultsHash: adapter.state.LastResultsHash,
+		TxResults:   txResults,
+		Events:      toEvents(response.Events),
+	}, nil
+}
+
+func (adapter *Adapter) ExpectedResults(block *Block) ([]byte, []byte) {
+	return block.LastResultsHash, block.AppHash
+}
+
+func (adapter *Adapter) GetHeight() int64 {
+	height := adapter.blockStore.Height()
+	if height == 0 {
+		height, _ = adapter.stateStore.GetOfflineStateSyncHeight()
+	}
+
+	return height
+}
+
+func (adapter *Adapter) GetBaseHeight() int64 {
+	return adapter.blockStore.Base()
+}
+
+func (adapter *Adapter) LoadBlock(height int64) *Block {
+	block, _ := adapter.blockStore.LoadBlock(height)
+	return block
+}
+
+func (adapter *Adapter) LoadSeenCommit(height int64) interface{} {
+	block, _ := adapter.blockStore.LoadBlock(height + 1)
+	return block.LastCommit
+}
+
+func (adapter *Adapter) LoadState(height int64) (interface{}, error) {
+	initialHeight := height
+	if initialHeight == 0 {
+		initialHeight = 1
+	}
+
+	lastBlock, _ := adapter.blockStore.LoadBlock(height)
+	currentBlock, _ := adapter.blockStore.LoadBlock(height + 1)
+	nextBlock, _ := adapter.blockStore.LoadBlock(height + 2)
+
+	lastValidators, err := adapter.stateStore.LoadValidators(height)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load validators at height %d: %w", height, err)
+	}
+
+	currentValidators, err := adapter.stateStore.LoadValidators(height + 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load validators at height %d: %w", height+1, err)
+	}
+
+	nextValidators, err := adapter.stateStore.LoadValidators(height + 2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load validators at height %d: %w", height+2, err)
+	}
+
+	consensusParams, err := adapter.stateStore.LoadConsensusParams(height + 2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load consensus params at height %d: %w", height, err)
+	}
+
+	return tmState.State{
+		Version: tmProtoState.Version{
+			Consensus: lastBlock.Version,
+		},
+		ChainID:                          lastBlock.ChainID,
+		InitialHeight:                    initialHeight,
+		LastBlockHeight:                  lastBlock.Height,
+		LastBlockID:                      currentBlock.LastBlockID,
+		LastBlockTime:                    lastBlock.Time,
+		NextValidators:                   nextValidators,
+		Validators:                       currentValidators,
+		LastValidators:                   lastValidators,
+		LastHeightValidatorsChanged:      nextBlock.Height,
+		ConsensusParams:                  consensusParams,
+		LastHeightConsensusParamsChanged: currentBlock.Height,
+		LastResultsHash:                  currentBlock.LastResultsHash,
+		AppHash:                          currentBlock.AppHash,
+	}, nil
+}
+
+func (adapter *Adapter) BootstrapState(stateValue, _, seenCommitValue []byte) error {
+	var state tmState.State
+	if err := json.Unmarshal(stateValue, &state); err != nil {
+		return fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+
+	var seenCommit *tmTypes.Commit
+	if err := json.Unmarshal(seenCommitValue, &seenCommit); err != nil {
+		return fmt.Errorf("failed to unmarshal seen commit: %w", err)
+	}
+
+	if err := adapter.stateStore.Bootstrap(state); err != nil {
+		return fmt.Errorf("failed to bootstrap state: %w", err)
+	}
+
+	if err := adapter.blockStore.SaveSeenCommit(state.LastBlockHeight, seenCommit); err != nil {
+		return fmt.Errorf("failed to save seen commit: %w", err)
+	}
+
+	if err := adapter.stateStore.SetOfflineStateSyncHeight(state.LastBlockHeight); err != nil {
+		return fmt.Errorf("failed to set offline state sync height: %w", err)
+	}
+
+	return nil
+}
+
+func (adapter *Adapter) PruneBlocks(toHeight int64) error {
+	blocksPruned, _, err := adapter.blockStore.PruneBlocks(toHeight, adapter.state)
+	if err != nil {
+		return fmt.Errorf("failed to prune blocks up to %d: %s", toHeight, err)
+	}
+
+	base := toHeight - int64(blocksPruned)
+
+	if toHeight > base {
+		if _, err := adapter.stateStore.PruneStates(base, toHeight, toHeight, 0); err != nil {
+			return fmt.Errorf("failed to prune state up to %d: %s", toHeight, err)
+		}
+	}
+
+	return nil
+}
+
+func (adapter *Adapter) LoadNodeId() (string, error) {
+	nodeKey, err := cometP2P.LoadNodeKey(adapter.config.NodeKeyFile())
+	if err != nil {
+		return "", err
+	}
+
+	return string(nodeKey.ID()), nil
+}
+
+func (adapter *Adapter) StartP2PPeer(block, nextBlock *Block, listenAddress, peerAddress string) error {
+	adapter.config.P2P.ListenAddress = listenAddress
+
+	// generate new node key for this peer
+	ksyncNodeKey := &cometP2P.NodeKey{
+		PrivKey: ed25519.GenPrivKey(),
+	}
+
+	nodeInfo, err := MakeNodeInfo(adapter.config, ksyncNodeKey, adapter.genDoc)
+	if err != nil {
+		return fmt.Errorf("failed to make node info: %w", err)
+	}
+
+	transport := cometP2P.NewMultiplexTransport(nodeInfo, *ksyncNodeKey, cometP2P.MConnConfig(adapter.config.P2P))
+	bcR := NewBlockchainReactor(core.NewBlockPeer[Block, PartSet](adapter, block, nextBlock, (*Block).ToProto))
+	sw := CreateSwitch(adapter.config, transport, bcR, nodeInfo, ksyncNodeKey, cometLogger)
+
+	// start the transport
+	addr, err := cometP2P.NewNetAddressString(cometP2P.IDAddressString(ksyncNodeKey.ID(), listenAddress))
+	if err != nil {
+		return fmt.Errorf("failed to start transport: %w", err)
+	}
+	if err := transport.Listen(*addr); err != nil {
+		return fmt.Errorf("failed to start transport: %w", err)
+	}
+
+	if err := sw.AddPersistentPeers([]string{peerAddress}); err != nil {
+		return fmt.Errorf("could not add persistent peers: %w", err)
+	}
+
+	// start switch
+	if err := sw.Start(); err != nil {
+		return fmt.Errorf("failed to start switch: %w", err)
+	}
+
+	// get peer
+	peer, err := cometP2P.NewNetAddressString(peerAddress)
+	if err != nil {
+		return fmt.Errorf("invalid peer address: %w", err)
+	}
+
+	if err := sw.DialPeerWithAddress(peer); err != nil {
+		return fmt.Errorf("failed to dial peer: %w", err)
+	}
+
+	return nil
+}
+
+func (adapter *Adapter) StartRPCServer(listener net.Listener) error {
+	rpcLogger := cometLogger.With("module", "rpc-server")
+
+	nodeKey, err := cometP2P.LoadNodeKey(adapter.config.NodeKeyFile())
+	if err != nil {
+		return fmt.Errorf("failed to get nodeKey: %w", err)
+	}
+	nodeInfo, err := MakeNodeInfo(adapter.config, nodeKey, adapter.genDoc)
+	if err != nil {
+		return fmt.Errorf("failed to get nodeInfo: %w", err)
+	}
+
+	rpcCoreEnv := rpccore.Environment{
+		ProxyAppQuery:    nil,
+		ProxyAppMempool:  nil,
+		StateStore:       adapter.stateStore,
+		BlockStore:       adapter.blockStore,
+		EvidencePool:     nil,
+		ConsensusState:   nil,
+		P2PPeers:         nil,
+		P2PTransport:     &Transport{nodeInfo: nodeInfo},
+		PubKey:           adapter.privValidatorKey,
+		GenDoc:           nil,
+		TxIndexer:        nil,
+		BlockIndexer:     nil,
+		ConsensusReactor: &ConsensusReactor{},
+		EventBus:         nil,
+		Mempool:          nil,
+		Logger:           rpcLogger,
+		Config:           *adapter.config.RPC,
+	}
+
+	routes := map[string]*rpcserver.RPCFunc{
+		"status":        rpcserver.NewRPCFunc(rpcCoreEnv.Status, ""),
+		"block":         rpcserver.NewRPCFunc(rpcCoreEnv.Block, "height"),
+		"block_results": rpcserver.NewRPCFunc(rpcCoreEnv.BlockResults, "height"),
+	}
+
+	mux := http.NewServeMux()
+	config := rpcserver.DefaultConfig()
+
+	rpcserver.RegisterRPCFuncs(mux, routes, rpcLogger)
+	return rpcserver.Serve(listener, mux, rpcLogger, config)
+}
+
+func (adapter *Adapter) ResetPrivValidator(keyFile, stateFile string) error {
+	privval.LoadFilePVEmptyState(keyFile, stateFile).Reset()
+	return nil
+}
+
+func (adapter *Adapter) GeneratePrivValidator(keyFile, stateFile string) error {
+	pv, err := privval.GenFilePV(keyFile, stateFile, nil)
+	if err != nil {
+		return err
+	}
+	pv.Save()
+	return nil
+}
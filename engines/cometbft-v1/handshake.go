@@ -0,0 +1,248 @@
+package cometbft_v1
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	abciTypes "github.com/cometbft/cometbft/abci/types"
+	"github.com/cometbft/cometbft/crypto/merkle"
+	mempl "github.com/cometbft/cometbft/mempool"
+	"github.com/cometbft/cometbft/proxy"
+	sm "github.com/cometbft/cometbft/state"
+	"github.com/cometbft/cometbft/store"
+	cometTypes "github.com/cometbft/cometbft/types"
+)
+
+// DoHandshake syncs the app with the block store. Since the handshaker of CometBFT v1
+// is part of an internal package this follows the same steps as the handshaker: the
+// chain gets initialized on genesis and blocks which the app is missing are replayed
+func DoHandshake(
+	stateStore sm.Store,
+	state sm.State,
+	blockStore *store.BlockStore,
+	genDoc *GenesisDoc,
+	eventBus cometTypes.BlockEventPublisher,
+	proxyApp proxy.AppConns,
+) error {
+	logger := cometLogger.With("module", "consensus")
+
+	res, err := proxyApp.Query().Info(context.Background(), proxy.InfoRequest)
+	if err != nil {
+		return fmt.Errorf("error calling Info: %v", err)
+	}
+
+	appHeight := res.LastBlockHeight
+	if appHeight < 0 {
+		return fmt.Errorf("got a negative last block height (%d) from the app", appHeight)
+	}
+	appHash := res.LastBlockAppHash
+
+	logger.Info("ABCI Handshake App Info", "height", appHeight, "hash", appHash, "software-version", res.Version, "protocol-version", res.AppVersion)
+
+	// only set the version if there is no existing state
+	if state.LastBlockHeight == 0 {
+		state.Version.Consensus.App = res.AppVersion
+	}
+
+	if appHeight == 0 {
+		state, appHash, err = initChain(stateStore, state, genDoc, proxyApp)
+		if err != nil {
+			return fmt.Errorf("error on init chain: %v", err)
+		}
+	}
+
+	storeBase, storeHeight, stateHeight := blockStore.Base(), blockStore.Height(), state.LastBlockHeight
+	logger.Info("ABCI Replay Blocks", "appHeight", appHeight, "storeHeight", storeHeight, "stateHeight", stateHeight)
+
+	switch {
+	case storeHeight == 0:
+		return checkAppHash(appHash, state.AppHash)
+	case appHeight == 0 && state.InitialHeight < storeBase:
+		return sm.ErrAppBlockHeightTooLow{AppHeight: appHeight, StoreBase: storeBase}
+	case appHeight > 0 && appHeight < storeBase-1:
+		return sm.ErrAppBlockHeightTooLow{AppHeight: appHeight, StoreBase: storeBase}
+	case storeHeight < appHeight:
+		return sm.ErrAppBlockHeightTooHigh{CoreHeight: storeHeight, AppHeight: appHeight}
+	case storeHeight < stateHeight || storeHeight > stateHeight+1:
+		return fmt.Errorf("block store height %d does not match state height %d", storeHeight, stateHeight)
+	}
+
+	// the state was saved for the last block in the store, so the app only has to catch up
+	if storeHeight == stateHeight {
+		if appHeight < storeHeight {
+			appHash, err = replayBlocks(stateStore, blockStore, genDoc, proxyApp, appHeight, storeHeight)
+			if err != nil {
+				return err
+			}
+		}
+
+		return checkAppHash(appHash, state.AppHash)
+	}
+
+	// the last block was saved in the store but the state was not updated. If the app already
+	// committed the last block it is replayed against a mock app which returns the stored
+	// FinalizeBlock response, so the block is not committed to the app twice
+	if appHeight == storeHeight {
+		finalizeBlockResponse, err := stateStore.LoadLastFinalizeBlockResponse(storeHeight)
+		if err != nil {
+			return fmt.Errorf("failed to load finalize block response at height %d: %w", storeHeight, err)
+		}
+
+		// nodes which upgraded from EndBlock to FinalizeBlock have no app hash saved
+		// for this height, in that case the app hash from the Info handshake is used
+		if len(finalizeBlockResponse.AppHash) == 0 {
+			finalizeBlockResponse.AppHash = appHash
+		}
+
+		mockApp, err := newMockProxyApp(finalizeBlockResponse)
+		if err != nil {
+			return fmt.Errorf("failed to start mock app: %w", err)
+		}
+
+		logger.Info("Replay last block using mock app")
+		return replayBlock(stateStore, state, blockStore, eventBus, mockApp, storeHeight)
+	}
+
+	if appHeight < stateHeight {
+		if _, err := replayBlocks(stateStore, blockStore, genDoc, proxyApp, appHeight, stateHeight); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Replay last block using real app")
+	return replayBlock(stateStore, state, blockStore, eventBus, proxyApp.Consensus(), storeHeight)
+}
+
+// replayBlock applies the block at the given height and updates the state
+func replayBlock(stateStore sm.Store, state sm.State, blockStore *store.BlockStore, eventBus cometTypes.BlockEventPublisher, appConn proxy.AppConnConsensus, height int64) error {
+	block, meta := blockStore.LoadBlock(height)
+	blockExec := sm.NewBlockExecutor(stateStore, cometLogger.With("module", "consensus"), appConn, &mempl.NopMempool{}, sm.EmptyEvidencePool{}, blockStore)
+	blockExec.SetEventBus(eventBus)
+
+	if _, err := blockExec.ApplyBlock(state, meta.BlockID, block, block.Height); err != nil {
+		return fmt.Errorf("failed to replay block at height %d: %w", height, err)
+	}
+
+	return nil
+}
+
+// mockProxyApp returns the stored FinalizeBlock response of a block which the app already
+// committed, so the block can be applied to the state again without reaching the app
+type mockProxyApp struct {
+	abciTypes.BaseApplication
+	finalizeBlockResponse *abciTypes.FinalizeBlockResponse
+}
+
+func newMockProxyApp(finalizeBlockResponse *abciTypes.FinalizeBlockResponse) (proxy.AppConnConsensus, error) {
+	clientCreator := proxy.NewLocalClientCreator(&mockProxyApp{finalizeBlockResponse: finalizeBlockResponse})
+
+	client, err := clientCreator.NewABCIConsensusClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Start(); err != nil {
+		return nil, err
+	}
+
+	return proxy.NewAppConnConsensus(client, proxy.NopMetrics()), nil
+}
+
+func (mock *mockProxyApp) FinalizeBlock(context.Context, *abciTypes.FinalizeBlockRequest) (*abciTypes.FinalizeBlockResponse, error) {
+	return mock.finalizeBlockResponse, nil
+}
+
+// initChain calls InitChain on the app and updates the state if it is the initial state
+func initChain(stateStore sm.Store, state sm.State, genDoc *GenesisDoc, proxyApp proxy.AppConns) (sm.State, []byte, error) {
+	validators := make([]*cometTypes.Validator, len(genDoc.Validators))
+	for i, val := range genDoc.Validators {
+		if _, ok := cometTypes.ABCIPubKeyTypesToNames[val.PubKey.Type()]; !ok {
+			return state, nil, fmt.Errorf("unsupported public key type %s (validator name: %s)", val.PubKey.Type(), val.Name)
+		}
+		validators[i] = cometTypes.NewValidator(val.PubKey, val.Power)
+	}
+
+	validatorSet := cometTypes.NewValidatorSet(validators)
+	consensusParams := genDoc.ConsensusParams.ToProto()
+
+	res, err := proxyApp.Consensus().InitChain(context.Background(), &abciTypes.InitChainRequest{
+		Time:            genDoc.GenesisTime,
+		ChainId:         genDoc.ChainID,
+		InitialHeight:   genDoc.InitialHeight,
+		ConsensusParams: &consensusParams,
+		Validators:      cometTypes.TM2PB.ValidatorUpdates(validatorSet),
+		AppStateBytes:   genDoc.AppState,
+	})
+	if err != nil {
+		return state, nil, err
+	}
+
+	// the state is only updated if it is still the initial state
+	if state.LastBlockHeight != 0 {
+		return state, res.AppHash, nil
+	}
+
+	if len(res.AppHash) > 0 {
+		state.AppHash = res.AppHash
+	}
+
+	if len(res.Validators) > 0 {
+		vals, err := cometTypes.PB2TM.ValidatorUpdates(res.Validators)
+		if err != nil {
+			return state, nil, err
+		}
+		state.Validators = cometTypes.NewValidatorSet(vals)
+		state.NextValidators = cometTypes.NewValidatorSet(vals).CopyIncrementProposerPriority(1)
+	} else if len(genDoc.Validators) == 0 {
+		return state, nil, fmt.Errorf("validator set is nil in genesis and still empty after InitChain")
+	}
+
+	if res.ConsensusParams != nil {
+		state.ConsensusParams = state.ConsensusParams.Update(res.ConsensusParams)
+		state.Version.Consensus.App = state.ConsensusParams.Version.App
+	}
+
+	state.LastResultsHash = merkle.HashFromByteSlices(nil)
+
+	if err := stateStore.Save(state); err != nil {
+		return state, nil, err
+	}
+
+	return state, res.AppHash, nil
+}
+
+// replayBlocks executes and commits the blocks after the app height up to the final height
+// without updating the state, since the state is already at the final height
+func replayBlocks(stateStore sm.Store, blockStore *store.BlockStore, genDoc *GenesisDoc, proxyApp proxy.AppConns, appHeight, finalHeight int64) ([]byte, error) {
+	var appHash []byte
+
+	firstHeight := appHeight + 1
+	if firstHeight == 1 {
+		firstHeight = genDoc.InitialHeight
+	}
+
+	for height := firstHeight; height <= finalHeight; height++ {
+		block, _ := blockStore.LoadBlock(height)
+
+		if len(appHash) > 0 {
+			if err := checkAppHash(appHash, block.AppHash); err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		appHash, err = sm.ExecCommitBlock(proxyApp.Consensus(), block, cometLogger.With("module", "consensus"), stateStore, genDoc.InitialHeight, finalHeight)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay block at height %d: %w", height, err)
+		}
+	}
+
+	return appHash, nil
+}
+
+func checkAppHash(appHash, expected []byte) error {
+	if !bytes.Equal(appHash, expected) {
+		return fmt.Errorf("app hash %X does not match expected app hash %X, did you reset CometBFT without resetting your application's data?", appHash, expected)
+	}
+	return nil
+}
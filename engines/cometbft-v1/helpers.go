@@ -0,0 +1,126 @@
+package cometbft_v1
+
+import (
+	"fmt"
+	dbm "github.com/cometbft/cometbft-db"
+	abciTypes "github.com/cometbft/cometbft/abci/types"
+	cfg "github.com/cometbft/cometbft/config"
+	mempl "github.com/cometbft/cometbft/mempool"
+	"github.com/cometbft/cometbft/proxy"
+	"github.com/cometbft/cometbft/state"
+	sm "github.com/cometbft/cometbft/state"
+	"github.com/cometbft/cometbft/store"
+	cometTypes "github.com/cometbft/cometbft/types"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"strings"
+)
+
+type DBContext struct {
+	ID     string
+	Config *Config
+}
+
+func LoadConfig(homePath string) (*cfg.Config, error) {
+	config := cfg.DefaultConfig()
+
+	viper.SetConfigName("config")
+	viper.SetConfigType("toml")
+	viper.AddConfigPath(homePath)
+	viper.AddConfigPath(filepath.Join(homePath, "config"))
+
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	if err := viper.Unmarshal(config); err != nil {
+		return nil, err
+	}
+
+	config.SetRoot(homePath)
+
+	return config, nil
+}
+
+func LoadGenesisDoc(config *Config) (*GenesisDoc, error) {
+	return cometTypes.GenesisDocFromFile(config.GenesisFile())
+}
+
+func DefaultDBProvider(ctx *DBContext) (dbm.DB, error) {
+	dbType := dbm.BackendType(ctx.Config.DBBackend)
+	return dbm.NewDB(ctx.ID, dbType, ctx.Config.DBDir())
+}
+
+func GetStateDBs(config *Config) (dbm.DB, state.Store, error) {
+	stateDB, err := DefaultDBProvider(&DBContext{"state", config})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stateStore := state.NewStore(stateDB, sm.StoreOptions{
+		DiscardABCIResponses: config.Storage.DiscardABCIResponses,
+		DBKeyLayout:          config.Storage.ExperimentalKeyLayout,
+	})
+
+	return stateDB, stateStore, nil
+}
+
+func GetBlockstoreDBs(config *Config) (dbm.DB, *store.BlockStore, error) {
+	blockStoreDB, err := DefaultDBProvider(&DBContext{"blockstore", config})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blockStore := store.NewBlockStore(blockStoreDB, store.WithDBKeyLayout(config.Storage.ExperimentalKeyLayout))
+
+	return blockStoreDB, blockStore, nil
+}
+
+func CreateAndStartProxyAppConns(config *Config, address, transport string) (proxy.AppConns, error) {
+	proxyApp := proxy.NewAppConns(proxy.DefaultClientCreator(address, transport, config.DBDir()), proxy.NopMetrics())
+	proxyApp.SetLogger(cometLogger.With("module", "proxy"))
+	if err := proxyApp.Start(); err != nil {
+		return nil, fmt.Errorf("error starting proxy app connections: %v", err)
+	}
+	return proxyApp, nil
+}
+
+func CreateAndStartEventBus() (*cometTypes.EventBus, error) {
+	eventBus := cometTypes.NewEventBus()
+	eventBus.SetLogger(cometLogger.With("module", "events"))
+	if err := eventBus.Start(); err != nil {
+		return nil, err
+	}
+	return eventBus, nil
+}
+
+func CreateMempool(config *Config, proxyApp proxy.AppConns, state sm.State) mempl.Mempool {
+	logger := cometLogger.With("module", "mempool")
+	mp := mempl.NewCListMempool(
+		config.Mempool,
+		proxyApp.Mempool(),
+		state.LastBlockHeight,
+		mempl.WithMetrics(mempl.NopMetrics()),
+		mempl.WithPreCheck(sm.TxPreCheck(state)),
+		mempl.WithPostCheck(sm.TxPostCheck(state)),
+	)
+
+	mp.SetLogger(logger)
+	if config.Consensus.WaitForTxs() {
+		mp.EnableTxsAvailable()
+	}
+
+	return mp
+}
+
+// OfferSnapshotResult returns the result without the enum prefix of CometBFT v1,
+// so it matches the results of the other engines
+func OfferSnapshotResult(result abciTypes.OfferSnapshotResult) string {
+	return strings.TrimPrefix(result.String(), "OFFER_SNAPSHOT_RESULT_")
+}
+
+// ApplySnapshotChunkResult returns the result without the enum prefix of CometBFT v1,
+// so it matches the results of the other engines
+func ApplySnapshotChunkResult(result abciTypes.ApplySnapshotChunkResult) string {
+	return strings.TrimPrefix(result.String(), "APPLY_SNAPSHOT_CHUNK_RESULT_")
+}
@@ -0,0 +1,20 @@
+package cometbft_v1
+
+import (
+	"github.com/KYVENetwork/ksync/engines/core"
+	"github.com/cometbft/cometbft/libs/log"
+)
+
+func CometLogger() (logger log.Logger) {
+	logger = KsyncCometLogger{Logger: core.NewLogger()}
+	return
+}
+
+type KsyncCometLogger struct {
+	core.Logger
+}
+
+func (l KsyncCometLogger) With(keyvals ...interface{}) (logger log.Logger) {
+	logger = KsyncCometLogger{Logger: core.NewLogger(keyvals...)}
+	return
+}
@@ -0,0 +1,175 @@
+package cometbft_v1
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/engines/core"
+	bcproto "github.com/cometbft/cometbft/api/cometbft/blocksync/v1"
+	cometproto "github.com/cometbft/cometbft/api/cometbft/types/v1"
+	cometLog "github.com/cometbft/cometbft/libs/log"
+	"github.com/cometbft/cometbft/p2p"
+	"github.com/cometbft/cometbft/p2p/conn"
+	sm "github.com/cometbft/cometbft/state"
+	"github.com/cometbft/cometbft/types"
+	"github.com/cometbft/cometbft/version"
+	"github.com/cosmos/gogoproto/proto"
+	"reflect"
+)
+
+const (
+	BlocksyncChannel = byte(0x40)
+
+	// the blocksync package of CometBFT v1 is internal, so the size of the
+	// block response message is defined here
+	BlockResponseMessagePrefixSize   = 4
+	BlockResponseMessageFieldKeySize = 1
+	MaxMsgSize                       = types.MaxBlockSizeBytes + BlockResponseMessagePrefixSize + BlockResponseMessageFieldKeySize
+)
+
+// BlockPeer serves the block and the next block to the node
+type BlockPeer = core.BlockPeer[Block, PartSet, *cometproto.Block]
+
+type BlockchainReactor struct {
+	p2p.BaseReactor
+
+	peer *BlockPeer
+}
+
+func NewBlockchainReactor(peer *BlockPeer) *BlockchainReactor {
+	bcR := &BlockchainReactor{
+		peer: peer,
+	}
+	bcR.BaseReactor = *p2p.NewBaseReactor("BlockchainReactor", bcR)
+	return bcR
+}
+
+func (bcR *BlockchainReactor) GetChannels() []*conn.ChannelDescriptor {
+	return []*conn.ChannelDescriptor{
+		{
+			ID:                  BlocksyncChannel,
+			Priority:            5,
+			SendQueueCapacity:   1000,
+			RecvBufferCapacity:  50 * 4096,
+			RecvMessageCapacity: MaxMsgSize,
+			MessageType:         &bcproto.Message{},
+		},
+	}
+}
+
+func (bcR *BlockchainReactor) Receive(e p2p.Envelope) {
+	if err := ValidateMsg(e.Message); err != nil {
+		bcR.Logger.Error("Peer sent us invalid msg", "peer", e.Src, "msg", e.Message, "err", err)
+		bcR.Switch.StopPeerForError(e.Src, err)
+		return
+	}
+
+	switch msg := e.Message.(type) {
+	case *bcproto.StatusRequest:
+		base, height := bcR.peer.Status()
+		e.Src.Send(p2p.Envelope{
+			ChannelID: BlocksyncChannel,
+			Message:   &bcproto.StatusResponse{Base: base, Height: height},
+		})
+	case *bcproto.BlockRequest:
+		if bl, ok := bcR.peer.Block(msg.Height); ok {
+			e.Src.TrySend(p2p.Envelope{
+				ChannelID: BlocksyncChannel,
+				Message:   &bcproto.BlockResponse{Block: bl},
+			})
+		}
+	case *bcproto.StatusResponse:
+		bcR.peer.StatusResponse(msg.Base, msg.Height)
+	default:
+		bcR.peer.UnknownMessage(msg)
+	}
+}
+
+// ValidateMsg validates a blocksync message like the blocksync reactor of CometBFT v1
+func ValidateMsg(pb proto.Message) error {
+	if pb == nil {
+		return fmt.Errorf("message cannot be nil")
+	}
+
+	switch msg := pb.(type) {
+	case *bcproto.BlockRequest:
+		if msg.Height < 0 {
+			return fmt.Errorf("invalid height %d: negative height", msg.Height)
+		}
+	case *bcproto.BlockResponse:
+		return nil
+	case *bcproto.NoBlockResponse:
+		if msg.Height < 0 {
+			return fmt.Errorf("invalid height %d: negative height", msg.Height)
+		}
+	case *bcproto.StatusResponse:
+		if msg.Base < 0 {
+			return fmt.Errorf("invalid base %d: negative base", msg.Base)
+		}
+		if msg.Height < 0 {
+			return fmt.Errorf("invalid height %d: negative height", msg.Height)
+		}
+		if msg.Base > msg.Height {
+			return fmt.Errorf("invalid height %d: base %d cannot be greater than height", msg.Height, msg.Base)
+		}
+	case *bcproto.StatusRequest:
+		return nil
+	default:
+		return fmt.Errorf("unknown message type %v", reflect.TypeOf(msg))
+	}
+	return nil
+}
+
+func MakeNodeInfo(
+	config *Config,
+	nodeKey *p2p.NodeKey,
+	genDoc *GenesisDoc,
+) (p2p.NodeInfo, error) {
+	nodeInfo := p2p.DefaultNodeInfo{
+		ProtocolVersion: p2p.NewProtocolVersion(
+			version.P2PProtocol,
+			sm.InitStateVersion.Consensus.Block,
+			sm.InitStateVersion.Consensus.App,
+		),
+		DefaultNodeID: nodeKey.ID(),
+		Network:       genDoc.ChainID,
+		Version:       version.CMTSemVer,
+		Channels:      []byte{BlocksyncChannel},
+		Moniker:       config.Moniker,
+		Other: p2p.DefaultNodeInfoOther{
+			TxIndex:    "off",
+			RPCAddress: config.RPC.ListenAddress,
+		},
+	}
+
+	lAddr := config.P2P.ExternalAddress
+
+	if lAddr == "" {
+		lAddr = config.P2P.ListenAddress
+	}
+
+	nodeInfo.ListenAddr = lAddr
+
+	err := nodeInfo.Validate()
+	return nodeInfo, err
+}
+
+func CreateSwitch(config *Config,
+	transport p2p.Transport,
+	bcReactor p2p.Reactor,
+	nodeInfo p2p.NodeInfo,
+	nodeKey *p2p.NodeKey,
+	logger cometLog.Logger) *p2p.Switch {
+
+	sw := p2p.NewSwitch(
+		config.P2P,
+		transport,
+	)
+	sw.SetLogger(logger)
+	bcReactor.SetLogger(logger)
+	sw.AddReactor("BLOCKCHAIN", bcReactor)
+
+	sw.SetNodeInfo(nodeInfo)
+	sw.SetNodeKey(nodeKey)
+
+	logger.Info("P2P Node ID", "ID", nodeKey.ID())
+	return sw
+}
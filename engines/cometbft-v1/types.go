@@ -0,0 +1,37 @@
+package cometbft_v1
+
+import (
+	cometCfg "github.com/cometbft/cometbft/config"
+	cometP2P "github.com/cometbft/cometbft/p2p"
+	cometTypes "github.com/cometbft/cometbft/types"
+)
+
+type Block = cometTypes.Block
+type PartSet = cometTypes.PartSet
+type LightBlock = cometTypes.LightBlock
+type Config = cometCfg.Config
+type GenesisDoc = cometTypes.GenesisDoc
+
+type Transport struct {
+	nodeInfo cometP2P.NodeInfo
+}
+
+func (t *Transport) Listeners() []string {
+	return []string{}
+}
+
+func (t *Transport) IsListening() bool {
+	return false
+}
+
+func (t *Transport) NodeInfo() cometP2P.NodeInfo {
+	return t.nodeInfo
+}
+
+// ConsensusReactor reports the sync status to the RPC server, since the consensus
+// reactor of CometBFT v1 is internal
+type ConsensusReactor struct{}
+
+func (r *ConsensusReactor) WaitSync() bool {
+	return false
+}
@@ -2,15 +2,12 @@ package cometbft_v37
 
 import (
 	"fmt"
-	abciClient "github.com/KYVENetwork/cometbft/v37/abci/client"
-	abciTypes "github.com/KYVENetwork/cometbft/v37/abci/types"
 	cfg "github.com/KYVENetwork/cometbft/v37/config"
 	cs "github.com/KYVENetwork/cometbft/v37/consensus"
 	"github.com/KYVENetwork/cometbft/v37/crypto"
 	"github.com/KYVENetwork/cometbft/v37/crypto/ed25519"
 	"github.com/KYVENetwork/cometbft/v37/evidence"
 	"github.com/KYVENetwork/cometbft/v37/libs/json"
-	cmtos "github.com/KYVENetwork/cometbft/v37/libs/os"
 	"github.com/KYVENetwork/cometbft/v37/mempool"
 	nm "github.com/KYVENetwork/cometbft/v37/node"
 	cometP2P "github.com/KYVENetwork/cometbft/v37/p2p"
@@ -22,24 +19,27 @@ import (
 	tmState "github.com/KYVENetwork/cometbft/v37/state"
 	tmStore "github.com/KYVENetwork/cometbft/v37/store"
 	tmTypes "github.com/KYVENetwork/cometbft/v37/types"
+	"github.com/KYVENetwork/ksync/engines/core"
+	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 	db "github.com/cometbft/cometbft-db"
+	"net"
 	"net/http"
-	"net/url"
-	"os"
-	"strconv"
-	"time"
 )
 
 var (
 	cometLogger = CometLogger()
 )
 
-type Engine struct {
-	HomePath      string
-	RpcServerPort int64
-	areDBsOpen    bool
-	config        *cfg.Config
+type Engine = core.Engine[Block, PartSet]
+
+func NewEngine(engineConfig types.EngineConfig) *Engine {
+	return core.NewEngine[Block, PartSet](&Adapter{}, engineConfig)
+}
+
+// Adapter implements the parts of the engine which are specific to CometBFT v0.37
+type Adapter struct {
+	config *cfg.Config
 
 	blockDB    db.DB
 	blockStore *tmStore.BlockStore
@@ -51,198 +51,170 @@ type Engine struct {
 	privValidatorKey crypto.PubKey
 
 	state         tmState.State
-	prevBlock     *Block
 	proxyApp      proxy.AppConns
 	mempool       *mempool.Mempool
 	evidencePool  *evidence.Pool
 	blockExecutor *tmState.BlockExecutor
 }
 
-func (engine *Engine) GetName() string {
+func (adapter *Adapter) GetName() string {
 	return utils.EngineCometBFTV37
 }
 
-func (engine *Engine) LoadConfig() error {
-	if engine.config != nil {
-		return nil
-	}
-
-	config, err := LoadConfig(engine.HomePath)
+func (adapter *Adapter) LoadConfig(homePath string) (*core.Config, error) {
+	config, err := LoadConfig(homePath)
 	if err != nil {
-		return fmt.Errorf("failed to load config.toml: %w", err)
+		return nil, err
 	}
 
-	engine.config = config
-	return nil
-}
+	adapter.config = config
 
-func (engine *Engine) OpenDBs() error {
-	if engine.areDBsOpen {
-		return nil
-	}
+	return &core.Config{
+		ProxyApp:               config.ProxyApp,
+		AbciTransport:          config.ABCI,
+		GenesisFile:            config.GenesisFile(),
+		DBDir:                  config.DBDir(),
+		AddrBookFile:           config.P2P.AddrBookFile(),
+		NodeKeyFile:            config.NodeKeyFile(),
+		PrivValidatorKeyFile:   config.PrivValidatorKeyFile(),
+		PrivValidatorStateFile: config.PrivValidatorStateFile(),
+		P2PListenAddress:       config.P2P.ListenAddress,
+	}, nil
+}
 
-	if err := engine.LoadConfig(); err != nil {
-		return err
+func (adapter *Adapter) LoadGenesis() (string, int64, error) {
+	genDoc, err := nm.DefaultGenesisDocProviderFunc(adapter.config)()
+	if err != nil {
+		return "", 0, err
 	}
 
-	if err := utils.FormatGenesisFile(engine.config.GenesisFile()); err != nil {
-		return fmt.Errorf("failed to format genesis file: %w", err)
-	}
+	return genDoc.ChainID, genDoc.InitialHeight, nil
+}
 
-	genDoc, err := nm.DefaultGenesisDocProviderFunc(engine.config)()
+func (adapter *Adapter) OpenDBs() error {
+	genDoc, err := nm.DefaultGenesisDocProviderFunc(adapter.config)()
 	if err != nil {
 		return fmt.Errorf("failed to load state and genDoc: %w", err)
 	}
-	engine.genDoc = genDoc
+	adapter.genDoc = genDoc
 
 	privValidatorKey, err := privval.LoadFilePVEmptyState(
-		engine.config.PrivValidatorKeyFile(),
-		engine.config.PrivValidatorStateFile(),
+		adapter.config.PrivValidatorKeyFile(),
+		adapter.config.PrivValidatorStateFile(),
 	).GetPubKey()
 	if err != nil {
 		return fmt.Errorf("failed to load validator key file: %w", err)
 	}
-	engine.privValidatorKey = privValidatorKey
+	adapter.privValidatorKey = privValidatorKey
 
-	blockDB, blockStore, err := GetBlockstoreDBs(engine.config)
+	blockDB, blockStore, err := GetBlockstoreDBs(adapter.config)
 	if err != nil {
 		return fmt.Errorf("failed to open blockDB: %w", err)
 	}
 
-	engine.blockDB = blockDB
-	engine.blockStore = blockStore
+	adapter.blockDB = blockDB
+	adapter.blockStore = blockStore
 
-	stateDB, stateStore, err := GetStateDBs(engine.config)
+	stateDB, stateStore, err := GetStateDBs(adapter.config)
 	if err != nil {
 		return fmt.Errorf("failed to open stateDB: %w", err)
 	}
 
-	engine.stateDB = stateDB
-	engine.stateStore = stateStore
+	adapter.stateDB = stateDB
+	adapter.stateStore = stateStore
 
-	engine.areDBsOpen = true
 	return nil
 }
 
-func (engine *Engine) CloseDBs() error {
-	if !engine.areDBsOpen {
-		return nil
-	}
-
-	if err := engine.blockDB.Close(); err != nil {
+func (adapter *Adapter) CloseDBs() error {
+	if err := adapter.blockDB.Close(); err != nil {
 		return fmt.Errorf("failed to close blockDB: %w", err)
 	}
 
-	if err := engine.stateDB.Close(); err != nil {
+	if err := adapter.stateDB.Close(); err != nil {
 		return fmt.Errorf("failed to close stateDB: %w", err)
 	}
 
-	engine.areDBsOpen = false
 	return nil
 }
 
-func (engine *Engine) GetHomePath() string {
-	return engine.HomePath
-}
-
-func (engine *Engine) GetProxyAppAddress() string {
-	return engine.config.ProxyApp
-}
-
-func (engine *Engine) StartProxyApp() error {
-	if engine.proxyApp != nil {
+func (adapter *Adapter) StartProxyApp(address, transport string) error {
+	if adapter.proxyApp != nil {
 		return fmt.Errorf("proxy app already started")
 	}
 
-	proxyApp, err := CreateAndStartProxyAppConns(engine.config)
+	proxyApp, err := CreateAndStartProxyAppConns(adapter.config, address, transport)
 	if err != nil {
 		return err
 	}
 
-	engine.proxyApp = proxyApp
+	adapter.proxyApp = proxyApp
 	return nil
 }
 
-func (engine *Engine) StopProxyApp() error {
-	if engine.proxyApp == nil {
+func (adapter *Adapter) StopProxyApp() error {
+	if adapter.proxyApp == nil {
 		return fmt.Errorf("proxy app already stopped")
 	}
 
-	if err := engine.proxyApp.Stop(); err != nil {
+	if err := adapter.proxyApp.Stop(); err != nil {
 		return err
 	}
 
-	engine.proxyApp = nil
+	adapter.proxyApp = nil
 	return nil
 }
 
-func (engine *Engine) GetChainId() (string, error) {
-	if err := engine.LoadConfig(); err != nil {
-		return "", fmt.Errorf("failed to load config: %w", err)
-	}
-
-	genDoc, err := nm.DefaultGenesisDocProviderFunc(engine.config)()
+func (adapter *Adapter) DoHandshake() error {
+	state, err := tmState.NewStore(adapter.stateDB, tmState.StoreOptions{
+		DiscardABCIResponses: false,
+	}).LoadFromDBOrGenesisDoc(adapter.genDoc)
 	if err != nil {
-		return "", fmt.Errorf("failed to load genDoc: %w", err)
+		return fmt.Errorf("failed to load state from genDoc: %w", err)
 	}
 
-	return genDoc.ChainID, nil
-}
-
-func (engine *Engine) GetContinuationHeight() (int64, error) {
-	height := engine.blockStore.Height()
-
-	initialHeight, err := utils.GetInitialHeightFromGenesisFile(engine.GetGenesisPath())
+	eventBus, err := CreateAndStartEventBus()
 	if err != nil {
-		return 0, fmt.Errorf("failed to load initial height from genesis file: %w", err)
-	}
-
-	continuationHeight := height + 1
-
-	if continuationHeight < initialHeight {
-		continuationHeight = initialHeight
+		return fmt.Errorf("failed to start event bus: %w", err)
 	}
 
-	return continuationHeight, nil
-}
-
-func (engine *Engine) DoHandshake() error {
-	state, err := tmState.NewStore(engine.stateDB, tmState.StoreOptions{
-		DiscardABCIResponses: false,
-	}).LoadFromDBOrGenesisDoc(engine.genDoc)
+	info, err := adapter.proxyApp.Query().InfoSync(proxy.RequestInfo)
 	if err != nil {
-		return fmt.Errorf("failed to load state from genDoc: %w", err)
+		return fmt.Errorf("failed to get app info: %w", err)
 	}
 
-	eventBus, err := CreateAndStartEventBus()
+	appAhead, err := core.AppAheadOfStore(info.LastBlockHeight, info.LastBlockAppHash, state.LastBlockHeight, state.AppHash, adapter.GetHeight())
 	if err != nil {
-		return fmt.Errorf("failed to start event bus: %w", err)
+		return fmt.Errorf("failed to check app height: %w", err)
 	}
 
-	if err := DoHandshake(engine.stateStore, state, engine.blockStore, engine.genDoc, eventBus, engine.proxyApp); err != nil {
-		return fmt.Errorf("failed to do handshake: %w", err)
+	// if the app is ahead of the block store it has nothing to replay
+	if !appAhead {
+		if err := DoHandshake(adapter.stateStore, state, adapter.blockStore, adapter.genDoc, eventBus, adapter.proxyApp); err != nil {
+			return fmt.Errorf("failed to do handshake: %w", err)
+		}
 	}
 
-	state, err = engine.stateStore.Load()
+	state, err = adapter.stateStore.Load()
 	if err != nil {
 		return fmt.Errorf("failed to reload state: %w", err)
 	}
 
-	engine.state = state
+	adapter.state = state
 
-	mempool := CreateMempool(engine.config, engine.proxyApp, state)
+	mempool := CreateMempool(adapter.config, adapter.proxyApp, state)
 
-	_, evidencePool, err := CreateEvidenceReactor(engine.config, engine.stateStore, engine.blockStore)
+	_, evidencePool, err := CreateEvidenceReactor(adapter.config, adapter.stateStore, adapter.blockStore)
 	if err != nil {
 		return fmt.Errorf("failed to create evidence reactor: %w", err)
 	}
 
-	engine.mempool = &mempool
-	engine.evidencePool = evidencePool
-	engine.blockExecutor = tmState.NewBlockExecutor(
-		engine.stateStore,
+	adapter.mempool = &mempool
+	adapter.evidencePool = evidencePool
+	adapter.blockExecutor = tmState.NewBlockExecutor(
+		adapter.stateStore,
 		cometLogger.With("module", "state"),
-		engine.proxyApp.Consensus(),
+		adapter.proxyApp.Consensus(),
 		mempool,
 		evidencePool,
 	)
@@ -250,383 +222,144 @@ func (engine *Engine) DoHandshake() error {
 	return nil
 }
 
-func (engine *Engine) ApplyBlock(runtime *string, value []byte) error {
-	var block *Block
-
-	if runtime == nil {
-		// if runtime is nil we sync from another cometbft node
-		var blockResponse BlockResponse
-		err := json.Unmarshal(value, &blockResponse)
-		if err != nil {
-			return fmt.Errorf("failed to unmarshal block response: %w", err)
-		}
-		block = &blockResponse.Result.Block
-	} else if *runtime == utils.KSyncRuntimeTendermint {
-		var parsed TendermintValue
-
-		if err := json.Unmarshal(value, &parsed); err != nil {
-			return fmt.Errorf("failed to unmarshal value: %w", err)
-		}
-
-		block = parsed.Block.Block
-	} else if *runtime == utils.KSyncRuntimeTendermintBsync {
-		if err := json.Unmarshal(value, &block); err != nil {
-			return fmt.Errorf("failed to unmarshal value: %w", err)
-		}
-	} else {
-		return fmt.Errorf("runtime %s unknown", runtime)
-	}
-
-	// if the previous block is not defined we continue
-	if engine.prevBlock == nil {
-		engine.prevBlock = block
-		return nil
-	}
-
-	// get block data
-	blockParts, err := engine.prevBlock.MakePartSet(tmTypes.BlockPartSizeBytes)
-	if err != nil {
-		return fmt.Errorf("failed make part set of block: %w", err)
-	}
-
-	blockId := tmTypes.BlockID{Hash: engine.prevBlock.Hash(), PartSetHeader: blockParts.Header()}
-
-	// verify block
-	if err := engine.blockExecutor.ValidateBlock(engine.state, engine.prevBlock); err != nil {
-		return fmt.Errorf("block validation failed at height %d: %w", engine.prevBlock.Height, err)
-	}
-
-	// verify commits
-	if err := engine.state.Validators.VerifyCommitLight(engine.state.ChainID, blockId, engine.prevBlock.Height, block.LastCommit); err != nil {
-		return fmt.Errorf("light commit verification failed at height %d: %w", engine.prevBlock.Height, err)
-	}
-
-	// store block
-	engine.blockStore.SaveBlock(engine.prevBlock, blockParts, block.LastCommit)
-
-	// execute block against app
-	state, _, err := engine.blockExecutor.ApplyBlock(engine.state, blockId, engine.prevBlock)
-	if err != nil {
-		return fmt.Errorf("failed to apply block at height %d: %w", engine.prevBlock.Height, err)
-	}
-
-	// update values for next round
-	engine.state = state
-	engine.prevBlock = block
-
-	return nil
+func (adapter *Adapter) NewABCIClient(address, transport string) (core.ABCIClient, error) {
+	return NewABCIClient(address, transport)
 }
 
-func (engine *Engine) ApplyFirstBlockOverP2P(runtime string, value, nextValue []byte) error {
-	var block, nextBlock *Block
-
-	if runtime == utils.KSyncRuntimeTendermint {
-		var parsed, nextParsed TendermintValue
-
-		if err := json.Unmarshal(value, &parsed); err != nil {
-			return fmt.Errorf("failed to unmarshal value: %w", err)
-		}
-
-		if err := json.Unmarshal(nextValue, &nextParsed); err != nil {
-			return fmt.Errorf("failed to unmarshal next value: %w", err)
-		}
-
-		block = parsed.Block.Block
-		nextBlock = nextParsed.Block.Block
-	} else if runtime == utils.KSyncRuntimeTendermintBsync {
-		if err := json.Unmarshal(value, &block); err != nil {
-			return fmt.Errorf("failed to unmarshal value: %w", err)
-		}
-
-		if err := json.Unmarshal(nextValue, &nextBlock); err != nil {
-			return fmt.Errorf("failed to unmarshal next value: %w", err)
-		}
-	} else {
-		return fmt.Errorf("runtime %s unknown", runtime)
-	}
-
-	peerAddress := engine.config.P2P.ListenAddress
-	peerHost, err := url.Parse(peerAddress)
-	if err != nil {
-		return fmt.Errorf("invalid peer address: %w", err)
-	}
-
-	port, err := strconv.ParseInt(peerHost.Port(), 10, 64)
-	if err != nil {
-		return fmt.Errorf("invalid peer port: %w", err)
-	}
-
-	// this peer should listen to different port to avoid port collision
-	engine.config.P2P.ListenAddress = fmt.Sprintf("tcp://%s:%d", peerHost.Hostname(), port-1)
-
-	nodeKey, err := cometP2P.LoadNodeKey(engine.config.NodeKeyFile())
-	if err != nil {
-		return fmt.Errorf("failed to load node key file: %w", err)
-	}
-
-	// generate new node key for this peer
-	ksyncNodeKey := &cometP2P.NodeKey{
-		PrivKey: ed25519.GenPrivKey(),
-	}
-
-	nodeInfo, err := MakeNodeInfo(engine.config, ksyncNodeKey, engine.genDoc)
-	transport := cometP2P.NewMultiplexTransport(nodeInfo, *ksyncNodeKey, cometP2P.MConnConfig(engine.config.P2P))
-	bcR := NewBlockchainReactor(block, nextBlock)
-	sw := CreateSwitch(engine.config, transport, bcR, nodeInfo, ksyncNodeKey, cometLogger)
-
-	// start the transport
-	addr, err := cometP2P.NewNetAddressString(cometP2P.IDAddressString(ksyncNodeKey.ID(), engine.config.P2P.ListenAddress))
-	if err != nil {
-		return fmt.Errorf("failed to start transport: %w", err)
-	}
-	if err := transport.Listen(*addr); err != nil {
-		return fmt.Errorf("failed to start transport: %w", err)
-	}
-
-	persistentPeers := make([]string, 0)
-	peerString := fmt.Sprintf("%s@%s:%s", nodeKey.ID(), peerHost.Hostname(), peerHost.Port())
-	persistentPeers = append(persistentPeers, peerString)
-
-	if err := sw.AddPersistentPeers(persistentPeers); err != nil {
-		return fmt.Errorf("could not add persistent peers: %w", err)
-	}
-
-	// start switch
-	if err := sw.Start(); err != nil {
-		return fmt.Errorf("failed to start switch: %w", err)
-	}
-
-	// get peer
-	peer, err := cometP2P.NewNetAddressString(peerString)
-	if err != nil {
-		return fmt.Errorf("invalid peer address: %w", err)
-	}
-
-	if err := sw.DialPeerWithAddress(peer); err != nil {
-		return fmt.Errorf("failed to dial peer: %w", err)
-	}
-
-	return nil
+func (adapter *Adapter) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
 }
 
-func (engine *Engine) GetGenesisPath() string {
-	return engine.config.GenesisFile()
+func (adapter *Adapter) Unmarshal(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
 }
 
-func (engine *Engine) GetGenesisHeight() (int64, error) {
-	defaultDocProvider := nm.DefaultGenesisDocProviderFunc(engine.config)
-	genDoc, err := defaultDocProvider()
-	if err != nil {
-		return 0, err
-	}
-
-	return genDoc.InitialHeight, nil
+func (adapter *Adapter) BlockHeight(block *Block) int64 {
+	return block.Height
 }
 
-func (engine *Engine) GetHeight() int64 {
-	return engine.blockStore.Height()
+func (adapter *Adapter) MakePartSet(block *Block) (*PartSet, error) {
+	return block.MakePartSet(tmTypes.BlockPartSizeBytes)
 }
 
-func (engine *Engine) GetBaseHeight() int64 {
-	return engine.blockStore.Base()
+func (adapter *Adapter) ValidateBlock(block *Block) error {
+	return adapter.blockExecutor.ValidateBlock(adapter.state, block)
 }
 
-func (engine *Engine) GetAppHeight() (int64, error) {
-	socketClient := abciClient.NewSocketClient(engine.config.ProxyApp, false)
-
-	if err := socketClient.Start(); err != nil {
-		return 0, fmt.Errorf("failed to start socket client: %w", err)
-	}
-
-	info, err := socketClient.InfoSync(abciTypes.RequestInfo{})
-	if err != nil {
-		return 0, fmt.Errorf("failed to query info: %w", err)
-	}
-
-	if err := socketClient.Stop(); err != nil {
-		return 0, fmt.Errorf("failed to stop socket client: %w", err)
-	}
-
-	return info.LastBlockHeight, nil
+func (adapter *Adapter) VerifyCommit(block *Block, blockParts *PartSet, nextBlock *Block) error {
+	blockId := tmTypes.BlockID{Hash: block.Hash(), PartSetHeader: blockParts.Header()}
+	return adapter.state.Validators.VerifyCommitLight(adapter.state.ChainID, blockId, block.Height, nextBlock.LastCommit)
 }
 
-func (engine *Engine) GetSnapshots() ([]byte, error) {
-	socketClient := abciClient.NewSocketClient(engine.config.ProxyApp, false)
+func (adapter *Adapter) ApplyBlock(block *Block, blockParts *PartSet, nextBlock *Block) error {
+	blockId := tmTypes.BlockID{Hash: block.Hash(), PartSetHeader: blockParts.Header()}
 
-	if err := socketClient.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start socket client: %w", err)
-	}
-
-	res, err := socketClient.ListSnapshotsSync(abciTypes.RequestListSnapshots{})
+	// execute block against app
+	state, _, err := adapter.blockExecutor.ApplyBlock(adapter.state, blockId, block)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list snapshots: %w", err)
-	}
-
-	if err := socketClient.Stop(); err != nil {
-		return nil, fmt.Errorf("failed to stop socket client: %w", err)
-	}
-
-	if len(res.Snapshots) == 0 {
-		return json.Marshal([]Snapshot{})
+		return err
 	}
 
-	return json.Marshal(res.Snapshots)
+	adapter.state = state
+	return nil
 }
 
-func (engine *Engine) IsSnapshotAvailable(height int64) (bool, error) {
-	socketClient := abciClient.NewSocketClient(engine.config.ProxyApp, false)
-
-	if err := socketClient.Start(); err != nil {
-		return false, fmt.Errorf("failed to start socket client: %w", err)
-	}
-
-	res, err := socketClient.ListSnapshotsSync(abciTypes.RequestListSnapshots{})
-	if err != nil {
-		return false, fmt.Errorf("failed to list snapshots: %w", err)
-	}
+func (adapter *Adapter) SaveBlock(block *Block, blockParts *PartSet, nextBlock *Block) {
+	adapter.blockStore.SaveBlock(block, blockParts, nextBlock.LastCommit)
+}
 
-	if err := socketClient.Stop(); err != nil {
-		return false, fmt.Errorf("failed to stop socket client: %w", err)
-	}
+func (adapter *Adapter) StateHeight() int64 {
+	return adapter.state.LastBlockHeight
+}
 
-	for _, snapshot := range res.Snapshots {
-		if snapshot.Height == uint64(height) {
-			return true, nil
-		}
+func (adapter *Adapter) VerifyAppliedBlock(block *Block, blockParts *PartSet, nextBlock *Block) error {
+	blockId := tmTypes.BlockID{Hash: block.Hash(), PartSetHeader: blockParts.Header()}
+	if !blockId.Equals(adapter.state.LastBlockID) {
+		return fmt.Errorf("block id %s does not match block id %s of the state", blockId, adapter.state.LastBlockID)
 	}
 
-	return false, nil
+	return adapter.state.LastValidators.VerifyCommitLight(adapter.state.ChainID, blockId, block.Height, nextBlock.LastCommit)
 }
 
-func (engine *Engine) GetSnapshotChunk(height, format, chunk int64) ([]byte, error) {
-	socketClient := abciClient.NewSocketClient(engine.config.ProxyApp, false)
-
-	if err := socketClient.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start socket client: %w", err)
-	}
-
-	res, err := socketClient.LoadSnapshotChunkSync(abciTypes.RequestLoadSnapshotChunk{
-		Height: uint64(height),
-		Format: uint32(format),
-		Chunk:  uint32(chunk),
-	})
+func (adapter *Adapter) LoadBlockResults() (*core.BlockResults, error) {
+	abciResponses, err := adapter.stateStore.LoadLastABCIResponse(adapter.state.LastBlockHeight)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load snapshot chunk: %w", err)
+		return nil, err
 	}
 
-	if err := socketClient.Stop(); err != nil {
-		return nil, fmt.Errorf("failed to stop socket client: %w", err)
+	txResults := make([]core.TxResult, 0, len(abciResponses.DeliverTxs))
+	for index, deliverTx := range abciResponses.DeliverTxs {
+		txResults = append(txResults, core.TxResult{
+			Index:     index,
+			Code:      deliverTx.Code,
+			Codespace: deliverTx.Codespace,
+			Log:       deliverTx.Log,
+			Data:      deliverTx.Data,
+			GasWanted: deliverTx.GasWanted,
+			GasUsed:   deliverTx.GasUsed,
+			Events:    toEvents(deliverTx.Events),
+		})
 	}
 
-	return json.Marshal(res.Chunk)
+	return &core.BlockResults{
+		AppHash:     adapter.state.AppHash,
+		ResultsHash: adapter.state.LastResultsHash,
+		TxResults:   txResults,
+		Events:      append(toEvents(abciResponses.BeginBlock.GetEvents()), toEvents(abciResponses.EndBlock.GetEvents())...),
+	}, nil
 }
 
-func (engine *Engine) GetBlock(height int64) ([]byte, error) {
-	block := engine.blockStore.LoadBlock(height)
-	return json.Marshal(block)
+func (adapter *Adapter) ExpectedResults(block *Block) ([]byte, []byte) {
+	return block.LastResultsHash, block.AppHash
 }
 
-func (engine *Engine) StartRPCServer() {
-	// wait until all reactors have been booted
-	for engine.blockExecutor == nil {
-		time.Sleep(1000)
-	}
-
-	rpcLogger := cometLogger.With("module", "rpc-server")
-
-	consensusReactor := cs.NewReactor(cs.NewState(
-		engine.config.Consensus,
-		engine.state.Copy(),
-		engine.blockExecutor,
-		engine.blockStore,
-		*engine.mempool,
-		engine.evidencePool,
-	), false, cs.ReactorMetrics(cs.NopMetrics()))
-
-	nodeKey, err := cometP2P.LoadNodeKey(engine.config.NodeKeyFile())
-	if err != nil {
-		cometLogger.Error(fmt.Sprintf("failed to get nodeKey: %s", err))
-		return
-	}
-	nodeInfo, err := MakeNodeInfo(engine.config, nodeKey, engine.genDoc)
-	if err != nil {
-		cometLogger.Error(fmt.Sprintf("failed to get nodeInfo: %s", err))
-		return
-	}
-
-	rpccore.SetEnvironment(&rpccore.Environment{
-		ProxyAppQuery:    nil,
-		ProxyAppMempool:  nil,
-		StateStore:       engine.stateStore,
-		BlockStore:       engine.blockStore,
-		EvidencePool:     nil,
-		ConsensusState:   nil,
-		P2PPeers:         nil,
-		P2PTransport:     &Transport{nodeInfo: nodeInfo},
-		PubKey:           engine.privValidatorKey,
-		GenDoc:           nil,
-		TxIndexer:        nil,
-		BlockIndexer:     nil,
-		ConsensusReactor: consensusReactor,
-		EventBus:         nil,
-		Mempool:          nil,
-		Logger:           rpcLogger,
-		Config:           *engine.config.RPC,
-	})
-
-	routes := map[string]*rpcserver.RPCFunc{
-		"status":        rpcserver.NewRPCFunc(rpccore.Status, ""),
-		"block":         rpcserver.NewRPCFunc(rpccore.Block, "height"),
-		"block_results": rpcserver.NewRPCFunc(rpccore.BlockResults, "height"),
-	}
+func (adapter *Adapter) GetHeight() int64 {
+	return adapter.blockStore.Height()
+}
 
-	mux := http.NewServeMux()
-	config := rpcserver.DefaultConfig()
+func (adapter *Adapter) GetBaseHeight() int64 {
+	return adapter.blockStore.Base()
+}
 
-	rpcserver.RegisterRPCFuncs(mux, routes, rpcLogger)
-	listener, err := rpcserver.Listen(fmt.Sprintf("tcp://127.0.0.1:%d", engine.RpcServerPort), config)
-	if err != nil {
-		cometLogger.Error(fmt.Sprintf("failed to get rpc listener: %s", err))
-		return
-	}
+func (adapter *Adapter) LoadBlock(height int64) *Block {
+	return adapter.blockStore.LoadBlock(height)
+}
 
-	if err := rpcserver.Serve(listener, mux, rpcLogger, config); err != nil {
-		cometLogger.Error(fmt.Sprintf("failed to start rpc server: %s", err))
-		return
-	}
+func (adapter *Adapter) LoadSeenCommit(height int64) interface{} {
+	return adapter.blockStore.LoadBlock(height + 1).LastCommit
 }
 
-func (engine *Engine) GetState(height int64) ([]byte, error) {
+func (adapter *Adapter) LoadState(height int64) (interface{}, error) {
 	initialHeight := height
 	if initialHeight == 0 {
 		initialHeight = 1
 	}
 
-	lastBlock := engine.blockStore.LoadBlock(height)
-	currentBlock := engine.blockStore.LoadBlock(height + 1)
-	nextBlock := engine.blockStore.LoadBlock(height + 2)
+	lastBlock := adapter.blockStore.LoadBlock(height)
+	currentBlock := adapter.blockStore.LoadBlock(height + 1)
+	nextBlock := adapter.blockStore.LoadBlock(height + 2)
 
-	lastValidators, err := engine.stateStore.LoadValidators(height)
+	lastValidators, err := adapter.stateStore.LoadValidators(height)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load validators at height %d: %w", height, err)
 	}
 
-	currentValidators, err := engine.stateStore.LoadValidators(height + 1)
+	currentValidators, err := adapter.stateStore.LoadValidators(height + 1)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load validators at height %d: %w", height+1, err)
 	}
 
-	nextValidators, err := engine.stateStore.LoadValidators(height + 2)
+	nextValidators, err := adapter.stateStore.LoadValidators(height + 2)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load validators at height %d: %w", height+2, err)
 	}
 
-	consensusParams, err := engine.stateStore.LoadConsensusParams(height + 2)
+	consensusParams, err := adapter.stateStore.LoadConsensusParams(height + 2)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load consensus params at height %d: %w", height, err)
 	}
 
-	snapshotState := tmState.State{
+	return tmState.State{
 		Version: tmProtoState.Version{
 			Consensus: lastBlock.Version,
 		},
@@ -643,173 +376,177 @@ func (engine *Engine) GetState(height int64) ([]byte, error) {
 		LastHeightConsensusParamsChanged: currentBlock.Height,
 		LastResultsHash:                  currentBlock.LastResultsHash,
 		AppHash:                          currentBlock.AppHash,
-	}
-
-	return json.Marshal(snapshotState)
-}
-
-func (engine *Engine) GetSeenCommit(height int64) ([]byte, error) {
-	block := engine.blockStore.LoadBlock(height + 1)
-	return json.Marshal(block.LastCommit)
+	}, nil
 }
 
-func (engine *Engine) OfferSnapshot(value []byte) (string, uint32, error) {
-	var bundle TendermintSsyncBundle
+func (adapter *Adapter) BootstrapState(stateValue, blockValue, seenCommitValue []byte) error {
+	var state tmState.State
+	if err := json.Unmarshal(stateValue, &state); err != nil {
+		return fmt.Errorf("failed to unmarshal state: %w", err)
+	}
 
-	if err := json.Unmarshal(value, &bundle); err != nil {
-		return abciTypes.ResponseOfferSnapshot_UNKNOWN.String(), 0, fmt.Errorf("failed to unmarshal tendermint-ssync bundle: %w", err)
+	var block *Block
+	if err := json.Unmarshal(blockValue, &block); err != nil {
+		return fmt.Errorf("failed to unmarshal block: %w", err)
 	}
 
-	socketClient := abciClient.NewSocketClient(engine.config.ProxyApp, false)
+	var seenCommit *tmTypes.Commit
+	if err := json.Unmarshal(seenCommitValue, &seenCommit); err != nil {
+		return fmt.Errorf("failed to unmarshal seen commit: %w", err)
+	}
 
-	if err := socketClient.Start(); err != nil {
-		return abciTypes.ResponseOfferSnapshot_UNKNOWN.String(), 0, fmt.Errorf("failed to start socket client: %w", err)
+	if err := adapter.stateStore.Bootstrap(state); err != nil {
+		return fmt.Errorf("failed to bootstrap state: %w", err)
 	}
 
-	res, err := socketClient.OfferSnapshotSync(abciTypes.RequestOfferSnapshot{
-		Snapshot: bundle[0].Value.Snapshot,
-		AppHash:  bundle[0].Value.State.AppHash,
-	})
+	if err := adapter.blockStore.SaveSeenCommit(state.LastBlockHeight, seenCommit); err != nil {
+		return fmt.Errorf("failed to save seen commit: %w", err)
+	}
 
+	blockParts, err := block.MakePartSet(tmTypes.BlockPartSizeBytes)
 	if err != nil {
-		return abciTypes.ResponseOfferSnapshot_UNKNOWN.String(), 0, err
+		return fmt.Errorf("failed make part set of block: %w", err)
 	}
 
-	if err := socketClient.Stop(); err != nil {
-		return abciTypes.ResponseOfferSnapshot_UNKNOWN.String(), 0, fmt.Errorf("failed to stop socket client: %w", err)
-	}
+	adapter.blockStore.SaveBlock(block, blockParts, seenCommit)
 
-	return res.Result.String(), bundle[0].Value.Snapshot.Chunks, nil
+	return nil
 }
 
-func (engine *Engine) ApplySnapshotChunk(chunkIndex uint32, value []byte) (string, error) {
-	var bundle TendermintSsyncBundle
-
-	if err := json.Unmarshal(value, &bundle); err != nil {
-		return abciTypes.ResponseApplySnapshotChunk_UNKNOWN.String(), fmt.Errorf("failed to unmarshal tendermint-ssync bundle: %w", err)
-	}
-
-	nodeKey, err := cometP2P.LoadNodeKey(engine.config.NodeKeyFile())
+func (adapter *Adapter) PruneBlocks(toHeight int64) error {
+	blocksPruned, err := adapter.blockStore.PruneBlocks(toHeight)
 	if err != nil {
-		return abciTypes.ResponseApplySnapshotChunk_UNKNOWN.String(), fmt.Errorf("loading node key file failed: %w", err)
+		return fmt.Errorf("failed to prune blocks up to %d: %s", toHeight, err)
 	}
 
-	socketClient := abciClient.NewSocketClient(engine.config.ProxyApp, false)
+	base := toHeight - int64(blocksPruned)
 
-	if err := socketClient.Start(); err != nil {
-		return abciTypes.ResponseApplySnapshotChunk_UNKNOWN.String(), fmt.Errorf("failed to start socket client: %w", err)
+	if toHeight > base {
+		if err := adapter.stateStore.PruneStates(base, toHeight); err != nil {
+			return fmt.Errorf("failed to prune state up to %d: %s", toHeight, err)
+		}
 	}
 
-	res, err := socketClient.ApplySnapshotChunkSync(abciTypes.RequestApplySnapshotChunk{
-		Index:  chunkIndex,
-		Chunk:  bundle[0].Value.Chunk,
-		Sender: string(nodeKey.ID()),
-	})
+	return nil
+}
 
+func (adapter *Adapter) LoadNodeId() (string, error) {
+	nodeKey, err := cometP2P.LoadNodeKey(adapter.config.NodeKeyFile())
 	if err != nil {
-		return abciTypes.ResponseApplySnapshotChunk_UNKNOWN.String(), err
-	}
-
-	if err := socketClient.Stop(); err != nil {
-		return abciTypes.ResponseApplySnapshotChunk_UNKNOWN.String(), fmt.Errorf("failed to stop socket client: %w", err)
+		return "", err
 	}
 
-	return res.Result.String(), nil
+	return string(nodeKey.ID()), nil
 }
 
-func (engine *Engine) BootstrapState(value []byte) error {
-	var bundle TendermintSsyncBundle
+func (adapter *Adapter) StartP2PPeer(block, nextBlock *Block, listenAddress, peerAddress string) error {
+	adapter.config.P2P.ListenAddress = listenAddress
 
-	if err := json.Unmarshal(value, &bundle); err != nil {
-		return fmt.Errorf("failed to unmarshal tendermint-ssync bundle: %w", err)
+	// generate new node key for this peer
+	ksyncNodeKey := &cometP2P.NodeKey{
+		PrivKey: ed25519.GenPrivKey(),
 	}
 
-	err := engine.stateStore.Bootstrap(*bundle[0].Value.State)
+	nodeInfo, err := MakeNodeInfo(adapter.config, ksyncNodeKey, adapter.genDoc)
 	if err != nil {
-		return fmt.Errorf("failed to bootstrap state: %w", err)
+		return fmt.Errorf("failed to make node info: %w", err)
 	}
 
-	err = engine.blockStore.SaveSeenCommit(bundle[0].Value.State.LastBlockHeight, bundle[0].Value.SeenCommit)
-	if err != nil {
-		return fmt.Errorf("failed to save seen commit: %w", err)
-	}
+	transport := cometP2P.NewMultiplexTransport(nodeInfo, *ksyncNodeKey, cometP2P.MConnConfig(adapter.config.P2P))
+	bcR := NewBlockchainReactor(core.NewBlockPeer[Block, PartSet](adapter, block, nextBlock, (*Block).ToProto))
+	sw := CreateSwitch(adapter.config, transport, bcR, nodeInfo, ksyncNodeKey, cometLogger)
 
-	blockParts, err := bundle[0].Value.Block.MakePartSet(tmTypes.BlockPartSizeBytes)
+	// start the transport
+	addr, err := cometP2P.NewNetAddressString(cometP2P.IDAddressString(ksyncNodeKey.ID(), listenAddress))
 	if err != nil {
-		return fmt.Errorf("failed make part set of block: %w", err)
+		return fmt.Errorf("failed to start transport: %w", err)
+	}
+	if err := transport.Listen(*addr); err != nil {
+		return fmt.Errorf("failed to start transport: %w", err)
 	}
 
-	engine.blockStore.SaveBlock(bundle[0].Value.Block, blockParts, bundle[0].Value.SeenCommit)
+	if err := sw.AddPersistentPeers([]string{peerAddress}); err != nil {
+		return fmt.Errorf("could not add persistent peers: %w", err)
+	}
 
-	return nil
-}
+	// start switch
+	if err := sw.Start(); err != nil {
+		return fmt.Errorf("failed to start switch: %w", err)
+	}
 
-func (engine *Engine) PruneBlocks(toHeight int64) error {
-	blocksPruned, err := engine.blockStore.PruneBlocks(toHeight)
+	// get peer
+	peer, err := cometP2P.NewNetAddressString(peerAddress)
 	if err != nil {
-		return fmt.Errorf("failed to prune blocks up to %d: %s", toHeight, err)
+		return fmt.Errorf("invalid peer address: %w", err)
 	}
 
-	base := toHeight - int64(blocksPruned)
-
-	if toHeight > base {
-		if err := engine.stateStore.PruneStates(base, toHeight); err != nil {
-			return fmt.Errorf("failed to prune state up to %d: %s", toHeight, err)
-		}
+	if err := sw.DialPeerWithAddress(peer); err != nil {
+		return fmt.Errorf("failed to dial peer: %w", err)
 	}
 
 	return nil
 }
 
-func (engine *Engine) ResetAll(keepAddrBook bool) error {
-	config, err := LoadConfig(engine.HomePath)
+func (adapter *Adapter) StartRPCServer(listener net.Listener) error {
+	rpcLogger := cometLogger.With("module", "rpc-server")
+
+	consensusReactor := cs.NewReactor(cs.NewState(
+		adapter.config.Consensus,
+		adapter.state.Copy(),
+		adapter.blockExecutor,
+		adapter.blockStore,
+		*adapter.mempool,
+		adapter.evidencePool,
+	), false, cs.ReactorMetrics(cs.NopMetrics()))
+
+	nodeKey, err := cometP2P.LoadNodeKey(adapter.config.NodeKeyFile())
 	if err != nil {
-		return fmt.Errorf("failed to load config.toml: %w", err)
+		return fmt.Errorf("failed to get nodeKey: %w", err)
+	}
+	nodeInfo, err := MakeNodeInfo(adapter.config, nodeKey, adapter.genDoc)
+	if err != nil {
+		return fmt.Errorf("failed to get nodeInfo: %w", err)
 	}
 
-	dbDir := config.DBDir()
-	addrBookFile := config.P2P.AddrBookFile()
-	privValKeyFile := config.PrivValidatorKeyFile()
-	privValStateFile := config.PrivValidatorStateFile()
+	rpccore.SetEnvironment(&rpccore.Environment{
+		ProxyAppQuery:    nil,
+		ProxyAppMempool:  nil,
+		StateStore:       adapter.stateStore,
+		BlockStore:       adapter.blockStore,
+		EvidencePool:     nil,
+		ConsensusState:   nil,
+		P2PPeers:         nil,
+		P2PTransport:     &Transport{nodeInfo: nodeInfo},
+		PubKey:           adapter.privValidatorKey,
+		GenDoc:           nil,
+		TxIndexer:        nil,
+		BlockIndexer:     nil,
+		ConsensusReactor: consensusReactor,
+		EventBus:         nil,
+		Mempool:          nil,
+		Logger:           rpcLogger,
+		Config:           *adapter.config.RPC,
+	})
 
-	if keepAddrBook {
-		cometLogger.Info("the address book remains intact")
-	} else {
-		if err := os.Remove(addrBookFile); err == nil {
-			cometLogger.Info("removed existing address book", "file", addrBookFile)
-		} else if !os.IsNotExist(err) {
-			return fmt.Errorf("error removing address book, file: %s, err: %w", addrBookFile, err)
-		}
+	routes := map[string]*rpcserver.RPCFunc{
+		"status":        rpcserver.NewRPCFunc(rpccore.Status, ""),
+		"block":         rpcserver.NewRPCFunc(rpccore.Block, "height"),
+		"block_results": rpcserver.NewRPCFunc(rpccore.BlockResults, "height"),
 	}
 
-	if err := os.RemoveAll(dbDir); err == nil {
-		cometLogger.Info("removed all blockchain history", "dir", dbDir)
-	} else {
-		return fmt.Errorf("error removing all blockchain history, dir: %s, err: %w", dbDir, err)
-	}
+	mux := http.NewServeMux()
+	config := rpcserver.DefaultConfig()
 
-	if err := cmtos.EnsureDir(dbDir, 0700); err != nil {
-		return fmt.Errorf("unable to recreate dbDir, err: %w", err)
-	}
+	rpcserver.RegisterRPCFuncs(mux, routes, rpcLogger)
+	return rpcserver.Serve(listener, mux, rpcLogger, config)
+}
 
-	// recreate the dbDir since the privVal state needs to live there
-	if _, err := os.Stat(privValKeyFile); err == nil {
-		pv := privval.LoadFilePVEmptyState(privValKeyFile, privValStateFile)
-		pv.Reset()
-		cometLogger.Info(
-			"Reset private validator file to genesis state",
-			"keyFile", privValKeyFile,
-			"stateFile", privValStateFile,
-		)
-	} else {
-		pv := privval.GenFilePV(privValKeyFile, privValStateFile)
-		pv.Save()
-		cometLogger.Info(
-			"Generated private validator file",
-			"keyFile", privValKeyFile,
-			"stateFile", privValStateFile,
-		)
-	}
+func (adapter *Adapter) ResetPrivValidator(keyFile, stateFile string) error {
+	privval.LoadFilePVEmptyState(keyFile, stateFile).Reset()
+	return nil
+}
 
+func (adapter *Adapter) GeneratePrivValidator(keyFile, stateFile string) error {
+	privval.GenFilePV(keyFile, stateFile).Save()
 	return nil
 }
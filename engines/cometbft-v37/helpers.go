@@ -12,6 +12,7 @@ import (
 	sm "github.com/KYVENetwork/cometbft/v37/state"
 	"github.com/KYVENetwork/cometbft/v37/store"
 	cometTypes "github.com/KYVENetwork/cometbft/v37/types"
+	"github.com/KYVENetwork/ksync/engines/core"
 	dbm "github.com/cometbft/cometbft-db"
 	"github.com/spf13/viper"
 	"path/filepath"
@@ -45,7 +46,7 @@ func LoadConfig(homePath string) (*cfg.Config, error) {
 
 func DefaultDBProvider(ctx *DBContext) (dbm.DB, error) {
 	dbType := dbm.BackendType(ctx.Config.DBBackend)
-	return dbm.NewDB(ctx.ID, dbType, ctx.Config.DBDir())
+	return core.NewLegacyDB(ctx.ID, dbType, ctx.Config.DBDir())
 }
 
 func GetStateDBs(config *Config) (dbm.DB, state.Store, error) {
@@ -72,8 +73,8 @@ func GetBlockstoreDBs(config *Config) (dbm.DB, *store.BlockStore, error) {
 	return blockStoreDB, blockStore, nil
 }
 
-func CreateAndStartProxyAppConns(config *Config) (proxy.AppConns, error) {
-	proxyApp := proxy.NewAppConns(proxy.DefaultClientCreator(config.ProxyApp, config.ABCI, config.DBDir()), proxy.NopMetrics())
+func CreateAndStartProxyAppConns(config *Config, address, transport string) (proxy.AppConns, error) {
+	proxyApp := proxy.NewAppConns(proxy.DefaultClientCreator(address, transport, config.DBDir()), proxy.NopMetrics())
 	proxyApp.SetLogger(cometLogger.With("module", "proxy"))
 	if err := proxyApp.Start(); err != nil {
 		return nil, fmt.Errorf("error starting proxy app connections: %v", err)
@@ -1,48 +1,20 @@
 package cometbft_v37
 
 import (
-	"fmt"
 	"github.com/KYVENetwork/cometbft/v37/libs/log"
-	klogger "github.com/KYVENetwork/ksync/utils"
-	"github.com/rs/zerolog"
+	"github.com/KYVENetwork/ksync/engines/core"
 )
 
 func CometLogger() (logger log.Logger) {
-	logger = KsyncCometLogger{logger: klogger.LogFormatter("")}
+	logger = KsyncCometLogger{Logger: core.NewLogger()}
 	return
 }
 
 type KsyncCometLogger struct {
-	logger zerolog.Logger
-}
-
-func (l KsyncCometLogger) Debug(msg string, keyvals ...interface{}) {}
-
-func (l KsyncCometLogger) Info(msg string, keyvals ...interface{}) {
-	logger := l.logger.Info()
-
-	for i := 0; i < len(keyvals); i = i + 2 {
-		if keyvals[i] == "hash" || keyvals[i] == "appHash" {
-			logger = logger.Str(fmt.Sprintf("%v", keyvals[i]), fmt.Sprintf("%x", keyvals[i+1]))
-		} else {
-			logger = logger.Str(fmt.Sprintf("%v", keyvals[i]), fmt.Sprintf("%v", keyvals[i+1]))
-		}
-	}
-
-	logger.Msg(msg)
-}
-
-func (l KsyncCometLogger) Error(msg string, keyvals ...interface{}) {
-	logger := l.logger.Error()
-
-	for i := 0; i < len(keyvals); i = i + 2 {
-		logger = logger.Str(fmt.Sprintf("%v", keyvals[i]), fmt.Sprintf("%v", keyvals[i+1]))
-	}
-
-	logger.Msg(msg)
+	core.Logger
 }
 
 func (l KsyncCometLogger) With(keyvals ...interface{}) (logger log.Logger) {
-	logger = KsyncCometLogger{logger: klogger.LogFormatter(keyvals)}
+	logger = KsyncCometLogger{Logger: core.NewLogger(keyvals...)}
 	return
 }
@@ -1,37 +1,33 @@
 package cometbft_v37
 
 import (
-	"fmt"
 	bc "github.com/KYVENetwork/cometbft/v37/blocksync"
 	bcv0 "github.com/KYVENetwork/cometbft/v37/blocksync"
 	cometLog "github.com/KYVENetwork/cometbft/v37/libs/log"
 	"github.com/KYVENetwork/cometbft/v37/p2p"
 	bcproto "github.com/KYVENetwork/cometbft/v37/proto/cometbft/v37/blocksync"
+	cometproto "github.com/KYVENetwork/cometbft/v37/proto/cometbft/v37/types"
 	sm "github.com/KYVENetwork/cometbft/v37/state"
 	"github.com/KYVENetwork/cometbft/v37/version"
-	log "github.com/KYVENetwork/ksync/utils"
-	"reflect"
+	"github.com/KYVENetwork/ksync/engines/core"
 )
 
 const (
 	BlocksyncChannel = byte(0x40)
 )
 
-var (
-	logger = log.KsyncLogger("p2p")
-)
+// BlockPeer serves the block and the next block to the node
+type BlockPeer = core.BlockPeer[Block, PartSet, *cometproto.Block]
 
 type BlockchainReactor struct {
 	p2p.BaseReactor
 
-	block     *Block
-	nextBlock *Block
+	peer *BlockPeer
 }
 
-func NewBlockchainReactor(block *Block, nextBlock *Block) *BlockchainReactor {
+func NewBlockchainReactor(peer *BlockPeer) *BlockchainReactor {
 	bcR := &BlockchainReactor{
-		block:     block,
-		nextBlock: nextBlock,
+		peer: peer,
 	}
 	bcR.BaseReactor = *p2p.NewBaseReactor("BlockchainReactor", bcR)
 	return bcR
@@ -50,53 +46,6 @@ func (bcR *BlockchainReactor) GetChannels() []*p2p.ChannelDescriptor {
 	}
 }
 
-func (bcR *BlockchainReactor) sendStatusToPeer(src p2p.Peer) (queued bool) {
-	logger.Info().Int64("base", bcR.block.Height).Int64("height", bcR.block.Height+1).Msg("Sent status to peer")
-
-	return src.SendEnvelope(p2p.Envelope{
-		ChannelID: BlocksyncChannel,
-		Message: &bcproto.StatusResponse{
-			Base:   bcR.block.Height,
-			Height: bcR.block.Height + 1,
-		},
-	})
-}
-
-func (bcR *BlockchainReactor) sendBlockToPeer(msg *bcproto.BlockRequest, src p2p.Peer) (queued bool) {
-	if msg.Height == bcR.block.Height {
-		bl, err := bcR.block.ToProto()
-		if err != nil {
-			logger.Error().Str("could not convert msg to protobuf", err.Error())
-			return false
-		}
-
-		logger.Info().Msg(fmt.Sprintf("sent block with height %d to peer", bcR.block.Height))
-
-		return src.TrySendEnvelope(p2p.Envelope{
-			ChannelID: BlocksyncChannel,
-			Message:   &bcproto.BlockResponse{Block: bl},
-		})
-	}
-
-	if msg.Height == bcR.nextBlock.Height {
-		bl, err := bcR.nextBlock.ToProto()
-		if err != nil {
-			logger.Error().Str("could not convert msg to protobuf", err.Error())
-			return false
-		}
-
-		logger.Info().Msg(fmt.Sprintf("sent block with height %d to peer", bcR.nextBlock.Height))
-
-		return src.TrySendEnvelope(p2p.Envelope{
-			ChannelID: BlocksyncChannel,
-			Message:   &bcproto.BlockResponse{Block: bl},
-		})
-	}
-
-	logger.Error().Msg(fmt.Sprintf("peer asked for different block, expected = %d,%d, requested %d", bcR.block.Height, bcR.nextBlock.Height, msg.Height))
-	return false
-}
-
 func (bcR *BlockchainReactor) ReceiveEnvelope(e p2p.Envelope) {
 	if err := bc.ValidateMsg(e.Message); err != nil {
 		bcR.Logger.Error("Peer sent us invalid msg", "peer", e.Src, "msg", e.Message, "err", err)
@@ -106,15 +55,22 @@ func (bcR *BlockchainReactor) ReceiveEnvelope(e p2p.Envelope) {
 
 	switch msg := e.Message.(type) {
 	case *bcproto.StatusRequest:
-		logger.Info().Msg("Incoming status request")
-		bcR.sendStatusToPeer(e.Src)
+		base, height := bcR.peer.Status()
+		e.Src.SendEnvelope(p2p.Envelope{
+			ChannelID: BlocksyncChannel,
+			Message:   &bcproto.StatusResponse{Base: base, Height: height},
+		})
 	case *bcproto.BlockRequest:
-		logger.Info().Int64("height", msg.Height).Msg("Incoming block request")
-		bcR.sendBlockToPeer(msg, e.Src)
+		if bl, ok := bcR.peer.Block(msg.Height); ok {
+			e.Src.TrySendEnvelope(p2p.Envelope{
+				ChannelID: BlocksyncChannel,
+				Message:   &bcproto.BlockResponse{Block: bl},
+			})
+		}
 	case *bcproto.StatusResponse:
-		logger.Info().Int64("base", msg.Base).Int64("height", msg.Height).Msgf("Incoming status response")
+		bcR.peer.StatusResponse(msg.Base, msg.Height)
 	default:
-		logger.Error().Msg(fmt.Sprintf("Unknown message type %v", reflect.TypeOf(msg)))
+		bcR.peer.UnknownMessage(msg)
 	}
 }
 
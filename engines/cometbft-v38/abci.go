@@ -0,0 +1,99 @@
+package cometbft_v38
+
+import (
+	"context"
+	abciClient "github.com/KYVENetwork/cometbft/v38/abci/client"
+	abciTypes "github.com/KYVENetwork/cometbft/v38/abci/types"
+	"github.com/KYVENetwork/ksync/engines/core"
+)
+
+// abciConn sends queries and snapshots to the app over a socket or gRPC connection
+type abciConn struct {
+	client abciClient.Client
+}
+
+func NewABCIClient(address, transport string) (core.ABCIClient, error) {
+	client, err := abciClient.NewClient(address, transport, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.NewABCIClient[*abciTypes.Snapshot](client, &abciConn{client: client}), nil
+}
+
+func (c *abciConn) Info() (int64, error) {
+	res, err := c.client.Info(context.Background(), &abciTypes.RequestInfo{})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastBlockHeight, nil
+}
+
+func (c *abciConn) ListSnapshots() ([]*abciTypes.Snapshot, error) {
+	res, err := c.client.ListSnapshots(context.Background(), &abciTypes.RequestListSnapshots{})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Snapshots, nil
+}
+
+func (c *abciConn) LoadSnapshotChunk(height uint64, format, chunk uint32) ([]byte, error) {
+	res, err := c.client.LoadSnapshotChunk(context.Background(), &abciTypes.RequestLoadSnapshotChunk{
+		Height: height,
+		Format: format,
+		Chunk:  chunk,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Chunk, nil
+}
+
+func (c *abciConn) OfferSnapshot(snapshot *core.Snapshot, appHash []byte) (string, error) {
+	res, err := c.client.OfferSnapshot(context.Background(), &abciTypes.RequestOfferSnapshot{
+		Snapshot: &abciTypes.Snapshot{
+			Height:   snapshot.Height,
+			Format:   snapshot.Format,
+			Chunks:   snapshot.Chunks,
+			Hash:     snapshot.Hash,
+			Metadata: snapshot.Metadata,
+		},
+		AppHash: appHash,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return res.Result.String(), nil
+}
+
+func (c *abciConn) ApplySnapshotChunk(index uint32, chunk []byte, sender string) (string, error) {
+	res, err := c.client.ApplySnapshotChunk(context.Background(), &abciTypes.RequestApplySnapshotChunk{
+		Index:  index,
+		Chunk:  chunk,
+		Sender: sender,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return res.Result.String(), nil
+}
+
+// toEvents converts the events of the app into the events of a divergence report
+func toEvents(events []abciTypes.Event) []core.Event {
+	converted := make([]core.Event, 0, len(events))
+	for _, event := range events {
+		attributes := make([]core.EventAttribute, 0, len(event.Attributes))
+		for _, attribute := range event.Attributes {
+			attributes = append(attributes, core.EventAttribute{Key: attribute.Key, Value: attribute.Value})
+		}
+
+		converted = append(converted, core.Event{Type: event.Type, Attributes: attributes})
+	}
+
+	return converted
+}
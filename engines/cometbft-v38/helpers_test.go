@@ -0,0 +1,54 @@
+package cometbft_v38
+
+import (
+	cfg "github.com/KYVENetwork/cometbft/v38/config"
+	dbm "github.com/cometbft/cometbft-db"
+	"strings"
+	"testing"
+)
+
+// newConfig returns a config with the given db backend and its data in a temporary directory
+func newConfig(t *testing.T, backend dbm.BackendType) *Config {
+	config := cfg.DefaultConfig()
+	config.SetRoot(t.TempDir())
+	config.DBBackend = string(backend)
+	return config
+}
+
+func TestDefaultDBProviderRejectsUnsupportedBackend(t *testing.T) {
+	_, err := DefaultDBProvider(&DBContext{"state", newConfig(t, dbm.PebbleDBBackend)})
+	if err == nil {
+		t.Fatalf("expected pebbledb to be rejected by a cometbft-db v0.9 engine")
+	}
+
+	if !strings.Contains(err.Error(), string(dbm.PebbleDBBackend)) {
+		t.Errorf("expected the error to name the unsupported backend, got %s", err)
+	}
+}
+
+func TestDefaultDBProviderCopiesIteratorItems(t *testing.T) {
+	db, err := DefaultDBProvider(&DBContext{"state", newConfig(t, dbm.GoLevelDBBackend)})
+	if err != nil {
+		t.Fatalf("failed to open goleveldb: %s", err)
+	}
+	defer db.Close()
+
+	if err := db.Set([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("failed to set value: %s", err)
+	}
+
+	iterator, err := db.Iterator(nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create iterator: %s", err)
+	}
+
+	value := iterator.Value()
+	if err := iterator.Close(); err != nil {
+		t.Fatalf("failed to close iterator: %s", err)
+	}
+
+	// the value has to stay valid after the iterator got closed like in cometbft-db v0.9
+	if string(value) != "value" {
+		t.Errorf("expected value to be kept after closing the iterator, got %s", value)
+	}
+}
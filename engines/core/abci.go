@@ -0,0 +1,118 @@
+package core
+
+const (
+	// SnapshotResultUnknown is the result of offering a snapshot or applying a chunk if
+	// the app could not be asked
+	SnapshotResultUnknown = "UNKNOWN"
+)
+
+// Snapshot is a snapshot of the app, the json tags match the ones of the abci types
+type Snapshot struct {
+	Height   uint64 `json:"height,omitempty"`
+	Format   uint32 `json:"format,omitempty"`
+	Chunks   uint32 `json:"chunks,omitempty"`
+	Hash     []byte `json:"hash,omitempty"`
+	Metadata []byte `json:"metadata,omitempty"`
+}
+
+// ABCIClient is a connection to the app for queries and snapshots. The results of
+// OfferSnapshot and ApplySnapshotChunk are returned without the prefix of the enum,
+// like ACCEPT or REJECT
+type ABCIClient interface {
+	Start() error
+	Stop() error
+
+	// IsRunning returns false once the connection to the app was lost
+	IsRunning() bool
+
+	// Info returns the last block height of the app
+	Info() (int64, error)
+
+	ListSnapshots() ([]*Snapshot, error)
+	LoadSnapshotChunk(height uint64, format, chunk uint32) ([]byte, error)
+	OfferSnapshot(snapshot *Snapshot, appHash []byte) (string, error)
+	ApplySnapshotChunk(index uint32, chunk []byte, sender string) (string, error)
+}
+
+// ABCISnapshot is the snapshot type of a version
+type ABCISnapshot interface {
+	GetHeight() uint64
+	GetFormat() uint32
+	GetChunks() uint32
+	GetHash() []byte
+	GetMetadata() []byte
+}
+
+// ABCIService starts and stops the connection to the app, it is implemented by the
+// abci clients of all versions
+type ABCIService interface {
+	Start() error
+	Stop() error
+	IsRunning() bool
+}
+
+// ABCIConn contains the requests of a version to the app, S is the snapshot type of the
+// version. The results of OfferSnapshot and ApplySnapshotChunk are the names of the enums
+type ABCIConn[S ABCISnapshot] interface {
+	Info() (int64, error)
+	ListSnapshots() ([]S, error)
+	LoadSnapshotChunk(height uint64, format, chunk uint32) ([]byte, error)
+	OfferSnapshot(snapshot *Snapshot, appHash []byte) (string, error)
+	ApplySnapshotChunk(index uint32, chunk []byte, sender string) (string, error)
+}
+
+// NewABCIClient creates the client for queries and snapshots from the abci client of a version
+func NewABCIClient[S ABCISnapshot](service ABCIService, conn ABCIConn[S]) ABCIClient {
+	return &abciClient[S]{ABCIService: service, conn: conn}
+}
+
+type abciClient[S ABCISnapshot] struct {
+	ABCIService
+	conn ABCIConn[S]
+}
+
+func (c *abciClient[S]) Info() (int64, error) {
+	return c.conn.Info()
+}
+
+func (c *abciClient[S]) ListSnapshots() ([]*Snapshot, error) {
+	res, err := c.conn.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*Snapshot, 0, len(res))
+	for _, snapshot := range res {
+		snapshots = append(snapshots, &Snapshot{
+			Height:   snapshot.GetHeight(),
+			Format:   snapshot.GetFormat(),
+			Chunks:   snapshot.GetChunks(),
+			Hash:     snapshot.GetHash(),
+			Metadata: snapshot.GetMetadata(),
+		})
+	}
+
+	return snapshots, nil
+}
+
+func (c *abciClient[S]) LoadSnapshotChunk(height uint64, format, chunk uint32) ([]byte, error) {
+	return c.conn.LoadSnapshotChunk(height, format, chunk)
+}
+
+func (c *abciClient[S]) OfferSnapshot(snapshot *Snapshot, appHash []byte) (string, error) {
+	result, err := c.conn.OfferSnapshot(snapshot, appHash)
+	if err != nil {
+		return SnapshotResultUnknown, err
+	}
+
+	return result, nil
+}
+
+func (c *abciClient[S]) ApplySnapshotChunk(index uint32, chunk []byte, sender string) (string, error) {
+	result, err := c.conn.ApplySnapshotChunk(index, chunk, sender)
+	if err != nil {
+		return SnapshotResultUnknown, err
+	}
+
+	return result, nil
+}
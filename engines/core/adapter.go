@@ -0,0 +1,127 @@
+package core
+
+import (
+	"net"
+)
+
+// Config contains the settings of the config.toml which are the same in every version
+type Config struct {
+	ProxyApp               string
+	AbciTransport          string
+	GenesisFile            string
+	DBDir                  string
+	AddrBookFile           string
+	NodeKeyFile            string
+	PrivValidatorKeyFile   string
+	PrivValidatorStateFile string
+	P2PListenAddress       string
+}
+
+// Adapter contains everything of an engine which depends on the version of Tendermint or
+// CometBFT, like the proto types, the stores and the block executor. B is the block type
+// and P the part set type of the version
+type Adapter[B any, P any] interface {
+	// GetName gets the engine name
+	GetName() string
+
+	// LoadConfig loads the config.toml from the home path
+	LoadConfig(homePath string) (*Config, error)
+
+	// LoadGenesis loads the chain id and the initial height from the genesis file
+	LoadGenesis() (chainId string, initialHeight int64, err error)
+
+	// OpenDBs loads the genesis and the validator key and opens the blockstore and state DBs
+	OpenDBs() error
+
+	// CloseDBs closes the blockstore and state DBs
+	CloseDBs() error
+
+	// StartProxyApp starts the proxy app connections to the app with the given transport
+	StartProxyApp(address, transport string) error
+
+	// StopProxyApp stops the proxy app connections to the app
+	StopProxyApp() error
+
+	// DoHandshake does a handshake with the app and creates the block executor
+	DoHandshake() error
+
+	// NewABCIClient creates a client for queries and snapshots with the given
+	// transport which is not started yet
+	NewABCIClient(address, transport string) (ABCIClient, error)
+
+	// Marshal encodes the value with the JSON encoding of the version
+	Marshal(value interface{}) ([]byte, error)
+
+	// Unmarshal decodes the value with the JSON encoding of the version
+	Unmarshal(data []byte, value interface{}) error
+
+	// BlockHeight returns the height of the block
+	BlockHeight(block *B) int64
+
+	// MakePartSet splits the block into parts
+	MakePartSet(block *B) (*P, error)
+
+	// ValidateBlock validates the block against the current state
+	ValidateBlock(block *B) error
+
+	// VerifyCommit verifies the commit for the block which is included in the next block
+	VerifyCommit(block *B, parts *P, nextBlock *B) error
+
+	// ApplyBlock executes the block against the app without storing the block
+	ApplyBlock(block *B, parts *P, nextBlock *B) error
+
+	// SaveBlock stores the block with the commit of the next block in the block store
+	SaveBlock(block *B, parts *P, nextBlock *B)
+
+	// StateHeight gets the height of the last block applied to the state, which is one
+	// block ahead of the block store if the last applied block was not stored
+	StateHeight() int64
+
+	// VerifyAppliedBlock verifies that the block is the last block applied to the state and
+	// verifies the commit for the block which is included in the next block
+	VerifyAppliedBlock(block *B, parts *P, nextBlock *B) error
+
+	// LoadBlockResults loads the results of the app for the last applied block
+	LoadBlockResults() (*BlockResults, error)
+
+	// ExpectedResults returns the LastResultsHash and the AppHash of the block header,
+	// which are the hashes of the results of the previous block
+	ExpectedResults(block *B) (resultsHash []byte, appHash []byte)
+
+	// GetHeight gets the latest height stored in the blockstore.db
+	GetHeight() int64
+
+	// GetBaseHeight gets the earliest height stored in the blockstore.db
+	GetBaseHeight() int64
+
+	// LoadBlock loads the block from the blockstore.db
+	LoadBlock(height int64) *B
+
+	// LoadSeenCommit loads the commit for the block which is included in the next block
+	LoadSeenCommit(height int64) interface{}
+
+	// LoadState rebuilds the state at the height from the blockstore and state.db
+	LoadState(height int64) (interface{}, error)
+
+	// BootstrapState initializes the state with the state, the block and the seen commit of a snapshot
+	BootstrapState(state, block, seenCommit []byte) error
+
+	// PruneBlocks prunes blocks and states up to the height
+	PruneBlocks(toHeight int64) error
+
+	// LoadNodeId loads the id of the node key
+	LoadNodeId() (string, error)
+
+	// StartP2PPeer starts a peer listening on the address which serves the block and the
+	// next block to the peer of the node
+	StartP2PPeer(block, nextBlock *B, listenAddress, peerAddress string) error
+
+	// StartRPCServer serves /status, /block and /block_results on the listener until it gets closed
+	StartRPCServer(listener net.Listener) error
+
+	// ResetPrivValidator resets the validator state to the genesis state
+	ResetPrivValidator(keyFile, stateFile string) error
+
+	// GeneratePrivValidator creates a new validator key and state
+	GeneratePrivValidator(keyFile, stateFile string) error
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+	dbm "github.com/cometbft/cometbft-db"
+	"slices"
+)
+
+// legacyBackends are the db backends which were available in cometbft-db v0.9. Backends
+// added afterward, like pebbledb, are only supported by the cometbft-v1 engine
+var legacyBackends = []dbm.BackendType{
+	dbm.GoLevelDBBackend,
+	dbm.CLevelDBBackend,
+	dbm.MemDBBackend,
+	dbm.BoltDBBackend,
+	dbm.RocksDBBackend,
+	dbm.BadgerDBBackend,
+}
+
+// NewLegacyDB opens a database for the engines which were built against cometbft-db v0.9.
+// The cometbft-v1 engine requires cometbft-db v1, which only exists once in the binary.
+// Since cometbft-db v0.13 iterators return slices owned by the database instead of copies,
+// so the database returned here copies keys and values like v0.9 did
+func NewLegacyDB(name string, backend dbm.BackendType, dir string) (dbm.DB, error) {
+	if !slices.Contains(legacyBackends, backend) {
+		return nil, fmt.Errorf("db backend %s is not supported by engines built against cometbft-db v0.9, expected one of %v", backend, legacyBackends)
+	}
+
+	db, err := dbm.NewDB(name, backend, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s db with backend %s: %w", name, backend, err)
+	}
+
+	return &legacyDB{DB: db}, nil
+}
+
+type legacyDB struct {
+	dbm.DB
+}
+
+func (db *legacyDB) Iterator(start, end []byte) (dbm.Iterator, error) {
+	iterator, err := db.DB.Iterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &legacyIterator{Iterator: iterator}, nil
+}
+
+func (db *legacyDB) ReverseIterator(start, end []byte) (dbm.Iterator, error) {
+	iterator, err := db.DB.ReverseIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &legacyIterator{Iterator: iterator}, nil
+}
+
+// legacyIterator returns copies of the keys and values which can be kept and modified
+type legacyIterator struct {
+	dbm.Iterator
+}
+
+func (iterator *legacyIterator) Key() []byte {
+	return append([]byte{}, iterator.Iterator.Key()...)
+}
+
+func (iterator *legacyIterator) Value() []byte {
+	return append([]byte{}, iterator.Iterator.Value()...)
+}
@@ -0,0 +1,722 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"golang.org/x/net/netutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// rpcServerMaxOpenConnections limits the connections to the rpc server, like the
+	// listener of the rpc server of the node does
+	rpcServerMaxOpenConnections = 10
+)
+
+// Engine implements types.Engine for every version of Tendermint and CometBFT. Everything
+// which depends on the version is done by the adapter, so a new version only has to
+// implement an adapter
+type Engine[B any, P any] struct {
+	types.EngineConfig
+
+	adapter    Adapter[B, P]
+	config     *Config
+	areDBsOpen bool
+
+	prevBlock     *B
+	handshakeDone atomic.Bool
+
+	// rpcListener is the listener of the rpc server, it gets closed to stop the server
+	rpcListener   net.Listener
+	rpcListenerMu sync.Mutex
+
+	// abciClient is a long-lived connection to the app for queries and snapshots
+	// which is opened again on the next call once the connection was lost
+	abciClient   ABCIClient
+	abciClientMu sync.Mutex
+
+	// nodeId is loaded once from the node key file when the first chunk is applied
+	nodeId string
+}
+
+func NewEngine[B any, P any](adapter Adapter[B, P], engineConfig types.EngineConfig) *Engine[B, P] {
+	return &Engine[B, P]{
+		EngineConfig: engineConfig,
+		adapter:      adapter,
+	}
+}
+
+func (engine *Engine[B, P]) GetName() string {
+	return engine.adapter.GetName()
+}
+
+func (engine *Engine[B, P]) LoadConfig() error {
+	if engine.config != nil {
+		return nil
+	}
+
+	config, err := engine.adapter.LoadConfig(engine.HomePath)
+	if err != nil {
+		return fmt.Errorf("failed to load config.toml: %w", err)
+	}
+
+	address, transport, err := resolveAbciTransport(config.ProxyApp, config.AbciTransport, engine.AbciTransport)
+	if err != nil {
+		return err
+	}
+
+	config.ProxyApp = address
+	config.AbciTransport = transport
+
+	engine.config = config
+	return nil
+}
+
+// resolveAbciTransport returns the proxy app address and the transport used to connect to
+// the app. The transport of the flag is used first, then the transport of a grpc:// address
+// and then the abci setting of the config.toml
+func resolveAbciTransport(address, configTransport, flagTransport string) (string, string, error) {
+	transport := configTransport
+
+	protocol, addr, found := strings.Cut(address, "://")
+	if found && protocol == utils.AbciTransportGrpc {
+		transport = utils.AbciTransportGrpc
+	}
+
+	if flagTransport != "" {
+		transport = flagTransport
+	}
+
+	if transport == "" {
+		transport = utils.AbciTransportSocket
+	}
+
+	switch transport {
+	case utils.AbciTransportSocket:
+		if found && protocol == utils.AbciTransportGrpc {
+			address = fmt.Sprintf("tcp://%s", addr)
+		}
+	case utils.AbciTransportGrpc:
+		// the gRPC clients of newer versions resolve the address as a gRPC target, which
+		// only works for tcp addresses if the address has no scheme
+		if found && (protocol == "tcp" || protocol == utils.AbciTransportGrpc) {
+			address = addr
+		}
+	default:
+		return "", "", fmt.Errorf("abci transport %s unknown", transport)
+	}
+
+	return address, transport, nil
+}
+
+func (engine *Engine[B, P]) OpenDBs() error {
+	if engine.areDBsOpen {
+		return nil
+	}
+
+	if err := engine.LoadConfig(); err != nil {
+		return err
+	}
+
+	if err := utils.FormatGenesisFile(engine.config.GenesisFile); err != nil {
+		return fmt.Errorf("failed to format genesis file: %w", err)
+	}
+
+	if err := engine.adapter.OpenDBs(); err != nil {
+		return err
+	}
+
+	engine.areDBsOpen = true
+	return nil
+}
+
+func (engine *Engine[B, P]) CloseDBs() error {
+	if !engine.areDBsOpen {
+		return nil
+	}
+
+	if err := engine.adapter.CloseDBs(); err != nil {
+		return err
+	}
+
+	engine.areDBsOpen = false
+	return nil
+}
+
+func (engine *Engine[B, P]) GetHomePath() string {
+	return engine.HomePath
+}
+
+func (engine *Engine[B, P]) GetProxyAppAddress() string {
+	return engine.config.ProxyApp
+}
+
+func (engine *Engine[B, P]) GetAbciTransport() string {
+	return engine.config.AbciTransport
+}
+
+func (engine *Engine[B, P]) GetEngineConfig() types.EngineConfig {
+	return engine.EngineConfig
+}
+
+func (engine *Engine[B, P]) StartProxyApp() error {
+	return engine.adapter.StartProxyApp(engine.config.ProxyApp, engine.config.AbciTransport)
+}
+
+func (engine *Engine[B, P]) StopProxyApp() error {
+	if err := engine.stopABCIClient(); err != nil {
+		return fmt.Errorf("failed to stop abci client: %w", err)
+	}
+
+	return engine.adapter.StopProxyApp()
+}
+
+func (engine *Engine[B, P]) GetChainId() (string, error) {
+	if err := engine.LoadConfig(); err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+
+	chainId, _, err := engine.adapter.LoadGenesis()
+	if err != nil {
+		return "", fmt.Errorf("failed to load genDoc: %w", err)
+	}
+
+	return chainId, nil
+}
+
+func (engine *Engine[B, P]) GetContinuationHeight() (int64, error) {
+	height := engine.GetHeight()
+
+	initialHeight, err := utils.GetInitialHeightFromGenesisFile(engine.GetGenesisPath())
+	if err != nil {
+		return 0, fmt.Errorf("failed to load initial height from genesis file: %w", err)
+	}
+
+	continuationHeight := height + 1
+
+	if continuationHeight < initialHeight {
+		continuationHeight = initialHeight
+	}
+
+	return continuationHeight, nil
+}
+
+func (engine *Engine[B, P]) DoHandshake() error {
+	if err := engine.adapter.DoHandshake(); err != nil {
+		return err
+	}
+
+	// the block collector starts again at the continuation height after a handshake, so
+	// a block which was kept from a previous run must not be applied
+	engine.prevBlock = nil
+	engine.handshakeDone.Store(true)
+
+	return nil
+}
+
+func (engine *Engine[B, P]) ApplyBlock(runtime *string, value []byte) error {
+	block, err := engine.parseBlock(runtime, value)
+	if err != nil {
+		return err
+	}
+
+	// if the previous block is not defined we continue
+	if engine.prevBlock == nil {
+		engine.prevBlock = block
+		return nil
+	}
+
+	height := engine.adapter.BlockHeight(engine.prevBlock)
+
+	// get block data
+	blockParts, err := engine.adapter.MakePartSet(engine.prevBlock)
+	if err != nil {
+		return fmt.Errorf("failed make part set of block: %w", err)
+	}
+
+	// the block was applied in a previous run but was not stored, so it only gets verified again
+	if engine.adapter.StateHeight() == height {
+		return engine.storeAppliedBlock(height, blockParts, block)
+	}
+
+	// verify block
+	if err := engine.adapter.ValidateBlock(engine.prevBlock); err != nil {
+		return fmt.Errorf("block validation failed at height %d: %w", height, err)
+	}
+
+	// verify commits
+	if err := engine.adapter.VerifyCommit(engine.prevBlock, blockParts, block); err != nil {
+		return fmt.Errorf("light commit verification failed at height %d: %w", height, err)
+	}
+
+	if engine.VerifyResults {
+		// execute block against app
+		if err := engine.adapter.ApplyBlock(engine.prevBlock, blockParts, block); err != nil {
+			return fmt.Errorf("failed to apply block at height %d: %w", height, err)
+		}
+
+		// compare the results of the app with the hashes in the header of the next block
+		// before the block gets stored, so a diverging block is never committed to the block store.
+		// The app and the state stay one block ahead, on the next start the block gets verified again
+		if err := engine.verifyResults(height, block); err != nil {
+			return err
+		}
+
+		// store block
+		engine.adapter.SaveBlock(engine.prevBlock, blockParts, block)
+	} else {
+		// store block
+		engine.adapter.SaveBlock(engine.prevBlock, blockParts, block)
+
+		// execute block against app
+		if err := engine.adapter.ApplyBlock(engine.prevBlock, blockParts, block); err != nil {
+			return fmt.Errorf("failed to apply block at height %d: %w", height, err)
+		}
+	}
+
+	// update values for next round
+	engine.prevBlock = block
+
+	return nil
+}
+
+// storeAppliedBlock stores the block which the app already committed without executing it again.
+// This is the case if the results of the block diverged or the sync stopped before the block was stored
+func (engine *Engine[B, P]) storeAppliedBlock(height int64, blockParts *P, block *B) error {
+	if err := engine.adapter.VerifyAppliedBlock(engine.prevBlock, blockParts, block); err != nil {
+		return fmt.Errorf("applied block verification failed at height %d: %w", height, err)
+	}
+
+	if engine.VerifyResults {
+		if err := engine.verifyResults(height, block); err != nil {
+			return err
+		}
+	}
+
+	engine.adapter.SaveBlock(engine.prevBlock, blockParts, block)
+	engine.prevBlock = block
+
+	return nil
+}
+
+// AppAheadOfStore checks if the app and the state contain one block more than the block store,
+// which is the case if the last applied block was not stored. The handshake would reject the app,
+// instead the block gets stored without executing it again once it is received
+func AppAheadOfStore(appHeight int64, appHash []byte, stateHeight int64, stateAppHash []byte, storeHeight int64) (bool, error) {
+	if appHeight != storeHeight+1 || stateHeight != appHeight {
+		return false, nil
+	}
+
+	if !bytes.Equal(appHash, stateAppHash) {
+		return false, fmt.Errorf("app hash %X at height %d does not match app hash %X of the state", appHash, appHeight, stateAppHash)
+	}
+
+	logger.Info("app is one block ahead of the block store, the block gets stored without executing it again", "appHeight", appHeight, "storeHeight", storeHeight)
+	return true, nil
+}
+
+// parseBlock decodes the block of a data item of the runtime, if the runtime
+// is nil the value is a block response of another node
+func (engine *Engine[B, P]) parseBlock(runtime *string, value []byte) (*B, error) {
+	if runtime == nil {
+		var blockResponse BlockResponse[B]
+		if err := engine.adapter.Unmarshal(value, &blockResponse); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal block response: %w", err)
+		}
+		return &blockResponse.Result.Block, nil
+	}
+
+	switch *runtime {
+	case utils.KSyncRuntimeTendermint:
+		var parsed TendermintValue[B]
+		if err := engine.adapter.Unmarshal(value, &parsed); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal value: %w", err)
+		}
+		return parsed.Block.Block, nil
+	case utils.KSyncRuntimeTendermintBsync:
+		var block *B
+		if err := engine.adapter.Unmarshal(value, &block); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal value: %w", err)
+		}
+		return block, nil
+	default:
+		return nil, fmt.Errorf("runtime %s unknown", *runtime)
+	}
+}
+
+func (engine *Engine[B, P]) ApplyFirstBlockOverP2P(runtime string, value, nextValue []byte) error {
+	if runtime != utils.KSyncRuntimeTendermint && runtime != utils.KSyncRuntimeTendermintBsync {
+		return fmt.Errorf("runtime %s unknown", runtime)
+	}
+
+	block, err := engine.parseBlock(&runtime, value)
+	if err != nil {
+		return err
+	}
+
+	nextBlock, err := engine.parseBlock(&runtime, nextValue)
+	if err != nil {
+		return fmt.Errorf("failed to parse next block: %w", err)
+	}
+
+	peerHost, err := url.Parse(engine.config.P2PListenAddress)
+	if err != nil {
+		return fmt.Errorf("invalid peer address: %w", err)
+	}
+
+	port, err := strconv.ParseInt(peerHost.Port(), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid peer port: %w", err)
+	}
+
+	// this peer should listen to different port to avoid port collision
+	listenAddress := fmt.Sprintf("tcp://%s:%d", peerHost.Hostname(), port-1)
+
+	nodeId, err := engine.adapter.LoadNodeId()
+	if err != nil {
+		return fmt.Errorf("failed to load node key file: %w", err)
+	}
+
+	peerAddress := fmt.Sprintf("%s@%s:%s", nodeId, peerHost.Hostname(), peerHost.Port())
+
+	return engine.adapter.StartP2PPeer(block, nextBlock, listenAddress, peerAddress)
+}
+
+func (engine *Engine[B, P]) GetGenesisPath() string {
+	return engine.config.GenesisFile
+}
+
+func (engine *Engine[B, P]) GetGenesisHeight() (int64, error) {
+	_, initialHeight, err := engine.adapter.LoadGenesis()
+	if err != nil {
+		return 0, err
+	}
+
+	return initialHeight, nil
+}
+
+func (engine *Engine[B, P]) GetHeight() int64 {
+	return engine.adapter.GetHeight()
+}
+
+func (engine *Engine[B, P]) GetBaseHeight() int64 {
+	return engine.adapter.GetBaseHeight()
+}
+
+// getABCIClient returns the connection to the app for queries and snapshots,
+// a new connection is started if there is none or if it was lost. The dial fails
+// right away if the app is not reachable, so a crashed app is not retried forever
+func (engine *Engine[B, P]) getABCIClient() (ABCIClient, error) {
+	engine.abciClientMu.Lock()
+	defer engine.abciClientMu.Unlock()
+
+	if engine.abciClient != nil && engine.abciClient.IsRunning() {
+		return engine.abciClient, nil
+	}
+
+	client, err := engine.adapter.NewABCIClient(engine.config.ProxyApp, engine.config.AbciTransport)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s abci client: %w", engine.config.AbciTransport, err)
+	}
+
+	if err := client.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s abci client: %w", engine.config.AbciTransport, err)
+	}
+
+	engine.abciClient = client
+	return client, nil
+}
+
+// stopABCIClient closes the connection to the app for queries and snapshots
+func (engine *Engine[B, P]) stopABCIClient() error {
+	engine.abciClientMu.Lock()
+	defer engine.abciClientMu.Unlock()
+
+	client := engine.abciClient
+	engine.abciClient = nil
+
+	if client == nil || !client.IsRunning() {
+		return nil
+	}
+
+	return client.Stop()
+}
+
+// queryApp runs the query on the connection to the app. Queries which only read from the
+// app are sent again over a new connection if the connection was lost during the query
+func (engine *Engine[B, P]) queryApp(retry bool, query func(client ABCIClient) error) error {
+	client, err := engine.getABCIClient()
+	if err != nil {
+		return err
+	}
+
+	err = query(client)
+	if err == nil || !retry || client.IsRunning() {
+		return err
+	}
+
+	logger.Info("connection to app was lost, reconnecting")
+
+	if client, err = engine.getABCIClient(); err != nil {
+		return err
+	}
+
+	return query(client)
+}
+
+func (engine *Engine[B, P]) GetAppHeight() (height int64, err error) {
+	err = engine.queryApp(true, func(client ABCIClient) error {
+		if height, err = client.Info(); err != nil {
+			return fmt.Errorf("failed to query info: %w", err)
+		}
+		return nil
+	})
+	return
+}
+
+func (engine *Engine[B, P]) listSnapshots() (snapshots []*Snapshot, err error) {
+	err = engine.queryApp(true, func(client ABCIClient) error {
+		if snapshots, err = client.ListSnapshots(); err != nil {
+			return fmt.Errorf("failed to list snapshots: %w", err)
+		}
+		return nil
+	})
+	return
+}
+
+func (engine *Engine[B, P]) GetSnapshots() ([]byte, error) {
+	snapshots, err := engine.listSnapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(snapshots) == 0 {
+		return engine.adapter.Marshal([]Snapshot{})
+	}
+
+	return engine.adapter.Marshal(snapshots)
+}
+
+func (engine *Engine[B, P]) IsSnapshotAvailable(height int64) (bool, error) {
+	snapshots, err := engine.listSnapshots()
+	if err != nil {
+		return false, err
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.Height == uint64(height) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (engine *Engine[B, P]) GetSnapshotChunk(height, format, chunk int64) ([]byte, error) {
+	var data []byte
+
+	err := engine.queryApp(true, func(client ABCIClient) (err error) {
+		if data, err = client.LoadSnapshotChunk(uint64(height), uint32(format), uint32(chunk)); err != nil {
+			return fmt.Errorf("failed to load snapshot chunk: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return engine.adapter.Marshal(data)
+}
+
+func (engine *Engine[B, P]) GetBlock(height int64) ([]byte, error) {
+	return engine.adapter.Marshal(engine.adapter.LoadBlock(height))
+}
+
+func (engine *Engine[B, P]) StartRPCServer() {
+	// wait until all reactors have been booted
+	for !engine.handshakeDone.Load() {
+		time.Sleep(time.Second)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", engine.RpcServerPort))
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to get rpc listener: %s", err))
+		return
+	}
+
+	engine.rpcListenerMu.Lock()
+	engine.rpcListener = netutil.LimitListener(listener, rpcServerMaxOpenConnections)
+	engine.rpcListenerMu.Unlock()
+
+	if err := engine.adapter.StartRPCServer(engine.rpcListener); err != nil && !errors.Is(err, net.ErrClosed) {
+		logger.Error(fmt.Sprintf("failed to start rpc server: %s", err))
+	}
+}
+
+// StopRPCServer stops the rpc server, it has to be stopped before the dbs get closed
+// since it serves the blocks directly from them
+func (engine *Engine[B, P]) StopRPCServer() {
+	engine.rpcListenerMu.Lock()
+	defer engine.rpcListenerMu.Unlock()
+
+	if engine.rpcListener == nil {
+		return
+	}
+
+	if err := engine.rpcListener.Close(); err != nil {
+		logger.Error(fmt.Sprintf("failed to stop rpc server: %s", err))
+	}
+
+	engine.rpcListener = nil
+}
+
+func (engine *Engine[B, P]) GetState(height int64) ([]byte, error) {
+	state, err := engine.adapter.LoadState(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return engine.adapter.Marshal(state)
+}
+
+func (engine *Engine[B, P]) GetSeenCommit(height int64) ([]byte, error) {
+	return engine.adapter.Marshal(engine.adapter.LoadSeenCommit(height))
+}
+
+func (engine *Engine[B, P]) parseSsyncBundle(value []byte) (*TendermintSsyncDataItem, error) {
+	var bundle TendermintSsyncBundle
+
+	if err := engine.adapter.Unmarshal(value, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tendermint-ssync bundle: %w", err)
+	}
+
+	if len(bundle) == 0 {
+		return nil, fmt.Errorf("tendermint-ssync bundle is empty")
+	}
+
+	return &bundle[0], nil
+}
+
+func (engine *Engine[B, P]) OfferSnapshot(value []byte) (string, uint32, error) {
+	item, err := engine.parseSsyncBundle(value)
+	if err != nil {
+		return SnapshotResultUnknown, 0, err
+	}
+
+	var state SnapshotState
+	if err := engine.adapter.Unmarshal(item.Value.State, &state); err != nil {
+		return SnapshotResultUnknown, 0, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+
+	result := SnapshotResultUnknown
+
+	err = engine.queryApp(false, func(client ABCIClient) (err error) {
+		result, err = client.OfferSnapshot(item.Value.Snapshot, state.AppHash)
+		return
+	})
+	if err != nil {
+		return SnapshotResultUnknown, 0, err
+	}
+
+	return result, item.Value.Snapshot.Chunks, nil
+}
+
+func (engine *Engine[B, P]) ApplySnapshotChunk(chunkIndex uint32, value []byte) (string, error) {
+	item, err := engine.parseSsyncBundle(value)
+	if err != nil {
+		return SnapshotResultUnknown, err
+	}
+
+	if engine.nodeId == "" {
+		nodeId, err := engine.adapter.LoadNodeId()
+		if err != nil {
+			return SnapshotResultUnknown, fmt.Errorf("loading node key file failed: %w", err)
+		}
+		engine.nodeId = nodeId
+	}
+
+	result := SnapshotResultUnknown
+
+	err = engine.queryApp(false, func(client ABCIClient) (err error) {
+		result, err = client.ApplySnapshotChunk(chunkIndex, item.Value.Chunk, engine.nodeId)
+		return
+	})
+	if err != nil {
+		return SnapshotResultUnknown, err
+	}
+
+	return result, nil
+}
+
+func (engine *Engine[B, P]) BootstrapState(value []byte) error {
+	item, err := engine.parseSsyncBundle(value)
+	if err != nil {
+		return err
+	}
+
+	return engine.adapter.BootstrapState(item.Value.State, item.Value.Block, item.Value.SeenCommit)
+}
+
+func (engine *Engine[B, P]) PruneBlocks(toHeight int64) error {
+	return engine.adapter.PruneBlocks(toHeight)
+}
+
+func (engine *Engine[B, P]) ResetAll(keepAddrBook bool) error {
+	config, err := engine.adapter.LoadConfig(engine.HomePath)
+	if err != nil {
+		return fmt.Errorf("failed to load config.toml: %w", err)
+	}
+
+	if keepAddrBook {
+		logger.Info("the address book remains intact")
+	} else {
+		if err := os.Remove(config.AddrBookFile); err == nil {
+			logger.Info("removed existing address book", "file", config.AddrBookFile)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("error removing address book, file: %s, err: %w", config.AddrBookFile, err)
+		}
+	}
+
+	if err := os.RemoveAll(config.DBDir); err == nil {
+		logger.Info("removed all blockchain history", "dir", config.DBDir)
+	} else {
+		return fmt.Errorf("error removing all blockchain history, dir: %s, err: %w", config.DBDir, err)
+	}
+
+	// recreate the dbDir since the privVal state needs to live there
+	if err := os.MkdirAll(config.DBDir, 0700); err != nil {
+		return fmt.Errorf("unable to recreate dbDir, err: %w", err)
+	}
+
+	if _, err := os.Stat(config.PrivValidatorKeyFile); err == nil {
+		if err := engine.adapter.ResetPrivValidator(config.PrivValidatorKeyFile, config.PrivValidatorStateFile); err != nil {
+			return fmt.Errorf("failed to reset private validator file: %w", err)
+		}
+		logger.Info(
+			"Reset private validator file to genesis state",
+			"keyFile", config.PrivValidatorKeyFile,
+			"stateFile", config.PrivValidatorStateFile,
+		)
+	} else {
+		if err := engine.adapter.GeneratePrivValidator(config.PrivValidatorKeyFile, config.PrivValidatorStateFile); err != nil {
+			return fmt.Errorf("failed to generate private validator file: %w", err)
+		}
+		logger.Info(
+			"Generated private validator file",
+			"keyFile", config.PrivValidatorKeyFile,
+			"stateFile", config.PrivValidatorStateFile,
+		)
+	}
+
+	return nil
+}
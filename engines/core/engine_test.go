@@ -0,0 +1,176 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/types"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testBlock struct {
+	Height int64 `json:"height"`
+	// AppHash is the app hash of the results of the previous block
+	AppHash []byte `json:"app_hash"`
+}
+
+type testParts struct{}
+
+// testAdapter is a node whose app commits a block as soon as it gets applied, only the
+// methods which are needed to apply blocks are implemented
+type testAdapter struct {
+	Adapter[testBlock, testParts]
+
+	// appHashes are the app hashes which the app computes for the blocks
+	appHashes   map[int64][]byte
+	executions  map[int64]int
+	stateHeight int64
+	storeHeight int64
+}
+
+func newTestAdapter() *testAdapter {
+	return &testAdapter{appHashes: make(map[int64][]byte), executions: make(map[int64]int)}
+}
+
+func (adapter *testAdapter) Unmarshal(data []byte, value interface{}) error {
+	return json.Unmarshal(data, value)
+}
+
+func (adapter *testAdapter) BlockHeight(block *testBlock) int64 {
+	return block.Height
+}
+
+func (adapter *testAdapter) MakePartSet(block *testBlock) (*testParts, error) {
+	return &testParts{}, nil
+}
+
+func (adapter *testAdapter) ValidateBlock(block *testBlock) error {
+	if block.Height != adapter.stateHeight+1 {
+		return fmt.Errorf("expected block %d, got %d", adapter.stateHeight+1, block.Height)
+	}
+	return nil
+}
+
+func (adapter *testAdapter) VerifyCommit(block *testBlock, parts *testParts, nextBlock *testBlock) error {
+	return nil
+}
+
+func (adapter *testAdapter) ApplyBlock(block *testBlock, parts *testParts, nextBlock *testBlock) error {
+	adapter.executions[block.Height]++
+	adapter.stateHeight = block.Height
+	return nil
+}
+
+func (adapter *testAdapter) SaveBlock(block *testBlock, parts *testParts, nextBlock *testBlock) {
+	adapter.storeHeight = block.Height
+}
+
+func (adapter *testAdapter) StateHeight() int64 {
+	return adapter.stateHeight
+}
+
+func (adapter *testAdapter) VerifyAppliedBlock(block *testBlock, parts *testParts, nextBlock *testBlock) error {
+	if block.Height != adapter.stateHeight {
+		return fmt.Errorf("expected applied block %d, got %d", adapter.stateHeight, block.Height)
+	}
+	return nil
+}
+
+func (adapter *testAdapter) LoadBlockResults() (*BlockResults, error) {
+	return &BlockResults{AppHash: adapter.appHashes[adapter.stateHeight]}, nil
+}
+
+func (adapter *testAdapter) ExpectedResults(block *testBlock) ([]byte, []byte) {
+	return nil, block.AppHash
+}
+
+// applyBlocks applies the blocks of the chain from the start height on like a sync which
+// starts at the continuation height, every block of the chain has the app hash "chain"
+func applyBlocks(engine *Engine[testBlock, testParts], startHeight, endHeight int64) error {
+	for height := startHeight; height <= endHeight; height++ {
+		value, err := json.Marshal(BlockResponse[testBlock]{Result: struct {
+			Block testBlock `json:"block"`
+		}{Block: testBlock{Height: height, AppHash: []byte("chain")}}})
+		if err != nil {
+			return err
+		}
+
+		if err := engine.ApplyBlock(nil, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestApplyBlockResumesAfterDivergence(t *testing.T) {
+	homePath := t.TempDir()
+	adapter := newTestAdapter()
+
+	for height := int64(1); height <= 5; height++ {
+		adapter.appHashes[height] = []byte("chain")
+	}
+	adapter.appHashes[2] = []byte("diverged")
+
+	engineConfig := types.EngineConfig{HomePath: homePath, VerifyResults: true}
+
+	if err := applyBlocks(NewEngine[testBlock, testParts](adapter, engineConfig), 1, 3); err == nil {
+		t.Fatalf("expected the results of block 2 to diverge")
+	}
+
+	if _, err := os.Stat(filepath.Join(homePath, "ksync-divergence-2.json")); err != nil {
+		t.Errorf("expected a divergence report for block 2: %s", err)
+	}
+
+	// the app committed the diverging block, but it was not stored
+	if adapter.stateHeight != 2 || adapter.storeHeight != 1 {
+		t.Fatalf("expected state height 2 and store height 1, got %d and %d", adapter.stateHeight, adapter.storeHeight)
+	}
+
+	// a restart continues at the block after the block store and reports the divergence again
+	if err := applyBlocks(NewEngine[testBlock, testParts](adapter, engineConfig), adapter.storeHeight+1, 3); err == nil {
+		t.Fatalf("expected the results of block 2 to diverge again")
+	}
+
+	if adapter.executions[2] != 1 || adapter.storeHeight != 1 {
+		t.Fatalf("expected block 2 to be neither executed again nor stored, got %d executions and store height %d", adapter.executions[2], adapter.storeHeight)
+	}
+
+	// the results of the applied block match if the sync only stopped before the block was stored
+	adapter.appHashes[2] = []byte("chain")
+
+	if err := applyBlocks(NewEngine[testBlock, testParts](adapter, engineConfig), adapter.storeHeight+1, 5); err != nil {
+		t.Fatalf("failed to resume after the divergence: %s", err)
+	}
+
+	if adapter.executions[2] != 1 {
+		t.Errorf("expected block 2 to be executed once, got %d executions", adapter.executions[2])
+	}
+
+	if adapter.stateHeight != 4 || adapter.storeHeight != 4 {
+		t.Errorf("expected state and store height 4, got %d and %d", adapter.stateHeight, adapter.storeHeight)
+	}
+}
+
+func TestAppAheadOfStore(t *testing.T) {
+	for name, c := range map[string]struct {
+		appHeight, stateHeight, storeHeight int64
+		appHash                             string
+		expectedAhead, expectedErr          bool
+	}{
+		"in sync":               {5, 5, 5, "state", false, false},
+		"app behind":            {4, 5, 5, "state", false, false},
+		"applied but not saved": {6, 6, 5, "state", true, false},
+		"state behind app":      {6, 5, 5, "state", false, false},
+		"app hash mismatch":     {6, 6, 5, "app", false, true},
+	} {
+		ahead, err := AppAheadOfStore(c.appHeight, []byte(c.appHash), c.stateHeight, []byte("state"), c.storeHeight)
+		if (err != nil) != c.expectedErr {
+			t.Errorf("%s: expected error %t, got %v", name, c.expectedErr, err)
+		}
+
+		if ahead != c.expectedAhead {
+			t.Errorf("%s: expected app ahead %t, got %t", name, c.expectedAhead, ahead)
+		}
+	}
+}
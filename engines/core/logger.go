@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+	klogger "github.com/KYVENetwork/ksync/utils"
+	"github.com/rs/zerolog"
+)
+
+var (
+	logger = NewLogger()
+)
+
+// Logger formats the logs of Tendermint and CometBFT like the logs of KSYNC. The
+// engines only have to add a With method which returns the logger interface of their version
+type Logger struct {
+	logger zerolog.Logger
+}
+
+func NewLogger(keyvals ...interface{}) Logger {
+	return Logger{logger: klogger.LogFormatter(keyvals...)}
+}
+
+func (l Logger) Debug(msg string, keyvals ...interface{}) {
+	logger := l.logger.Debug()
+
+	for i := 0; i < len(keyvals); i = i + 2 {
+		logger = logger.Str(fmt.Sprintf("%v", keyvals[i]), fmt.Sprintf("%v", keyvals[i+1]))
+	}
+
+	logger.Msg(msg)
+}
+
+func (l Logger) Info(msg string, keyvals ...interface{}) {
+	logger := l.logger.Info()
+
+	for i := 0; i < len(keyvals); i = i + 2 {
+		if keyvals[i] == "hash" || keyvals[i] == "appHash" {
+			logger = logger.Str(fmt.Sprintf("%v", keyvals[i]), fmt.Sprintf("%x", keyvals[i+1]))
+		} else {
+			logger = logger.Str(fmt.Sprintf("%v", keyvals[i]), fmt.Sprintf("%v", keyvals[i+1]))
+		}
+	}
+
+	logger.Msg(msg)
+}
+
+func (l Logger) Error(msg string, keyvals ...interface{}) {
+	logger := l.logger.Error()
+
+	for i := 0; i < len(keyvals); i = i + 2 {
+		logger = logger.Str(fmt.Sprintf("%v", keyvals[i]), fmt.Sprintf("%v", keyvals[i+1]))
+	}
+
+	logger.Msg(msg)
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"reflect"
+)
+
+var (
+	p2pLogger = utils.KsyncLogger("p2p")
+)
+
+// BlockPeer serves the block and the next block to the blocksync reactor of the node. The
+// reactors of the versions only decode the requests and send the responses, M is the proto
+// type of the block of the version
+type BlockPeer[B any, P any, M any] struct {
+	adapter   Adapter[B, P]
+	block     *B
+	nextBlock *B
+	toProto   func(block *B) (M, error)
+}
+
+func NewBlockPeer[B any, P any, M any](adapter Adapter[B, P], block, nextBlock *B, toProto func(block *B) (M, error)) *BlockPeer[B, P, M] {
+	return &BlockPeer[B, P, M]{
+		adapter:   adapter,
+		block:     block,
+		nextBlock: nextBlock,
+		toProto:   toProto,
+	}
+}
+
+// Status returns the base and the height which are reported to the node
+func (peer *BlockPeer[B, P, M]) Status() (base int64, height int64) {
+	p2pLogger.Info().Msg("Incoming status request")
+
+	base, height = peer.adapter.BlockHeight(peer.block), peer.adapter.BlockHeight(peer.block)+1
+	p2pLogger.Info().Int64("base", base).Int64("height", height).Msg("Sent status to peer")
+
+	return base, height
+}
+
+// Block returns the proto block with the requested height, false if the peer does not have it
+func (peer *BlockPeer[B, P, M]) Block(height int64) (M, bool) {
+	p2pLogger.Info().Int64("height", height).Msg("Incoming block request")
+
+	for _, block := range []*B{peer.block, peer.nextBlock} {
+		if peer.adapter.BlockHeight(block) != height {
+			continue
+		}
+
+		pb, err := peer.toProto(block)
+		if err != nil {
+			p2pLogger.Error().Msg(fmt.Sprintf("could not convert block with height %d to protobuf: %s", height, err))
+			return pb, false
+		}
+
+		p2pLogger.Info().Msg(fmt.Sprintf("sent block with height %d to peer", height))
+		return pb, true
+	}
+
+	p2pLogger.Error().Msg(fmt.Sprintf("peer asked for different block, expected = %d,%d, requested %d", peer.adapter.BlockHeight(peer.block), peer.adapter.BlockHeight(peer.nextBlock), height))
+
+	var empty M
+	return empty, false
+}
+
+// StatusResponse logs the status the node reported
+func (peer *BlockPeer[B, P, M]) StatusResponse(base, height int64) {
+	p2pLogger.Info().Int64("base", base).Int64("height", height).Msg("Incoming status response")
+}
+
+// UnknownMessage logs a message the peer can not handle
+func (peer *BlockPeer[B, P, M]) UnknownMessage(msg interface{}) {
+	p2pLogger.Error().Msg(fmt.Sprintf("Unknown message type %v", reflect.TypeOf(msg)))
+}
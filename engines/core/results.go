@@ -0,0 +1,244 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/utils"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BlockResults are the results of the app for the last applied block. The chain commits
+// to them with the LastResultsHash and the AppHash in the header of the next block
+type BlockResults struct {
+	AppHash     []byte
+	ResultsHash []byte
+	TxResults   []TxResult
+
+	// Events are the block events of BeginBlock and EndBlock or of FinalizeBlock
+	Events []Event
+}
+
+type TxResult struct {
+	Index     int     `json:"index"`
+	Code      uint32  `json:"code"`
+	Codespace string  `json:"codespace,omitempty"`
+	Log       string  `json:"log,omitempty"`
+	Data      []byte  `json:"data,omitempty"`
+	GasWanted int64   `json:"gas_wanted"`
+	GasUsed   int64   `json:"gas_used"`
+	Events    []Event `json:"events"`
+
+	// Diverges is set if the tx result differs from the tx result of the block rpc
+	Diverges bool `json:"diverges,omitempty"`
+}
+
+// nodeTxResult is a tx result in the /block_results response of a node, only the fields
+// which are part of the results hash are decoded
+type nodeTxResult struct {
+	Code      uint32 `json:"code"`
+	Codespace string `json:"codespace"`
+	Data      []byte `json:"data"`
+	GasWanted int64  `json:"gas_wanted,string"`
+	GasUsed   int64  `json:"gas_used,string"`
+}
+
+type blockResultsResponse struct {
+	Result struct {
+		TxsResults []nodeTxResult `json:"txs_results"`
+	} `json:"result"`
+}
+
+type Event struct {
+	Type       string           `json:"type"`
+	Attributes []EventAttribute `json:"attributes"`
+}
+
+type EventAttribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// DivergenceReport describes the results of a block which do not match the results the
+// chain committed to. The chain only contains the hashes of the results, so the report
+// lists all results of the app which can then be compared with the block results of a node
+type DivergenceReport struct {
+	Height              int64      `json:"height"`
+	ExpectedAppHash     string     `json:"expected_app_hash"`
+	AppHash             string     `json:"app_hash"`
+	ExpectedResultsHash string     `json:"expected_results_hash"`
+	ResultsHash         string     `json:"results_hash"`
+	TxResults           []TxResult `json:"tx_results"`
+	Events              []Event    `json:"events"`
+
+	// DivergingTxs are the indexes of the txs whose results differ from the block results
+	// of the block rpc, NodeTxResults are the tx results of the block rpc
+	DivergingTxs  []int      `json:"diverging_txs,omitempty"`
+	NodeTxResults []TxResult `json:"node_tx_results,omitempty"`
+}
+
+// verifyResults compares the results of the app for the block at the given height with the
+// LastResultsHash and the AppHash of the next block. On a mismatch a divergence report is
+// logged and written to the home directory
+func (engine *Engine[B, P]) verifyResults(height int64, nextBlock *B) error {
+	results, err := engine.adapter.LoadBlockResults()
+	if err != nil {
+		return fmt.Errorf("failed to load results of block %d: %w", height, err)
+	}
+
+	expectedResultsHash, expectedAppHash := engine.adapter.ExpectedResults(nextBlock)
+
+	appHashMatches := bytes.Equal(results.AppHash, expectedAppHash)
+	resultsHashMatches := bytes.Equal(results.ResultsHash, expectedResultsHash)
+
+	if appHashMatches && resultsHashMatches {
+		return nil
+	}
+
+	report := DivergenceReport{
+		Height:              height,
+		ExpectedAppHash:     fmt.Sprintf("%X", expectedAppHash),
+		AppHash:             fmt.Sprintf("%X", results.AppHash),
+		ExpectedResultsHash: fmt.Sprintf("%X", expectedResultsHash),
+		ResultsHash:         fmt.Sprintf("%X", results.ResultsHash),
+		TxResults:           results.TxResults,
+		Events:              results.Events,
+	}
+
+	if engine.BlockRpcConfig != nil {
+		if err := engine.compareTxResults(&report); err != nil {
+			logger.Error("failed to compare tx results with block rpc", "height", height, "err", err)
+		}
+	}
+
+	logger.Error(
+		"results of app diverge from chain",
+		"height", report.Height,
+		"expectedAppHash", report.ExpectedAppHash,
+		"appHash", report.AppHash,
+		"expectedResultsHash", report.ExpectedResultsHash,
+		"resultsHash", report.ResultsHash,
+	)
+
+	if !resultsHashMatches {
+		logger.Error("results hash mismatch, the code, data or gas of at least one tx differs from the chain")
+	}
+
+	if !appHashMatches {
+		logger.Error("app hash mismatch, the state of the app after the block differs from the chain")
+	}
+
+	for _, txResult := range report.TxResults {
+		logger.Error(
+			"tx result",
+			"index", txResult.Index,
+			"code", txResult.Code,
+			"codespace", txResult.Codespace,
+			"gasWanted", txResult.GasWanted,
+			"gasUsed", txResult.GasUsed,
+			"events", eventTypes(txResult.Events),
+			"diverges", txResult.Diverges,
+		)
+	}
+
+	if len(report.DivergingTxs) > 0 {
+		logger.Error("tx results diverge from block rpc", "indexes", fmt.Sprint(report.DivergingTxs))
+	}
+
+	logger.Error("block events", "events", eventTypes(report.Events))
+
+	path := filepath.Join(engine.HomePath, fmt.Sprintf("ksync-divergence-%d.json", height))
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal divergence report: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write divergence report: %w", err)
+	}
+
+	return fmt.Errorf("results of app diverge from chain at height %d, divergence report with all tx results and events written to %s", height, path)
+}
+
+// compareTxResults loads the block results of the diverging block from the block rpc and
+// marks the txs whose code, data or gas differ in the report
+func (engine *Engine[B, P]) compareTxResults(report *DivergenceReport) error {
+	var nodeTxResults []nodeTxResult
+	var err error
+
+	for _, endpoint := range engine.BlockRpcConfig.Endpoints {
+		nodeTxResults, err = getNodeTxResults(endpoint, report.Height)
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for i, nodeTxResult := range nodeTxResults {
+		report.NodeTxResults = append(report.NodeTxResults, TxResult{
+			Index:     i,
+			Code:      nodeTxResult.Code,
+			Codespace: nodeTxResult.Codespace,
+			Data:      nodeTxResult.Data,
+			GasWanted: nodeTxResult.GasWanted,
+			GasUsed:   nodeTxResult.GasUsed,
+		})
+	}
+
+	for i := range report.TxResults {
+		txResult := &report.TxResults[i]
+
+		if i >= len(nodeTxResults) {
+			txResult.Diverges = true
+		} else {
+			nodeTxResult := nodeTxResults[i]
+			txResult.Diverges = txResult.Code != nodeTxResult.Code ||
+				!bytes.Equal(txResult.Data, nodeTxResult.Data) ||
+				txResult.GasWanted != nodeTxResult.GasWanted ||
+				txResult.GasUsed != nodeTxResult.GasUsed
+		}
+
+		if txResult.Diverges {
+			report.DivergingTxs = append(report.DivergingTxs, txResult.Index)
+		}
+	}
+
+	// txs which only the node has results for also diverge
+	for i := len(report.TxResults); i < len(nodeTxResults); i++ {
+		report.DivergingTxs = append(report.DivergingTxs, i)
+	}
+
+	return nil
+}
+
+func getNodeTxResults(endpoint string, height int64) ([]nodeTxResult, error) {
+	data, err := utils.GetFromUrlWithOptions(fmt.Sprintf("%s/block_results?height=%d", endpoint, height),
+		utils.GetFromUrlOptions{SkipTLSVerification: true},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block results from %s: %w", endpoint, err)
+	}
+
+	var response blockResultsResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal block results from %s: %w", endpoint, err)
+	}
+
+	return response.Result.TxsResults, nil
+}
+
+// eventTypes returns the types of the events in the order they were emitted
+func eventTypes(events []Event) string {
+	types := make([]string, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+
+	return strings.Join(types, ",")
+}
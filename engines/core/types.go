@@ -0,0 +1,52 @@
+package core
+
+import (
+	"encoding/json"
+)
+
+type TendermintValue[B any] struct {
+	Block struct {
+		Block *B `json:"block"`
+	} `json:"block"`
+}
+
+type TendermintDataItem[B any] struct {
+	Key   string             `json:"key"`
+	Value TendermintValue[B] `json:"value"`
+}
+
+type TendermintBsyncDataItem[B any] struct {
+	Key   string `json:"key"`
+	Value *B     `json:"value"`
+}
+
+type TendermintBundle[B any] []TendermintDataItem[B]
+
+type TendermintBsyncBundle[B any] []TendermintBsyncDataItem[B]
+
+// TendermintSsyncBundle keeps the block, the state and the seen commit encoded,
+// since only the adapter knows their types
+type TendermintSsyncBundle = []TendermintSsyncDataItem
+
+type TendermintSsyncDataItem struct {
+	Key   string `json:"key"`
+	Value struct {
+		Snapshot   *Snapshot       `json:"snapshot"`
+		Block      json.RawMessage `json:"block"`
+		SeenCommit json.RawMessage `json:"seenCommit"`
+		State      json.RawMessage `json:"state"`
+		ChunkIndex uint32          `json:"chunkIndex"`
+		Chunk      []byte          `json:"chunk"`
+	} `json:"value"`
+}
+
+// SnapshotState is the part of the state which is needed to offer a snapshot
+type SnapshotState struct {
+	AppHash []byte
+}
+
+type BlockResponse[B any] struct {
+	Result struct {
+		Block B `json:"block"`
+	} `json:"result"`
+}
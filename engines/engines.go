@@ -3,6 +3,7 @@ package engines
 import (
 	"fmt"
 	"github.com/KYVENetwork/ksync/engines/celestia-core-v34"
+	"github.com/KYVENetwork/ksync/engines/cometbft-v1"
 	"github.com/KYVENetwork/ksync/engines/cometbft-v37"
 	"github.com/KYVENetwork/ksync/engines/cometbft-v38"
 	"github.com/KYVENetwork/ksync/engines/tendermint-v34"
@@ -17,10 +18,10 @@ var (
 	logger = utils.KsyncLogger("engines")
 )
 
-func EngineSourceFactory(engine, homePath, registryUrl, source string, rpcServerPort, continuationHeight int64) (types.Engine, error) {
+func EngineSourceFactory(engine, registryUrl, source string, continuationHeight int64, engineConfig types.EngineConfig) (types.Engine, error) {
 	// if the engine was specified by the user or the source is empty we determine the engine by the engine input
 	if engine != "" || source == "" {
-		return EngineFactory(engine, homePath, rpcServerPort), nil
+		return EngineFactory(engine, engineConfig), nil
 	}
 
 	entry, err := helpers.GetSourceRegistryEntry(registryUrl, source)
@@ -42,46 +43,48 @@ func EngineSourceFactory(engine, homePath, registryUrl, source string, rpcServer
 	}
 
 	logger.Info().Msg(fmt.Sprintf("using \"%s\" as consensus engine", engine))
-	return EngineFactory(engine, homePath, rpcServerPort), nil
+	return EngineFactory(engine, engineConfig), nil
 }
 
-func EngineFactory(engine, homePath string, rpcServerPort int64) types.Engine {
+func EngineFactory(engine string, engineConfig types.EngineConfig) types.Engine {
 	switch engine {
 	case "":
-		return &cometbft_v38.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return cometbft_v38.NewEngine(engineConfig)
 	case utils.EngineTendermintV34:
-		return &tendermint_v34.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return tendermint_v34.NewEngine(engineConfig)
 	case utils.EngineCometBFTV37:
-		return &cometbft_v37.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return cometbft_v37.NewEngine(engineConfig)
 	case utils.EngineCometBFTV38:
-		return &cometbft_v38.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return cometbft_v38.NewEngine(engineConfig)
+	case utils.EngineCometBFTV1:
+		return cometbft_v1.NewEngine(engineConfig)
 	case utils.EngineCelestiaCoreV34:
-		return &celestia_core_v34.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return celestia_core_v34.NewEngine(engineConfig)
 
 	// These engines are deprecated and will be removed soon
 	case utils.EngineTendermintV34Legacy:
 		logger.Warn().Msg(fmt.Sprintf("engine %s is deprecated and will soon be removed, use %s instead", utils.EngineTendermintV34Legacy, utils.EngineTendermintV34))
-		return &tendermint_v34.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return tendermint_v34.NewEngine(engineConfig)
 	case utils.EngineCometBFTV37Legacy:
 		logger.Warn().Msg(fmt.Sprintf("engine %s is deprecated and will soon be removed, use %s instead", utils.EngineCometBFTV37Legacy, utils.EngineCometBFTV37))
-		return &cometbft_v37.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return cometbft_v37.NewEngine(engineConfig)
 	case utils.EngineCometBFTV38Legacy:
 		logger.Warn().Msg(fmt.Sprintf("engine %s is deprecated and will soon be removed, use %s instead", utils.EngineCometBFTV38Legacy, utils.EngineCometBFTV38))
-		return &cometbft_v38.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return cometbft_v38.NewEngine(engineConfig)
 	case utils.EngineCelestiaCoreV34Legacy:
 		logger.Warn().Msg(fmt.Sprintf("engine %s is deprecated and will soon be removed, use %s instead", utils.EngineCelestiaCoreV34Legacy, utils.EngineCelestiaCoreV34))
-		return &celestia_core_v34.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return celestia_core_v34.NewEngine(engineConfig)
 
 	// These engines are deprecated and will be removed soon
 	case utils.EngineTendermintLegacy:
 		logger.Warn().Msg(fmt.Sprintf("engine %s is deprecated and will soon be removed, use %s instead", utils.EngineTendermintLegacy, utils.EngineTendermintV34))
-		return &tendermint_v34.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return tendermint_v34.NewEngine(engineConfig)
 	case utils.EngineCometBFTLegacy:
 		logger.Warn().Msg(fmt.Sprintf("engine %s is deprecated and will soon be removed, use %s or %s instead", utils.EngineCometBFTLegacy, utils.EngineCometBFTV37, utils.EngineCometBFTV38))
-		return &cometbft_v37.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return cometbft_v37.NewEngine(engineConfig)
 	case utils.EngineCelestiaCoreLegacy:
 		logger.Warn().Msg(fmt.Sprintf("engine %s is deprecated and will soon be removed, use %s instead", utils.EngineCelestiaCoreLegacy, utils.EngineCelestiaCoreV34))
-		return &celestia_core_v34.Engine{HomePath: homePath, RpcServerPort: rpcServerPort}
+		return celestia_core_v34.NewEngine(engineConfig)
 	default:
 		logger.Error().Msg(fmt.Sprintf("engine %s not found, run \"ksync engines\" to list all available engines", engine))
 		os.Exit(1)
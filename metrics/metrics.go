@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	currentHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ksync",
+		Name:      "current_height",
+		Help:      "Latest height stored in the block store",
+	})
+
+	targetHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ksync",
+		Name:      "target_height",
+		Help:      "Height at which the sync stops, zero if the sync runs until the latest height",
+	})
+
+	appliedBlocks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "ksync",
+		Name:      "applied_blocks_total",
+		Help:      "Number of blocks applied against the app",
+	})
+
+	blocksPerSecond = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ksync",
+		Name:      "blocks_per_second",
+		Help:      "Number of blocks applied per second over the last rate interval",
+	})
+
+	applyBlockDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "ksync",
+		Name:      "apply_block_duration_seconds",
+		Help:      "Duration of applying a single block against the app",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+	})
+
+	bundleDownloadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ksync",
+		Name:      "bundle_download_bytes_total",
+		Help:      "Number of compressed bundle bytes downloaded per storage provider",
+	}, []string{"provider"})
+
+	bundleDownloadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "ksync",
+		Name:      "bundle_download_duration_seconds",
+		Help:      "Duration of downloading a bundle per storage provider",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"provider"})
+
+	requestRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "ksync",
+		Name:      "request_retries_total",
+		Help:      "Number of failed requests which were retried per endpoint host",
+	}, []string{"host"})
+
+	snapshotPoolHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "ksync",
+		Name:      "snapshot_pool_height",
+		Help:      "Latest height of the snapshot pool, to be compared with the current height",
+	})
+
+	backupDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "ksync",
+		Name:      "backup_duration_seconds",
+		Help:      "Duration of creating a backup of the data directory per status, which is either success or failure",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"status"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		currentHeight,
+		targetHeight,
+		appliedBlocks,
+		blocksPerSecond,
+		applyBlockDuration,
+		bundleDownloadBytes,
+		bundleDownloadDuration,
+		requestRetries,
+		snapshotPoolHeight,
+		backupDuration,
+	)
+}
+
+// rateInterval is the minimum interval over which the blocks per second are calculated
+const rateInterval = 10 * time.Second
+
+var (
+	rateMu     = sync.Mutex{}
+	rateStart  time.Time
+	rateBlocks int64
+)
+
+// StartMetricsServer serves the metrics on /metrics of the given address and port
+func StartMetricsServer(address string, port int64) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return http.ListenAndServe(fmt.Sprintf("%s:%d", address, port), mux)
+}
+
+func SetCurrentHeight(height int64) {
+	currentHeight.Set(float64(height))
+}
+
+func SetTargetHeight(height int64) {
+	targetHeight.Set(float64(height))
+}
+
+// ObserveAppliedBlock records a block which was applied against the app together with its duration
+func ObserveAppliedBlock(height int64, duration time.Duration) {
+	currentHeight.Set(float64(height))
+	appliedBlocks.Inc()
+	applyBlockDuration.Observe(duration.Seconds())
+
+	rateMu.Lock()
+	defer rateMu.Unlock()
+
+	if rateStart.IsZero() {
+		rateStart = time.Now()
+	}
+
+	rateBlocks++
+
+	if elapsed := time.Since(rateStart); elapsed >= rateInterval {
+		blocksPerSecond.Set(float64(rateBlocks) / elapsed.Seconds())
+		rateStart, rateBlocks = time.Now(), 0
+	}
+}
+
+// ObserveBundleDownload records a bundle which was downloaded from the storage provider
+func ObserveBundleDownload(provider string, bytes int, duration time.Duration) {
+	bundleDownloadBytes.WithLabelValues(provider).Add(float64(bytes))
+	bundleDownloadDuration.WithLabelValues(provider).Observe(duration.Seconds())
+}
+
+// IncRequestRetries records a failed request to the host which gets retried
+func IncRequestRetries(host string) {
+	requestRetries.WithLabelValues(host).Inc()
+}
+
+func SetSnapshotPoolHeight(height int64) {
+	snapshotPoolHeight.Set(float64(height))
+}
+
+// ObserveBackup records the duration of a backup, failed backups are recorded with their own status
+func ObserveBackup(duration time.Duration, err error) {
+	status := "success"
+	if err != nil {
+		status = "failure"
+	}
+
+	backupDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
@@ -0,0 +1,303 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/KYVENetwork/ksync/collectors/bundles"
+	"github.com/KYVENetwork/ksync/collectors/pool"
+	"github.com/KYVENetwork/ksync/collectors/snapshots"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	tmjson "github.com/tendermint/tendermint/libs/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	logger = utils.KsyncLogger("mirror")
+)
+
+const (
+	ChainIdFile = "chain_id"
+	PoolFile    = "pool.json"
+	IndexFile   = "index.json"
+	BundlesDir  = "bundles"
+)
+
+// GetPoolDir returns the directory in which all data of a single pool is mirrored
+func GetPoolDir(mirrorDir string, poolId int64) string {
+	return filepath.Join(mirrorDir, strconv.FormatInt(poolId, 10))
+}
+
+// LoadChainId returns the id of the KYVE chain the mirror was created from, empty
+// if the mirror was created before the chain id got stored
+func LoadChainId(mirrorDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(mirrorDir, ChainIdFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read chain id of mirror: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeChainId stores the id of the KYVE chain in the mirror, a mirror can only
+// contain pools of a single chain since pool ids are only unique per chain
+func writeChainId(mirrorDir, chainId string) error {
+	existing, err := LoadChainId(mirrorDir)
+	if err != nil {
+		return err
+	}
+
+	if existing != "" && existing != chainId {
+		return fmt.Errorf("mirror directory contains pools of chain %s, can not mirror pools of chain %s into it", existing, chainId)
+	}
+
+	if err := os.WriteFile(filepath.Join(mirrorDir, ChainIdFile), []byte(chainId), 0o644); err != nil {
+		return fmt.Errorf("failed to write chain id of mirror: %w", err)
+	}
+
+	return nil
+}
+
+// IsValidStorageId checks that the storage id of a bundle can be used as file name
+// in the mirror without pointing outside of the bundles directory
+func IsValidStorageId(storageId string) bool {
+	return storageId != "" && storageId != "." && storageId != ".." && storageId == filepath.Base(storageId)
+}
+
+// ParseKeyHeight returns the height of a bundle key, depending on the runtime of the
+// pool the key is either a plain block height or a snapshot key with a chunk index
+func ParseKeyHeight(runtime, key string) (int64, error) {
+	if runtime == utils.KSyncRuntimeTendermintSsync {
+		height, _, err := utils.ParseSnapshotFromKey(key)
+		return height, err
+	}
+
+	return utils.ParseBlockHeightFromKey(key)
+}
+
+// LoadIndex loads all mirrored finalized bundles of a pool sorted by their bundle id
+func LoadIndex(poolDir string) ([]types.FinalizedBundle, error) {
+	data, err := os.ReadFile(filepath.Join(poolDir, IndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []types.FinalizedBundle{}, nil
+		}
+		return nil, fmt.Errorf("failed to read bundle index: %w", err)
+	}
+
+	var index []types.FinalizedBundle
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bundle index: %w", err)
+	}
+
+	return index, nil
+}
+
+func writeIndex(poolDir string, index map[int64]types.FinalizedBundle) error {
+	ids := make([]int64, 0, len(index))
+	for id := range index {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	sorted := make([]types.FinalizedBundle, 0, len(ids))
+	for _, id := range ids {
+		sorted = append(sorted, index[id])
+	}
+
+	data, err := json.MarshalIndent(sorted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle index: %w", err)
+	}
+
+	// write to a temporary file first so an interrupted mirror never
+	// leaves a corrupted index behind
+	tmp := filepath.Join(poolDir, IndexFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write bundle index: %w", err)
+	}
+
+	return os.Rename(tmp, filepath.Join(poolDir, IndexFile))
+}
+
+// getStartBundleId returns the id of the first bundle which contains data for the
+// given start height, so the mirror does not need to walk through the entire pool
+func getStartBundleId(chainRest *utils.EndpointGroup, poolResponse *types.PoolResponse, startHeight int64) (int64, error) {
+	if startHeight == 0 {
+		return 0, nil
+	}
+
+	if poolResponse.Pool.Data.Runtime == utils.KSyncRuntimeTendermintSsync {
+		bundleId, _, err := snapshots.FindNearestSnapshotBundleIdByHeight(chainRest, poolResponse.Pool.Id, startHeight)
+		return bundleId, err
+	}
+
+	finalizedBundle, err := bundles.GetFinalizedBundleForBlockHeight(chainRest, *poolResponse, startHeight)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get finalized bundle for block height %d: %w", startHeight, err)
+	}
+
+	return strconv.ParseInt(finalizedBundle.Id, 10, 64)
+}
+
+// isMirrored checks if the raw bundle data was already downloaded and is still valid
+func isMirrored(path string, finalizedBundle types.FinalizedBundle) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	return utils.CreateSha256Checksum(data) == finalizedBundle.DataHash
+}
+
+// StartMirror downloads all finalized bundles of the pool which contain data between the start
+// and the target height into the mirror directory. The bundles are stored in their raw compressed
+// format together with an index of the finalized bundle metadata. Already mirrored bundles are
+// skipped, so an interrupted mirror can simply be started again.
+func StartMirror(ctx context.Context, chainId string, chainRest *utils.EndpointGroup, storageRest string, poolId, startHeight, targetHeight int64, mirrorDir string) error {
+	logger.Info().Msg(fmt.Sprintf("starting to mirror pool %d", poolId))
+
+	poolResponse, err := pool.GetPoolInfo(chainRest, poolId)
+	if err != nil {
+		return fmt.Errorf("failed to get pool info: %w", err)
+	}
+
+	runtime := poolResponse.Pool.Data.Runtime
+	if runtime != utils.KSyncRuntimeTendermint && runtime != utils.KSyncRuntimeTendermintBsync && runtime != utils.KSyncRuntimeTendermintSsync {
+		return fmt.Errorf("found invalid runtime on pool %d: %s", poolId, runtime)
+	}
+
+	poolDir := GetPoolDir(mirrorDir, poolId)
+	if err := os.MkdirAll(filepath.Join(poolDir, BundlesDir), 0o755); err != nil {
+		return fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+
+	if err := writeChainId(mirrorDir, chainId); err != nil {
+		return err
+	}
+
+	// the pool response gets stored in the same format as returned by the KYVE REST
+	rawPool, err := tmjson.MarshalIndent(poolResponse, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pool response: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(poolDir, PoolFile), rawPool, 0o644); err != nil {
+		return fmt.Errorf("failed to write pool response: %w", err)
+	}
+
+	existing, err := LoadIndex(poolDir)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[int64]types.FinalizedBundle)
+	for _, finalizedBundle := range existing {
+		id, err := strconv.ParseInt(finalizedBundle.Id, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse bundle id %s: %w", finalizedBundle.Id, err)
+		}
+		index[id] = finalizedBundle
+	}
+
+	startBundleId, err := getStartBundleId(chainRest, poolResponse, startHeight)
+	if err != nil {
+		return fmt.Errorf("failed to find start bundle for height %d: %w", startHeight, err)
+	}
+
+	mirrored, skipped := 0, 0
+	bundlesPage, nextKey, err := bundles.GetFinalizedBundlesPageWithOffset(chainRest, poolId, utils.BundlesPageLimit, startBundleId, "", false)
+
+BundleMirror:
+	for {
+		if err != nil {
+			return fmt.Errorf("failed to get finalized bundles page: %w", err)
+		}
+
+		for _, finalizedBundle := range bundlesPage {
+			fromHeight, err := ParseKeyHeight(runtime, finalizedBundle.FromKey)
+			if err != nil {
+				return fmt.Errorf("failed to parse from key %s: %w", finalizedBundle.FromKey, err)
+			}
+
+			toHeight, err := ParseKeyHeight(runtime, finalizedBundle.ToKey)
+			if err != nil {
+				return fmt.Errorf("failed to parse to key %s: %w", finalizedBundle.ToKey, err)
+			}
+
+			if toHeight < startHeight {
+				continue
+			}
+
+			if targetHeight > 0 && fromHeight > targetHeight {
+				break BundleMirror
+			}
+
+			id, err := strconv.ParseInt(finalizedBundle.Id, 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse bundle id %s: %w", finalizedBundle.Id, err)
+			}
+
+			if !IsValidStorageId(finalizedBundle.StorageId) {
+				return fmt.Errorf("found invalid storage id %s on bundle %d", finalizedBundle.StorageId, id)
+			}
+
+			path := filepath.Join(poolDir, BundlesDir, finalizedBundle.StorageId)
+
+			if isMirrored(path, finalizedBundle) {
+				index[id] = finalizedBundle
+				skipped++
+				continue
+			}
+
+			logger.Info().Msg(fmt.Sprintf("mirroring bundle %d with storage id %s", id, finalizedBundle.StorageId))
+
+			data, err := bundles.RetrieveDataFromStorageProvider(ctx, finalizedBundle, storageRest)
+			if err != nil {
+				return fmt.Errorf("failed to retrieve data from storage provider with storage id %s: %w", finalizedBundle.StorageId, err)
+			}
+
+			if utils.CreateSha256Checksum(data) != finalizedBundle.DataHash {
+				return fmt.Errorf("found different sha256 checksum on bundle with storage id %s: expected = %s found = %s", finalizedBundle.StorageId, finalizedBundle.DataHash, utils.CreateSha256Checksum(data))
+			}
+
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				return fmt.Errorf("failed to write bundle with storage id %s: %w", finalizedBundle.StorageId, err)
+			}
+
+			index[id] = finalizedBundle
+			mirrored++
+		}
+
+		// persist the index after every page so an interrupted mirror is still usable
+		if err := writeIndex(poolDir, index); err != nil {
+			return err
+		}
+
+		if nextKey == "" {
+			break
+		}
+
+		time.Sleep(utils.RequestTimeoutMS * time.Millisecond)
+		bundlesPage, nextKey, err = bundles.GetFinalizedBundlesPage(chainRest, poolId, utils.BundlesPageLimit, nextKey, false)
+	}
+
+	if err := writeIndex(poolDir, index); err != nil {
+		return err
+	}
+
+	logger.Info().Msg(fmt.Sprintf("mirrored %d new bundles of pool %d into %s, %d bundles were already mirrored", mirrored, poolId, poolDir, skipped))
+	return nil
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/control"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// StartControlApiServer serves the status of the running sync and lets orchestration
+// tooling pause, resume and change the target height of the sync
+func StartControlApiServer(address string, port int64) error {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+
+	r.GET("/status", StatusHandler)
+	r.POST("/pause", PauseHandler)
+	r.POST("/resume", ResumeHandler)
+	r.POST("/target_height/:height", TargetHeightHandler)
+
+	return r.Run(fmt.Sprintf("%s:%d", address, port))
+}
+
+func StatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, control.GetStatus())
+}
+
+func PauseHandler(c *gin.Context) {
+	control.Pause()
+	c.JSON(http.StatusOK, control.GetStatus())
+}
+
+func ResumeHandler(c *gin.Context) {
+	control.Resume()
+	c.JSON(http.StatusOK, control.GetStatus())
+}
+
+func TargetHeightHandler(c *gin.Context) {
+	height, err := strconv.ParseInt(c.Param("height"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Error parsing param \"height\" to int64: %s", err.Error()),
+		})
+		return
+	}
+
+	if err := control.ChangeTargetHeight(height); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, control.GetStatus())
+}
@@ -0,0 +1,387 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/KYVENetwork/ksync/mirror"
+	"github.com/KYVENetwork/ksync/types"
+	"github.com/KYVENetwork/ksync/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/tendermint/tendermint/libs/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+var (
+	logger = utils.KsyncLogger("server")
+)
+
+type mirroredPool struct {
+	pool    types.PoolResponse
+	bundles []types.FinalizedBundle
+}
+
+// MirrorApiServer serves a local bundle mirror created with "ksync mirror". It implements
+// the subset of the KYVE REST API and the storage provider API which is used by KSYNC, so
+// it can fully replace both endpoints during a sync.
+type MirrorApiServer struct {
+	chainId  string
+	pools    map[int64]*mirroredPool
+	storages map[string]string
+	server   *http.Server
+}
+
+// StartMirrorApiServer loads all mirrored pools from the mirror directory and starts serving
+// them on a random local port. The returned endpoint can be used as chain and storage rest
+func StartMirrorApiServer(mirrorDir string) (*MirrorApiServer, string, error) {
+	apiServer := &MirrorApiServer{
+		pools:    make(map[int64]*mirroredPool),
+		storages: make(map[string]string),
+	}
+
+	chainId, err := mirror.LoadChainId(mirrorDir)
+	if err != nil {
+		return nil, "", err
+	}
+	apiServer.chainId = chainId
+
+	entries, err := os.ReadDir(mirrorDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read mirror directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		poolId, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		if err := apiServer.loadPool(mirrorDir, poolId); err != nil {
+			return nil, "", fmt.Errorf("failed to load mirrored pool %d: %w", poolId, err)
+		}
+	}
+
+	if len(apiServer.pools) == 0 {
+		return nil, "", fmt.Errorf("found no mirrored pools in %s", mirrorDir)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+
+	r.GET("/kyve/query/v1beta1/pool/:pool", apiServer.GetPoolHandler)
+	r.GET("/kyve/v1/bundles/:pool", apiServer.GetFinalizedBundlesHandler)
+	r.GET("/kyve/v1/bundles/:pool/:id", apiServer.GetFinalizedBundleHandler)
+	r.GET("/storage/:storage_id", apiServer.GetStorageHandler)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to listen for mirror api server: %w", err)
+	}
+
+	apiServer.server = &http.Server{Handler: r}
+
+	go func() {
+		if err := apiServer.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error().Msg(fmt.Sprintf("mirror api server stopped: %s", err))
+		}
+	}()
+
+	return apiServer, fmt.Sprintf("http://%s", listener.Addr().String()), nil
+}
+
+// Close stops serving the mirror
+func (apiServer *MirrorApiServer) Close() error {
+	return apiServer.server.Close()
+}
+
+// ChainId returns the id of the KYVE chain the mirror was created from, empty if unknown
+func (apiServer *MirrorApiServer) ChainId() string {
+	return apiServer.chainId
+}
+
+// GetPoolIds returns the ids of the block and snapshot pool. Pool ids which are not given
+// are resolved from the mirrored pools by their runtime, so no source registry is needed
+func (apiServer *MirrorApiServer) GetPoolIds(blockPoolId, snapshotPoolId string, blockPoolRequired, snapshotPoolRequired bool) (int64, int64, error) {
+	var bId, sId int64
+	var err error
+
+	if blockPoolId != "" {
+		if bId, err = strconv.ParseInt(blockPoolId, 10, 64); err != nil {
+			return 0, 0, err
+		}
+	} else if blockPoolRequired {
+		if bId, err = apiServer.findPoolId("block", utils.KSyncRuntimeTendermint, utils.KSyncRuntimeTendermintBsync); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if snapshotPoolId != "" {
+		if sId, err = strconv.ParseInt(snapshotPoolId, 10, 64); err != nil {
+			return 0, 0, err
+		}
+	} else if snapshotPoolRequired {
+		if sId, err = apiServer.findPoolId("snapshot", utils.KSyncRuntimeTendermintSsync); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return bId, sId, nil
+}
+
+// findPoolId returns the id of the only mirrored pool with one of the given runtimes
+func (apiServer *MirrorApiServer) findPoolId(poolType string, runtimes ...string) (int64, error) {
+	var poolIds []int64
+
+	for poolId, p := range apiServer.pools {
+		for _, runtime := range runtimes {
+			if p.pool.Pool.Data.Runtime == runtime {
+				poolIds = append(poolIds, poolId)
+			}
+		}
+	}
+
+	switch len(poolIds) {
+	case 0:
+		return 0, fmt.Errorf("found no mirrored %s pool", poolType)
+	case 1:
+		return poolIds[0], nil
+	default:
+		return 0, fmt.Errorf("found multiple mirrored %s pools, specify the pool with --%s-pool-id", poolType, poolType)
+	}
+}
+
+func (apiServer *MirrorApiServer) loadPool(mirrorDir string, poolId int64) error {
+	poolDir := mirror.GetPoolDir(mirrorDir, poolId)
+
+	rawPool, err := os.ReadFile(filepath.Join(poolDir, mirror.PoolFile))
+	if err != nil {
+		return fmt.Errorf("failed to read pool response: %w", err)
+	}
+
+	var poolResponse types.PoolResponse
+	if err := json.Unmarshal(rawPool, &poolResponse); err != nil {
+		return fmt.Errorf("failed to unmarshal pool response: %w", err)
+	}
+
+	index, err := mirror.LoadIndex(poolDir)
+	if err != nil {
+		return err
+	}
+
+	if len(index) == 0 {
+		return fmt.Errorf("pool has no mirrored bundles")
+	}
+
+	// the pool boundaries are limited to the mirrored bundles, so KSYNC does not try
+	// to sync blocks or snapshots which are not part of the mirror
+	first, last := index[0], index[len(index)-1]
+
+	lastId, err := strconv.ParseInt(last.Id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse bundle id %s: %w", last.Id, err)
+	}
+
+	poolResponse.Pool.Data.StartKey = first.FromKey
+	poolResponse.Pool.Data.CurrentKey = last.ToKey
+	poolResponse.Pool.Data.CurrentSummary = last.BundleSummary
+	poolResponse.Pool.Data.TotalBundles = lastId + 1
+
+	for _, finalizedBundle := range index {
+		if !mirror.IsValidStorageId(finalizedBundle.StorageId) {
+			return fmt.Errorf("found invalid storage id %s on bundle %s", finalizedBundle.StorageId, finalizedBundle.Id)
+		}
+		apiServer.storages[finalizedBundle.StorageId] = filepath.Join(poolDir, mirror.BundlesDir, finalizedBundle.StorageId)
+	}
+
+	apiServer.pools[poolId] = &mirroredPool{
+		pool:    poolResponse,
+		bundles: index,
+	}
+
+	return nil
+}
+
+func (apiServer *MirrorApiServer) getPool(c *gin.Context) *mirroredPool {
+	poolId, err := strconv.ParseInt(c.Param("pool"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Error parsing param \"pool\" to int64: %s", err.Error()),
+		})
+		return nil
+	}
+
+	p, ok := apiServer.pools[poolId]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("pool %d is not mirrored", poolId),
+		})
+		return nil
+	}
+
+	return p
+}
+
+func (apiServer *MirrorApiServer) respond(c *gin.Context, value interface{}) {
+	resp, err := json.Marshal(value)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", resp)
+}
+
+func (apiServer *MirrorApiServer) GetPoolHandler(c *gin.Context) {
+	p := apiServer.getPool(c)
+	if p == nil {
+		return
+	}
+
+	apiServer.respond(c, p.pool)
+}
+
+func (apiServer *MirrorApiServer) GetFinalizedBundlesHandler(c *gin.Context) {
+	p := apiServer.getPool(c)
+	if p == nil {
+		return
+	}
+
+	// if an index is requested we return the bundle which contains the data item
+	// with the given index
+	if rawIndex := c.Query("index"); rawIndex != "" {
+		index, err := strconv.ParseInt(rawIndex, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Error parsing query \"index\" to int64: %s", err.Error()),
+			})
+			return
+		}
+
+		response := types.FinalizedBundlesResponse{FinalizedBundles: []types.FinalizedBundle{}}
+
+		if startKey, err := mirror.ParseKeyHeight(p.pool.Pool.Data.Runtime, p.pool.Pool.Data.StartKey); err == nil {
+			height := startKey + index
+
+			for _, finalizedBundle := range p.bundles {
+				fromHeight, _ := mirror.ParseKeyHeight(p.pool.Pool.Data.Runtime, finalizedBundle.FromKey)
+				toHeight, _ := mirror.ParseKeyHeight(p.pool.Pool.Data.Runtime, finalizedBundle.ToKey)
+
+				if fromHeight <= height && height <= toHeight {
+					response.FinalizedBundles = append(response.FinalizedBundles, finalizedBundle)
+					break
+				}
+			}
+		}
+
+		apiServer.respond(c, response)
+		return
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("pagination.limit", "100"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Error parsing query \"pagination.limit\" to int64: %s", err.Error()),
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.DefaultQuery("pagination.offset", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Error parsing query \"pagination.offset\" to int64: %s", err.Error()),
+		})
+		return
+	}
+
+	reverse := c.Query("pagination.reverse") == "true"
+
+	ordered := p.bundles
+	if reverse {
+		ordered = make([]types.FinalizedBundle, len(p.bundles))
+		for i, finalizedBundle := range p.bundles {
+			ordered[len(p.bundles)-1-i] = finalizedBundle
+		}
+	}
+
+	// the pagination key is the position of the next bundle in the ordered list,
+	// the offset is interpreted as bundle id since the mirror can start at any bundle
+	position := int64(0)
+
+	if key := c.Query("pagination.key"); key != "" {
+		rawKey, err := base64.URLEncoding.DecodeString(key)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Error decoding query \"pagination.key\": %s", err.Error()),
+			})
+			return
+		}
+
+		position, err = strconv.ParseInt(string(rawKey), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Error parsing query \"pagination.key\": %s", err.Error()),
+			})
+			return
+		}
+	} else if offset > 0 {
+		for position < int64(len(ordered)) {
+			id, _ := strconv.ParseInt(ordered[position].Id, 10, 64)
+			if (!reverse && id >= offset) || (reverse && id <= offset) {
+				break
+			}
+			position++
+		}
+	}
+
+	end := position + limit
+	if end > int64(len(ordered)) {
+		end = int64(len(ordered))
+	}
+
+	response := types.FinalizedBundlesResponse{FinalizedBundles: []types.FinalizedBundle{}}
+
+	if position < end {
+		response.FinalizedBundles = ordered[position:end]
+	}
+
+	if end < int64(len(ordered)) {
+		response.Pagination.NextKey = []byte(strconv.FormatInt(end, 10))
+	}
+
+	apiServer.respond(c, response)
+}
+
+func (apiServer *MirrorApiServer) GetFinalizedBundleHandler(c *gin.Context) {
+	p := apiServer.getPool(c)
+	if p == nil {
+		return
+	}
+
+	for _, finalizedBundle := range p.bundles {
+		if finalizedBundle.Id == c.Param("id") {
+			apiServer.respond(c, finalizedBundle)
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": fmt.Sprintf("bundle %s is not mirrored", c.Param("id")),
+	})
+}
+
+func (apiServer *MirrorApiServer) GetStorageHandler(c *gin.Context) {
+	path, ok := apiServer.storages[c.Param("storage_id")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("bundle with storage id %s is not mirrored", c.Param("storage_id")),
+		})
+		return
+	}
+
+	c.File(path)
+}
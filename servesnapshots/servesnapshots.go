@@ -1,11 +1,14 @@
 package servesnapshots
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/KYVENetwork/ksync/blocksync"
 	"github.com/KYVENetwork/ksync/bootstrap"
 	"github.com/KYVENetwork/ksync/collectors/pool"
+	"github.com/KYVENetwork/ksync/control"
 	"github.com/KYVENetwork/ksync/server"
 	"github.com/KYVENetwork/ksync/statesync"
 	"github.com/KYVENetwork/ksync/types"
@@ -21,12 +24,14 @@ var (
 
 // PerformServeSnapshotsValidationChecks checks if the targetHeight lies in the range of available blocks and checks
 // if a state-sync snapshot is available right before the startHeight
-func PerformServeSnapshotsValidationChecks(engine types.Engine, chainRest string, snapshotPoolId, blockPoolId, startHeight, targetHeight int64) (snapshotBundleId, snapshotHeight int64, err error) {
+func PerformServeSnapshotsValidationChecks(engine types.Engine, chainRest *utils.EndpointGroup, snapshotPoolId, blockPoolId, startHeight, targetHeight int64) (snapshotBundleId, snapshotHeight int64, err error) {
 	height := engine.GetHeight()
 
 	// only if the app has not indexed any blocks yet we state-sync to the specified startHeight
 	if height == 0 {
-		snapshotBundleId, snapshotHeight, _ = statesync.PerformStateSyncValidationChecks(chainRest, snapshotPoolId, startHeight, false)
+		if stateSyncPlan, err := statesync.PerformStateSyncValidationChecks(chainRest, snapshotPoolId, startHeight, false); err == nil {
+			snapshotBundleId, snapshotHeight = stateSyncPlan.SnapshotBundleId, stateSyncPlan.SnapshotHeight
+		}
 	}
 
 	continuationHeight := snapshotHeight
@@ -38,15 +43,16 @@ func PerformServeSnapshotsValidationChecks(engine types.Engine, chainRest string
 		continuationHeight = c
 	}
 
-	if err := blocksync.PerformBlockSyncValidationChecks(chainRest, nil, &blockPoolId, continuationHeight, targetHeight, false, false); err != nil {
+	if _, err := blocksync.PerformBlockSyncValidationChecks(chainRest, nil, &blockPoolId, continuationHeight, targetHeight, false, false); err != nil {
 		return 0, 0, fmt.Errorf("block-sync validation checks failed: %w", err)
 	}
 
 	return
 }
 
-func StartServeSnapshotsWithBinary(engine types.Engine, binaryPath, homePath, chainRest, storageRest string, blockPoolId *int64, snapshotPoolId, targetHeight, height, snapshotBundleId, snapshotHeight, snapshotPort int64, appFlags string, rpcServer, pruning, keepSnapshots, skipWaiting, debug bool) error {
+func StartServeSnapshotsWithBinary(ctx context.Context, engine types.Engine, binaryPath, homePath string, chainRest *utils.EndpointGroup, storageRest string, blockPoolId *int64, prefetchCfg *types.PrefetchConfig, snapshotPoolId, targetHeight, height, snapshotBundleId, snapshotHeight, snapshotPort int64, appFlags string, rpcServer, pruning, keepSnapshots, skipWaiting, debug bool) error {
 	logger.Info().Msg("starting serve-snapshots")
+	control.SetTargetHeight(targetHeight)
 
 	if pruning && skipWaiting {
 		return fmt.Errorf("pruning has to be disabled with --pruning=false if --skip-waiting is true")
@@ -98,11 +104,11 @@ func StartServeSnapshotsWithBinary(engine types.Engine, binaryPath, homePath, ch
 		)
 	}
 
-	processId := 0
+	var process *utils.Process
 
 	if height == 0 && snapshotHeight > 0 {
 		// start binary process thread
-		processId, err = utils.StartBinaryProcessForDB(engine, binaryPath, debug, snapshotArgs)
+		process, err = utils.StartBinaryProcessForDB(engine, binaryPath, debug, snapshotArgs)
 		if err != nil {
 			return fmt.Errorf("failed to start binary process: %w", err)
 		}
@@ -112,44 +118,42 @@ func StartServeSnapshotsWithBinary(engine types.Engine, binaryPath, homePath, ch
 		}
 
 		// found snapshot, applying it and continuing block-sync from here
-		if err := statesync.StartStateSyncExecutor(engine, chainRest, storageRest, snapshotPoolId, snapshotBundleId); err != nil {
+		if err := statesync.StartStateSyncExecutor(ctx, engine, chainRest, storageRest, snapshotPoolId, snapshotBundleId); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return utils.StopStateSyncGracefully(engine, process, "serve-snapshots")
+			}
+
 			logger.Error().Msg(fmt.Sprintf("state-sync failed with: %s", err))
 
 			// stop binary process thread
-			if err := utils.StopProcessByProcessId(processId); err != nil {
-				return fmt.Errorf("failed to stop process by process id: %w", err)
+			if err := process.Stop(); err != nil {
+				return fmt.Errorf("failed to stop binary process: %w", err)
 			}
 
 			return fmt.Errorf("failed to start state-sync executor: %w", err)
 		}
 
 		// TODO: does app has to be restarted after a state-sync?
-		if engine.GetName() == utils.EngineCometBFTV37 || engine.GetName() == utils.EngineCometBFTV38 {
+		if engine.GetName() == utils.EngineCometBFTV37 || engine.GetName() == utils.EngineCometBFTV38 || engine.GetName() == utils.EngineCometBFTV1 {
 			// ignore error, since process gets terminated anyway afterward
 			e := engine.CloseDBs()
 			_ = e
 
-			if err := utils.StopProcessByProcessId(processId); err != nil {
-				return fmt.Errorf("failed to stop process by process id: %w", err)
+			if err := process.Stop(); err != nil {
+				return fmt.Errorf("failed to stop binary process: %w", err)
 			}
 
-			// wait until process has properly shut down
-			time.Sleep(10 * time.Second)
-
-			processId, err = utils.StartBinaryProcessForDB(engine, binaryPath, debug, snapshotArgs)
+			process, err = utils.StartBinaryProcessForDB(engine, binaryPath, debug, snapshotArgs)
 			if err != nil {
 				return fmt.Errorf("failed to start process: %w", err)
 			}
 
-			// wait until process has properly started
-			time.Sleep(10 * time.Second)
-
 			if err := engine.OpenDBs(); err != nil {
 				logger.Error().Msg(fmt.Sprintf("failed to open dbs in engine: %s", err))
 
 				// stop binary process thread
-				if err := utils.StopProcessByProcessId(processId); err != nil {
-					return fmt.Errorf("failed to stop process by process id: %w", err)
+				if err := process.Stop(); err != nil {
+					return fmt.Errorf("failed to stop binary process: %w", err)
 				}
 
 				return fmt.Errorf("failed to open dbs in engine: %w", err)
@@ -157,12 +161,12 @@ func StartServeSnapshotsWithBinary(engine types.Engine, binaryPath, homePath, ch
 		}
 	} else {
 		// if we have to sync from genesis we first bootstrap the node
-		if err := bootstrap.StartBootstrapWithBinary(engine, binaryPath, homePath, chainRest, storageRest, nil, blockPoolId, appFlags, debug); err != nil {
+		if err := bootstrap.StartBootstrapWithBinary(ctx, engine, binaryPath, homePath, chainRest, storageRest, nil, blockPoolId, appFlags, debug); err != nil {
 			return fmt.Errorf("failed to bootstrap node: %w", err)
 		}
 
 		// after the node is bootstrapped we start the binary process thread
-		processId, err = utils.StartBinaryProcessForDB(engine, binaryPath, debug, snapshotArgs)
+		process, err = utils.StartBinaryProcessForDB(engine, binaryPath, debug, snapshotArgs)
 		if err != nil {
 			return fmt.Errorf("failed to start binary process: %w", err)
 		}
@@ -178,21 +182,28 @@ func StartServeSnapshotsWithBinary(engine types.Engine, binaryPath, homePath, ch
 
 	go server.StartSnapshotApiServer(engine, snapshotPort)
 
+	start := time.Now()
+	startHeight := engine.GetHeight()
+
 	// db executes blocks against app until target height
-	if err := blocksync.StartBlockSyncExecutor(engine, chainRest, storageRest, nil, blockPoolId, targetHeight, snapshotPoolId, config.Interval, pruning, skipWaiting, nil); err != nil {
+	if err := blocksync.StartBlockSyncExecutor(ctx, engine, process, binaryPath, chainRest, storageRest, nil, blockPoolId, prefetchCfg, nil, startHeight, targetHeight, targetHeight, snapshotPoolId, config.Interval, pruning, skipWaiting, nil); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return utils.StopSyncGracefully(engine, process, "serve-snapshots", startHeight, start)
+		}
+
 		logger.Error().Msg(fmt.Sprintf("failed to start db executor: %s", err))
 
 		// stop binary process thread
-		if err := utils.StopProcessByProcessId(processId); err != nil {
-			return fmt.Errorf("failed to stop process by process id: %w", err)
+		if err := process.Stop(); err != nil {
+			return fmt.Errorf("failed to stop binary process: %w", err)
 		}
 
 		return fmt.Errorf("failed to start block-sync executor: %w", err)
 	}
 
 	// stop binary process thread
-	if err := utils.StopProcessByProcessId(processId); err != nil {
-		return fmt.Errorf("failed to stop process by process id: %w", err)
+	if err := process.Stop(); err != nil {
+		return fmt.Errorf("failed to stop binary process: %w", err)
 	}
 
 	if err := engine.CloseDBs(); err != nil {
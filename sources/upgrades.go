@@ -0,0 +1,210 @@
+package sources
+
+import (
+	"fmt"
+	"github.com/KYVENetwork/ksync/sources/helpers"
+	"github.com/KYVENetwork/ksync/types"
+	log "github.com/KYVENetwork/ksync/utils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// upgradeBinaries finds the binaries of the chain upgrades in a directory. The directory can either
+// have the cosmovisor layout (genesis/bin and upgrades/<name>/bin) or simply contain the binaries of
+// all versions, in this case the binaries are matched by the output of their version command
+type upgradeBinaries struct {
+	dir        string
+	binaryName string
+	cosmovisor bool
+	versions   map[string]string
+}
+
+func newUpgradeBinaries(dir, binaryPath string) (*upgradeBinaries, error) {
+	dir = filepath.Clean(dir)
+
+	// a cosmovisor home can be given directly or with the daemon home containing it
+	if isDir(filepath.Join(dir, "cosmovisor")) {
+		dir = filepath.Join(dir, "cosmovisor")
+	}
+
+	binaries := &upgradeBinaries{
+		dir:        dir,
+		cosmovisor: isDir(filepath.Join(dir, "genesis", "bin")) || isDir(filepath.Join(dir, "upgrades")),
+	}
+
+	if binaryPath != "" {
+		binaries.binaryName = filepath.Base(binaryPath)
+	}
+
+	if !isDir(dir) {
+		return nil, fmt.Errorf("upgrade binaries directory %s does not exist", dir)
+	}
+
+	return binaries, nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
+
+// findBinaryInDir returns the binary with the name of the daemon in the directory, if the
+// name is unknown the directory has to contain exactly one binary
+func (binaries *upgradeBinaries) findBinaryInDir(dir string) (string, error) {
+	if binaries.binaryName != "" && isExecutable(filepath.Join(dir, binaries.binaryName)) {
+		return filepath.Join(dir, binaries.binaryName), nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+
+	var found []string
+	for _, entry := range entries {
+		if path := filepath.Join(dir, entry.Name()); isExecutable(path) {
+			found = append(found, path)
+		}
+	}
+
+	if len(found) != 1 {
+		return "", fmt.Errorf("expected exactly one binary in %s but found %d", dir, len(found))
+	}
+
+	// all following upgrades are looked up with the same daemon name
+	binaries.binaryName = filepath.Base(found[0])
+	return found[0], nil
+}
+
+// GenesisBinary returns the binary of a cosmovisor layout which is used before the first upgrade
+func (binaries *upgradeBinaries) GenesisBinary() (string, error) {
+	if !binaries.cosmovisor {
+		return "", fmt.Errorf("directory %s has no cosmovisor layout, the binary before the first upgrade has to be provided with --binary", binaries.dir)
+	}
+
+	return binaries.findBinaryInDir(filepath.Join(binaries.dir, "genesis", "bin"))
+}
+
+// loadVersions runs the version command of every binary in the directory, its direct subdirectories
+// and the bin directories of a cosmovisor layout
+func (binaries *upgradeBinaries) loadVersions() {
+	binaries.versions = make(map[string]string)
+
+	var paths []string
+	for _, pattern := range []string{"*", "*/*", "*/bin/*", "*/*/bin/*"} {
+		matches, _ := filepath.Glob(filepath.Join(binaries.dir, pattern))
+		paths = append(paths, matches...)
+	}
+
+	for _, path := range paths {
+		if !isExecutable(path) {
+			continue
+		}
+
+		version, err := log.GetBinaryVersion(path)
+		if err != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to get version of binary %s: %s", path, err))
+			continue
+		}
+
+		version = strings.TrimPrefix(version, "v")
+		if _, ok := binaries.versions[version]; !ok {
+			binaries.versions[version] = path
+		}
+	}
+}
+
+// UpgradeBinary returns the binary which has to be used from the height of the upgrade on
+func (binaries *upgradeBinaries) UpgradeBinary(name, recommendedVersion string) (string, error) {
+	if binaries.cosmovisor {
+		for _, name := range []string{name, strings.ToLower(name)} {
+			if dir := filepath.Join(binaries.dir, "upgrades", name, "bin"); isDir(dir) {
+				return binaries.findBinaryInDir(dir)
+			}
+		}
+	}
+
+	if binaries.versions == nil {
+		binaries.loadVersions()
+	}
+
+	if path, ok := binaries.versions[strings.TrimPrefix(recommendedVersion, "v")]; ok {
+		return path, nil
+	}
+
+	return "", fmt.Errorf("found no binary with version %s for upgrade \"%s\" in %s", recommendedVersion, name, binaries.dir)
+}
+
+// GetBinaryUpgrades returns the upgrades of the source which are needed to sync from the continuation height
+// to the target height together with their binaries. The first upgrade is the one which is active at the
+// continuation height, if the continuation height is before the first upgrade the provided binary or the
+// genesis binary of the cosmovisor layout is used. A target height of zero includes all upgrades
+func GetBinaryUpgrades(registryUrl, source, binariesDir, binaryPath string, continuationHeight, targetHeight int64) ([]types.BinaryUpgrade, error) {
+	if source == "" {
+		return nil, fmt.Errorf("source is required to look up the upgrades of the chain")
+	}
+
+	entry, err := helpers.GetSourceRegistryEntry(registryUrl, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source registry entry: %w", err)
+	}
+
+	binaries, err := newUpgradeBinaries(binariesDir, binaryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	upgrades := []types.BinaryUpgrade{{Name: "genesis", BinaryPath: binaryPath}}
+	beforeFirstUpgrade := true
+
+	for _, upgrade := range entry.Codebase.Settings.Upgrades {
+		height, err := strconv.ParseInt(upgrade.Height, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse upgrade height %s: %w", upgrade.Height, err)
+		}
+
+		if targetHeight > 0 && height > targetHeight {
+			break
+		}
+
+		// upgrades before the continuation height are only needed for the active one
+		if height <= continuationHeight {
+			upgrades, beforeFirstUpgrade = upgrades[:0], false
+		}
+
+		upgrades = append(upgrades, types.BinaryUpgrade{
+			Name:               upgrade.Name,
+			Height:             height,
+			RecommendedVersion: upgrade.RecommendedVersion,
+			Engine:             upgrade.Engine,
+		})
+	}
+
+	// the genesis binary is resolved first since it defines the name of the daemon
+	if beforeFirstUpgrade && upgrades[0].BinaryPath == "" {
+		if upgrades[0].BinaryPath, err = binaries.GenesisBinary(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range upgrades {
+		if upgrades[i].BinaryPath != "" {
+			continue
+		}
+
+		if upgrades[i].BinaryPath, err = binaries.UpgradeBinary(upgrades[i].Name, upgrades[i].RecommendedVersion); err != nil {
+			return nil, err
+		}
+
+		logger.Info().Msg(fmt.Sprintf("found binary %s for upgrade \"%s\" at height %d", upgrades[i].BinaryPath, upgrades[i].Name, upgrades[i].Height))
+	}
+
+	return upgrades, nil
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/KYVENetwork/ksync/sources/helpers"
 	log "github.com/KYVENetwork/ksync/utils"
-	"os/exec"
 	"strconv"
 	"strings"
 )
@@ -18,26 +17,11 @@ func IsBinaryRecommendedVersion(binaryPath, registryUrl, source string, continua
 		return nil
 	}
 
-	cmdPath, err := exec.LookPath(binaryPath)
+	binaryVersion, err := log.GetBinaryVersion(binaryPath)
 	if err != nil {
-		return fmt.Errorf("failed to lookup binary path: %w", err)
+		return err
 	}
 
-	startArgs := make([]string, 0)
-
-	// if we run with cosmovisor we start with the cosmovisor run command
-	if strings.HasSuffix(binaryPath, "cosmovisor") {
-		startArgs = append(startArgs, "run")
-	}
-
-	startArgs = append(startArgs, "version")
-
-	out, err := exec.Command(cmdPath, startArgs...).Output()
-	if err != nil {
-		return fmt.Errorf("failed to get output of binary: %w", err)
-	}
-
-	binaryVersion := strings.TrimSuffix(string(out), "\n")
 	binaryVersionFormatted := fmt.Sprintf("v%s", binaryVersion)
 
 	var recommendedVersion string
@@ -64,7 +48,7 @@ func IsBinaryRecommendedVersion(binaryPath, registryUrl, source string, continua
 		return nil
 	}
 
-	fmt.Printf("\u001B[36m[KSYNC]\u001B[0m The recommended binary version for the current height %d is %s while the provided binary has the following version: %s. Proceed anyway? [y/N]: ", continuationHeight, recommendedVersion, binaryVersion)
+	log.Prompt("The recommended binary version for the current height %d is %s while the provided binary has the following version: %s. Proceed anyway? [y/N]: ", continuationHeight, recommendedVersion, binaryVersion)
 
 	answer := ""
 	if _, err := fmt.Scan(&answer); err != nil {
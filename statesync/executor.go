@@ -1,15 +1,20 @@
 package statesync
 
 import (
+	"context"
 	"fmt"
 	"github.com/KYVENetwork/ksync/collectors/bundles"
+	"github.com/KYVENetwork/ksync/control"
 	"github.com/KYVENetwork/ksync/types"
 	"github.com/KYVENetwork/ksync/utils"
 )
 
 // StartStateSyncExecutor takes the bundle id of the first snapshot chunk and applies the snapshot from there
-func StartStateSyncExecutor(engine types.Engine, chainRest, storageRest string, snapshotPoolId, snapshotBundleId int64) error {
+func StartStateSyncExecutor(ctx context.Context, engine types.Engine, chainRest *utils.EndpointGroup, storageRest string, snapshotPoolId, snapshotBundleId int64) error {
 	logger.Info().Msg(fmt.Sprintf("applying state-sync snapshot"))
+	control.SetPhase(control.PhaseStateSync)
+	control.SetEngine(engine.GetName())
+	control.SetSnapshotPoolId(snapshotPoolId)
 
 	appHeight, err := engine.GetAppHeight()
 	if err != nil {
@@ -30,7 +35,7 @@ func StartStateSyncExecutor(engine types.Engine, chainRest, storageRest string,
 		return fmt.Errorf("failed getting snapshot height from to_key %s: %w", finalizedBundle.ToKey, err)
 	}
 
-	deflated, err := bundles.GetDataFromFinalizedBundle(*finalizedBundle, storageRest)
+	deflated, err := bundles.GetDataFromFinalizedBundle(ctx, *finalizedBundle, storageRest)
 	if err != nil {
 		return fmt.Errorf("failed getting data from finalized bundle: %w", err)
 	}
@@ -48,12 +53,21 @@ func StartStateSyncExecutor(engine types.Engine, chainRest, storageRest string,
 	}
 
 	for chunkIndex := uint32(0); chunkIndex < chunks; chunkIndex++ {
+		// a chunk which is being applied is always finished, but no new chunk is started after an interrupt
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := control.WaitWhilePaused(ctx); err != nil {
+			return err
+		}
+
 		chunkBundleFinalized, err := bundles.GetFinalizedBundleById(chainRest, snapshotPoolId, snapshotBundleId+int64(chunkIndex))
 		if err != nil {
 			return fmt.Errorf("failed getting finalized bundle: %w", err)
 		}
 
-		chunkBundleDeflated, err := bundles.GetDataFromFinalizedBundle(*chunkBundleFinalized, storageRest)
+		chunkBundleDeflated, err := bundles.GetDataFromFinalizedBundle(ctx, *chunkBundleFinalized, storageRest)
 		if err != nil {
 			return fmt.Errorf("failed getting data from finalized bundle: %w", err)
 		}
@@ -74,9 +88,15 @@ func StartStateSyncExecutor(engine types.Engine, chainRest, storageRest string,
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if err := engine.BootstrapState(deflated); err != nil {
 		return fmt.Errorf("failed to bootstrap state: %s\"", err)
 	}
 
+	control.SetHeight(snapshotHeight)
+
 	return nil
 }
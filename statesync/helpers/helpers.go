@@ -12,8 +12,8 @@ import (
 // GetSnapshotPoolHeight returns the height of the snapshot the pool is currently archiving.
 // Note that this snapshot can be not complete since for the state-sync to work all chunks have
 // to be available.
-func GetSnapshotPoolHeight(restEndpoint string, poolId int64) int64 {
-	snapshotPool, err := pool.GetPoolInfo(restEndpoint, poolId)
+func GetSnapshotPoolHeight(chainRest *utils.EndpointGroup, poolId int64) int64 {
+	snapshotPool, err := pool.GetPoolInfo(chainRest, poolId)
 	if err != nil {
 		panic(fmt.Errorf("could not get snapshot pool: %w", err))
 	}
@@ -38,9 +38,9 @@ func GetSnapshotPoolHeight(restEndpoint string, poolId int64) int64 {
 // GetSnapshotBoundaries returns the snapshot heights for the lowest complete snapshot and the
 // highest complete snapshot. A complete snapshot contains all chunks of the snapshot, a snapshot which is currently
 // still being archived can have the latest chunks missing, therefore being not usable.
-func GetSnapshotBoundaries(restEndpoint string, poolId int64) (startHeight int64, endHeight int64, err error) {
+func GetSnapshotBoundaries(chainRest *utils.EndpointGroup, poolId int64) (startHeight int64, endHeight int64, err error) {
 	// load start and latest height
-	poolResponse, err := pool.GetPoolInfo(restEndpoint, poolId)
+	poolResponse, err := pool.GetPoolInfo(chainRest, poolId)
 	if err != nil {
 		return startHeight, endHeight, fmt.Errorf("failed to get pool info: %w", err)
 	}
@@ -85,7 +85,7 @@ func GetSnapshotBoundaries(restEndpoint string, poolId int64) (startHeight int64
 		}
 	}
 
-	bundle, err := bundles.GetFinalizedBundleById(restEndpoint, poolId, highestUsableSnapshotBundleId)
+	bundle, err := bundles.GetFinalizedBundleById(chainRest, poolId, highestUsableSnapshotBundleId)
 	if err != nil {
 		return startHeight, endHeight, fmt.Errorf("failed to get finalized bundle with id %d: %w", highestUsableSnapshotBundleId, err)
 	}
@@ -1,6 +1,7 @@
 package statesync
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/KYVENetwork/ksync/collectors/snapshots"
@@ -15,13 +16,13 @@ var (
 	logger = utils.KsyncLogger("state-sync")
 )
 
-// PerformStateSyncValidationChecks checks if a snapshot is available for the targetHeight and if not returns
-// the nearest available snapshot below the targetHeight. It also returns the bundle id for the snapshot
-func PerformStateSyncValidationChecks(chainRest string, snapshotPoolId, targetHeight int64, userInput bool) (snapshotBundleId, snapshotHeight int64, err error) {
+// PerformStateSyncValidationChecks checks if a snapshot is available for the targetHeight and if not plans
+// the nearest available snapshot below the targetHeight. The plan also contains the bundle id for the snapshot
+func PerformStateSyncValidationChecks(chainRest *utils.EndpointGroup, snapshotPoolId, targetHeight int64, userInput bool) (*types.StateSyncPlan, error) {
 	// get lowest and highest complete snapshot
 	startHeight, endHeight, err := helpers.GetSnapshotBoundaries(chainRest, snapshotPoolId)
 	if err != nil {
-		return snapshotBundleId, snapshotHeight, fmt.Errorf("failed get snapshot boundaries: %w", err)
+		return nil, fmt.Errorf("failed get snapshot boundaries: %w", err)
 	}
 
 	logger.Info().Msg(fmt.Sprintf("retrieved snapshot boundaries, earliest complete snapshot height = %d, latest complete snapshot height %d", startHeight, endHeight))
@@ -33,7 +34,7 @@ func PerformStateSyncValidationChecks(chainRest string, snapshotPoolId, targetHe
 	}
 
 	if targetHeight < startHeight {
-		return snapshotBundleId, snapshotHeight, fmt.Errorf("requested snapshot height %d but first available snapshot on pool is %d", targetHeight, startHeight)
+		return nil, fmt.Errorf("requested snapshot height %d but first available snapshot on pool is %d", targetHeight, startHeight)
 	}
 
 	// limit snapshot search height by latest available snapshot height
@@ -42,9 +43,9 @@ func PerformStateSyncValidationChecks(chainRest string, snapshotPoolId, targetHe
 		snapshotSearchHeight = endHeight
 	}
 
-	snapshotBundleId, snapshotHeight, err = snapshots.FindNearestSnapshotBundleIdByHeight(chainRest, snapshotPoolId, snapshotSearchHeight)
+	snapshotBundleId, snapshotHeight, err := snapshots.FindNearestSnapshotBundleIdByHeight(chainRest, snapshotPoolId, snapshotSearchHeight)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	if userInput {
@@ -53,28 +54,34 @@ func PerformStateSyncValidationChecks(chainRest string, snapshotPoolId, targetHe
 		// if we found a different snapshotHeight as the requested targetHeight it means the targetHeight was not
 		// available, and we have to sync to the nearest height below
 		if targetHeight != snapshotHeight {
-			fmt.Printf("\u001B[36m[KSYNC]\u001B[0m could not find snapshot with requested height %d, state-sync to nearest available snapshot with height %d instead? [y/N]: ", targetHeight, snapshotHeight)
+			utils.Prompt("could not find snapshot with requested height %d, state-sync to nearest available snapshot with height %d instead? [y/N]: ", targetHeight, snapshotHeight)
 		} else {
-			fmt.Printf("\u001B[36m[KSYNC]\u001B[0m should snapshot with height %d be applied with state-sync [y/N]: ", snapshotHeight)
+			utils.Prompt("should snapshot with height %d be applied with state-sync [y/N]: ", snapshotHeight)
 		}
 
 		if _, err := fmt.Scan(&answer); err != nil {
-			return snapshotBundleId, snapshotHeight, fmt.Errorf("failed to read in user input: %w", err)
+			return nil, fmt.Errorf("failed to read in user input: %w", err)
 		}
 
 		if strings.ToLower(answer) != "y" {
-			return snapshotBundleId, snapshotHeight, errors.New("aborted state-sync")
+			return nil, errors.New("aborted state-sync")
 		}
 	}
 
-	return snapshotBundleId, snapshotHeight, nil
+	return &types.StateSyncPlan{
+		PoolStartHeight:  startHeight,
+		PoolEndHeight:    endHeight,
+		TargetHeight:     targetHeight,
+		SnapshotBundleId: snapshotBundleId,
+		SnapshotHeight:   snapshotHeight,
+	}, nil
 }
 
-func StartStateSyncWithBinary(engine types.Engine, binaryPath, chainId, chainRest, storageRest string, snapshotPoolId, targetHeight, snapshotBundleId, snapshotHeight int64, appFlags string, optOut, debug bool) error {
+func StartStateSyncWithBinary(ctx context.Context, engine types.Engine, binaryPath, chainId string, chainRest *utils.EndpointGroup, storageRest string, snapshotPoolId, targetHeight, snapshotBundleId, snapshotHeight int64, appFlags string, optOut, debug bool) error {
 	logger.Info().Msg("starting state-sync")
 
 	// start binary process thread
-	processId, err := utils.StartBinaryProcessForDB(engine, binaryPath, debug, strings.Split(appFlags, ","))
+	process, err := utils.StartBinaryProcessForDB(engine, binaryPath, debug, strings.Split(appFlags, ","))
 	if err != nil {
 		return fmt.Errorf("failed to start binary process: %w", err)
 	}
@@ -87,20 +94,24 @@ func StartStateSyncWithBinary(engine types.Engine, binaryPath, chainId, chainRes
 
 	start := time.Now()
 
-	if err := StartStateSyncExecutor(engine, chainRest, storageRest, snapshotPoolId, snapshotBundleId); err != nil {
+	if err := StartStateSyncExecutor(ctx, engine, chainRest, storageRest, snapshotPoolId, snapshotBundleId); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return utils.StopStateSyncGracefully(engine, process, "state-sync")
+		}
+
 		logger.Error().Msg(fmt.Sprintf("failed to start state-sync: %s", err))
 
 		// stop binary process thread
-		if err := utils.StopProcessByProcessId(processId); err != nil {
-			return fmt.Errorf("failed to stop process by process id: %w", err)
+		if err := process.Stop(); err != nil {
+			return fmt.Errorf("failed to stop binary process: %w", err)
 		}
 
 		return fmt.Errorf("failed to start state-sync executor: %w", err)
 	}
 
 	// stop binary process thread
-	if err := utils.StopProcessByProcessId(processId); err != nil {
-		return fmt.Errorf("failed to stop process by process id: %w", err)
+	if err := process.Stop(); err != nil {
+		return fmt.Errorf("failed to stop binary process: %w", err)
 	}
 
 	elapsed := time.Since(start).Seconds()
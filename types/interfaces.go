@@ -21,10 +21,16 @@ type Engine interface {
 	// GetProxyAppAddress gets the proxy app address of the TSP connection
 	GetProxyAppAddress() string
 
+	// GetAbciTransport gets the transport of the ABCI connection, either socket or grpc
+	GetAbciTransport() string
+
+	// GetEngineConfig gets the settings the engine was created with
+	GetEngineConfig() EngineConfig
+
 	// StartProxyApp starts the proxy app connections to the app
 	StartProxyApp() error
 
-	// StopProxyApp stops the proxy app connections to the app
+	// StopProxyApp stops the proxy app connections and the query connection to the app
 	StopProxyApp() error
 
 	// GetChainId gets the chain id of the app
@@ -79,6 +85,10 @@ type Engine interface {
 	// /status, /block and /block_results
 	StartRPCServer()
 
+	// StopRPCServer stops the rpc server, it has to be stopped before the
+	// dbs get closed
+	StopRPCServer()
+
 	// GetState rebuilds the requested state from the blockstore and state.db
 	GetState(height int64) ([]byte, error)
 
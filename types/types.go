@@ -44,6 +44,10 @@ type TendermintSSyncConfig = struct {
 type DataItem struct {
 	Key   string          `json:"key"`
 	Value json.RawMessage `json:"value"`
+
+	// BundleId is the id of the finalized bundle the item was collected from. It is
+	// not part of the bundle format and empty if the item was collected from a rpc
+	BundleId string `json:"-"`
 }
 
 type Bundle = []DataItem
@@ -60,6 +64,7 @@ type FinalizedBundle struct {
 	FromKey           string `json:"from_key,omitempty"`
 	ToKey             string `json:"to_key,omitempty"`
 	DataHash          string `json:"data_hash,omitempty"`
+	BundleSummary     string `json:"bundle_summary,omitempty"`
 }
 
 type FinalizedBundlesResponse = struct {
@@ -142,6 +147,16 @@ type CosmosUpgrade struct {
 	Engine             string `yaml:"ksync-engine"`
 }
 
+// BinaryUpgrade is a chain upgrade together with the binary which applies
+// all blocks from the upgrade height until the next upgrade
+type BinaryUpgrade struct {
+	Name               string
+	Height             int64
+	RecommendedVersion string
+	Engine             string
+	BinaryPath         string
+}
+
 type Entry struct {
 	ConfigVersion *int     `yaml:"config-version"`
 	Networks      Networks `yaml:"networks"`
@@ -153,7 +168,69 @@ type SourceRegistry struct {
 	Entries map[string]Entry `yaml:",inline"`
 }
 
+// EngineConfig contains the settings of a consensus engine which are the same for every version
+type EngineConfig struct {
+	HomePath      string
+	RpcServerPort int64
+	AbciTransport string
+
+	// VerifyResults compares the results of the app with the results the chain committed
+	// to before a block is stored and stops at the first block where they diverge
+	VerifyResults bool
+
+	// BlockRpcConfig is used to compare the tx results of a diverging block with the block
+	// results of a node, nil if no block rpc was configured
+	BlockRpcConfig *BlockRpcConfig
+}
+
 type BlockRpcConfig struct {
-	Endpoint       string
+	Endpoints      []string
 	RequestTimeout time.Duration
+	Concurrency    int64
+	BatchSize      int64
+	RateLimit      int64
+}
+
+type PrefetchConfig struct {
+	Workers     int64
+	MaxMemoryMB int64
+}
+
+type ProgressConfig struct {
+	Interval time.Duration
+	Bar      bool
+}
+
+// SyncPlan describes what a sync would do, it is printed instead of starting the sync with --dry-run
+type SyncPlan struct {
+	SyncType       string         `json:"sync_type"`
+	ChainId        string         `json:"chain_id"`
+	Source         string         `json:"source"`
+	Engine         string         `json:"engine"`
+	BinaryPath     string         `json:"binary_path"`
+	HomePath       string         `json:"home_path"`
+	BlockPoolId    *int64         `json:"block_pool_id"`
+	SnapshotPoolId *int64         `json:"snapshot_pool_id"`
+	Height         int64          `json:"height"`
+	TargetHeight   int64          `json:"target_height"`
+	StateSync      *StateSyncPlan `json:"state_sync"`
+	BlockSync      *BlockSyncPlan `json:"block_sync"`
+}
+
+// StateSyncPlan describes the snapshot which gets applied
+type StateSyncPlan struct {
+	PoolStartHeight  int64 `json:"pool_start_height"`
+	PoolEndHeight    int64 `json:"pool_end_height"`
+	TargetHeight     int64 `json:"target_height"`
+	SnapshotBundleId int64 `json:"snapshot_bundle_id"`
+	SnapshotHeight   int64 `json:"snapshot_height"`
+}
+
+// BlockSyncPlan describes the range of blocks which get applied
+type BlockSyncPlan struct {
+	PoolStartHeight int64 `json:"pool_start_height"`
+	PoolEndHeight   int64 `json:"pool_end_height"`
+	StartHeight     int64 `json:"start_height"`
+	EndHeight       int64 `json:"end_height"`
+	Blocks          int64 `json:"blocks"`
 }
@@ -6,7 +6,6 @@ import (
 	"os"
 	"os/exec"
 	"strings"
-	"syscall"
 )
 
 func GetHomePathFromBinary(binaryPath string) string {
@@ -83,6 +82,8 @@ func GetEnginePathFromBinary(binaryPath string) string {
 					return EngineCometBFTV37
 				} else if strings.Contains(dependency[1], "0.38.") {
 					return EngineCometBFTV38
+				} else if strings.HasPrefix(dependency[1], "1.") {
+					return EngineCometBFTV1
 				}
 			}
 		}
@@ -93,14 +94,16 @@ func GetEnginePathFromBinary(binaryPath string) string {
 	return ""
 }
 
-func StartBinaryProcessForDB(engine types.Engine, binaryPath string, debug bool, args []string) (processId int, err error) {
+// StartBinaryProcessForDB starts the binary as an ABCI app without CometBFT and waits until
+// it accepts connections on the proxy app address
+func StartBinaryProcessForDB(engine types.Engine, binaryPath string, debug bool, args []string) (process *Process, err error) {
 	if binaryPath == "" {
 		return
 	}
 
 	cmdPath, err := exec.LookPath(binaryPath)
 	if err != nil {
-		return processId, fmt.Errorf("failed to lookup binary path: %w", err)
+		return nil, fmt.Errorf("failed to lookup binary path: %w", err)
 	}
 
 	startArgs := make([]string, 0)
@@ -111,7 +114,7 @@ func StartBinaryProcessForDB(engine types.Engine, binaryPath string, debug bool,
 	}
 
 	if err := engine.LoadConfig(); err != nil {
-		return processId, fmt.Errorf("failed to load engine config: %w", err)
+		return nil, fmt.Errorf("failed to load engine config: %w", err)
 	}
 
 	baseArgs := append([]string{
@@ -121,6 +124,8 @@ func StartBinaryProcessForDB(engine types.Engine, binaryPath string, debug bool,
 		"--with-tendermint=false",
 		"--address",
 		engine.GetProxyAppAddress(),
+		"--transport",
+		engine.GetAbciTransport(),
 	}, args...)
 
 	cmd := exec.Command(cmdPath, append(startArgs, baseArgs...)...)
@@ -130,22 +135,32 @@ func StartBinaryProcessForDB(engine types.Engine, binaryPath string, debug bool,
 		cmd.Stderr = os.Stderr
 	}
 
-	if err := cmd.Start(); err != nil {
-		return processId, fmt.Errorf("failed to start binary process: %w", err)
+	process, err = startProcess(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info().Msg(fmt.Sprintf("started binary process with process id %d, waiting for %s ABCI connection on %s", process.Pid(), engine.GetAbciTransport(), engine.GetProxyAppAddress()))
+
+	if err := process.WaitForAbciSocket(engine.GetProxyAppAddress()); err != nil {
+		if stopErr := process.Stop(); stopErr != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to stop binary process: %s", stopErr))
+		}
+		return nil, err
 	}
 
-	processId = cmd.Process.Pid
 	return
 }
 
-func StartBinaryProcessForP2P(engine types.Engine, binaryPath string, debug bool, args []string) (processId int, err error) {
+// StartBinaryProcessForP2P starts the binary as a full node which only connects to KSYNC
+func StartBinaryProcessForP2P(engine types.Engine, binaryPath string, debug bool, args []string) (process *Process, err error) {
 	if binaryPath == "" {
 		return
 	}
 
 	cmdPath, err := exec.LookPath(binaryPath)
 	if err != nil {
-		return processId, fmt.Errorf("failed to lookup binary path: %w", err)
+		return nil, fmt.Errorf("failed to lookup binary path: %w", err)
 	}
 
 	startArgs := make([]string, 0)
@@ -175,27 +190,29 @@ func StartBinaryProcessForP2P(engine types.Engine, binaryPath string, debug bool
 		cmd.Stderr = os.Stderr
 	}
 
-	if err := cmd.Start(); err != nil {
-		return processId, fmt.Errorf("failed to start binary process: %w", err)
-	}
-
-	processId = cmd.Process.Pid
-	return
+	return startProcess(cmd)
 }
 
-func StopProcessByProcessId(processId int) error {
-	if processId == 0 {
-		return nil
+// GetBinaryVersion returns the version printed by the version command of the binary
+func GetBinaryVersion(binaryPath string) (string, error) {
+	cmdPath, err := exec.LookPath(binaryPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to lookup binary path: %w", err)
 	}
 
-	process, err := os.FindProcess(processId)
-	if err != nil {
-		return fmt.Errorf("failed to find binary process: %w", err)
+	startArgs := make([]string, 0)
+
+	// if we run with cosmovisor we start with the cosmovisor run command
+	if strings.HasSuffix(binaryPath, "cosmovisor") {
+		startArgs = append(startArgs, "run")
 	}
 
-	if err = process.Signal(syscall.SIGTERM); err != nil {
-		return fmt.Errorf("failed to stop binary process with SIGTERM: %w", err)
+	startArgs = append(startArgs, "version")
+
+	out, err := exec.Command(cmdPath, startArgs...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get output of binary: %w", err)
 	}
 
-	return nil
+	return strings.TrimSuffix(string(out), "\n"), nil
 }
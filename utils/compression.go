@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+)
+
+const (
+	CompressionNone   = 0
+	CompressionGzip   = 1
+	CompressionZstd   = 2
+	CompressionBrotli = 3
+)
+
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (r zstdReadCloser) Close() error {
+	r.Decoder.Close()
+	return nil
+}
+
+// NewDecompressionReader wraps the input stream with a reader which decompresses the
+// data on the fly with the codec of the given compression id, so the decompressed data
+// never has to be fully held in memory
+func NewDecompressionReader(compressionId uint64, input io.Reader) (io.ReadCloser, error) {
+	switch compressionId {
+	case CompressionNone:
+		return io.NopCloser(input), nil
+	case CompressionGzip:
+		return gzip.NewReader(input)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(input, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			return nil, err
+		}
+		return zstdReadCloser{decoder}, nil
+	case CompressionBrotli:
+		return io.NopCloser(brotli.NewReader(input)), nil
+	default:
+		return nil, fmt.Errorf("compression id %d is not supported", compressionId)
+	}
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"strings"
+	"testing"
+)
+
+var testBundle = []byte(strings.Repeat(`{"key":"1","value":{"block":1}},`, 1000))
+
+// compress compresses the data with the writer of the codec
+func compress(t *testing.T, newWriter func(w io.Writer) (io.WriteCloser, error)) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	writer, err := newWriter(&buf)
+	if err != nil {
+		t.Fatalf("failed to create writer: %s", err)
+	}
+
+	if _, err := writer.Write(testBundle); err != nil {
+		t.Fatalf("failed to compress data: %s", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close writer: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestNewDecompressionReader(t *testing.T) {
+	for name, codec := range map[string]struct {
+		compressionId uint64
+		data          []byte
+	}{
+		"none": {CompressionNone, testBundle},
+		"gzip": {CompressionGzip, compress(t, func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		})},
+		"zstd": {CompressionZstd, compress(t, func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		})},
+		"brotli": {CompressionBrotli, compress(t, func(w io.Writer) (io.WriteCloser, error) {
+			return brotli.NewWriter(w), nil
+		})},
+	} {
+		reader, err := NewDecompressionReader(codec.compressionId, bytes.NewReader(codec.data))
+		if err != nil {
+			t.Fatalf("failed to create %s reader: %s", name, err)
+		}
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("failed to decompress %s data: %s", name, err)
+		}
+
+		if err := reader.Close(); err != nil {
+			t.Errorf("failed to close %s reader: %s", name, err)
+		}
+
+		if !bytes.Equal(data, testBundle) {
+			t.Errorf("expected %s data to match the uncompressed data", name)
+		}
+	}
+}
+
+func TestNewDecompressionReaderRejectsUnknownCompression(t *testing.T) {
+	if _, err := NewDecompressionReader(4, bytes.NewReader(testBundle)); err == nil {
+		t.Errorf("expected unknown compression id to be rejected")
+	}
+}
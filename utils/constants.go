@@ -1,5 +1,7 @@
 package utils
 
+import "time"
+
 const (
 	ChainIdMainnet  = "kyve-1"
 	ChainIdKaon     = "kaon-1"
@@ -27,6 +29,7 @@ const (
 	EngineTendermintV34   = "tendermint-v0.34"
 	EngineCometBFTV37     = "cometbft-v0.37"
 	EngineCometBFTV38     = "cometbft-v0.38"
+	EngineCometBFTV1      = "cometbft-v1"
 	EngineCelestiaCoreV34 = "celestia-core-v0.34"
 
 	EngineTendermintV34Legacy   = "tendermint-v34"
@@ -40,22 +43,57 @@ const (
 )
 
 const (
-	DefaultEngine             = EngineTendermintV34
-	DefaultChainId            = ChainIdMainnet
-	DefaultBackupPath         = "~/.ksync/backups"
-	DefaultRpcServerPort      = 7777
-	DefaultSnapshotServerPort = 7878
+	AbciTransportSocket = "socket"
+	AbciTransportGrpc   = "grpc"
+)
+
+const (
+	OutputText = "text"
+	OutputJson = "json"
+)
+
+const (
+	DefaultEngine              = EngineTendermintV34
+	DefaultChainId             = ChainIdMainnet
+	DefaultBackupPath          = "~/.ksync/backups"
+	DefaultRpcServerPort       = 7777
+	DefaultSnapshotServerPort  = 7878
+	DefaultMetricsPort         = 7979
+	DefaultMetricsAddress      = "127.0.0.1"
+	DefaultControlApiPort      = 7980
+	DefaultControlApiAddress   = "127.0.0.1"
+	DefaultPrefetchWorkers     = 2
+	DefaultPrefetchMaxMemory   = 1024
+	DefaultBundleCacheSize     = 10240
+	DefaultBlockRpcConcurrency = 4
+	DefaultBlockRpcBatchSize   = 20
+	DefaultProgressInterval    = 30
 )
 
 const (
 	BundlesPageLimit            = 1000
 	BlockBuffer                 = 300
 	PruningInterval             = 100
+	CheckpointInterval          = 100
+	CheckpointBundleLookahead   = 10
 	SnapshotPruningAheadFactor  = 3
 	SnapshotPruningWindowFactor = 6
 	BackoffMaxRetries           = 10
 	RequestTimeoutMS            = 250
 	RequestBlocksTimeoutMS      = 250
+	EndpointFailurePenalty      = 10 * time.Second
+	RpcNodeMaxFailures          = 3
+	RpcBlockMaxFailures         = 5
+	RpcRetryBaseDelay           = 500 * time.Millisecond
+	RpcRetryMaxDelay            = 30 * time.Second
+	ProcessStartupTimeout       = 10 * time.Minute
+	ProcessShutdownTimeout      = 60 * time.Second
+	ProcessOutputTailSize       = 4096
+	ProgressRateWindow          = time.Minute
+	ProgressPoolRefreshInterval = 5 * time.Minute
+	ProgressBarRefreshInterval  = time.Second
+	ProgressBarWidth            = 30
+	ControlApiRecentErrors      = 20
 )
 
 const (
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/KYVENetwork/ksync/metrics"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpointHealth keeps track of the latency and the recent errors of a single endpoint
+type endpointHealth struct {
+	endpoint string
+	latency  time.Duration
+	failures int64
+}
+
+// score returns the health score of the endpoint, lower is better. Every consecutive
+// failure is weighted like a very slow response so failing endpoints are moved to the
+// end but are still used as a last resort
+func (health *endpointHealth) score() time.Duration {
+	return health.latency + time.Duration(health.failures)*EndpointFailurePenalty
+}
+
+// EndpointGroup is a list of interchangeable endpoints serving the same data. Requests are
+// always sent to the healthiest endpoint and immediately fail over to the next one on errors.
+// The group is passed to everything which requests data from the endpoints, so the health of
+// the endpoints is shared between all requests
+type EndpointGroup struct {
+	mu        sync.Mutex
+	endpoints []*endpointHealth
+}
+
+func NewEndpointGroup(endpoints []string) *EndpointGroup {
+	group := &EndpointGroup{}
+
+	for _, endpoint := range endpoints {
+		group.endpoints = append(group.endpoints, &endpointHealth{endpoint: endpoint})
+	}
+
+	return group
+}
+
+// ParseEndpoints parses a comma separated list of endpoints and trims trailing slashes
+func ParseEndpoints(endpoints string) (parsed []string) {
+	for _, endpoint := range strings.Split(endpoints, ",") {
+		endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+		if endpoint != "" {
+			parsed = append(parsed, endpoint)
+		}
+	}
+
+	return
+}
+
+// String returns the endpoints of the group comma separated in the order they were given
+func (group *EndpointGroup) String() string {
+	if group == nil {
+		return ""
+	}
+
+	endpoints := make([]string, 0, len(group.endpoints))
+	for _, health := range group.endpoints {
+		endpoints = append(endpoints, health.endpoint)
+	}
+
+	return strings.Join(endpoints, ",")
+}
+
+// Primary returns the first endpoint which was given, independent of the health of the endpoints
+func (group *EndpointGroup) Primary() string {
+	if group == nil || len(group.endpoints) == 0 {
+		return ""
+	}
+
+	return group.endpoints[0].endpoint
+}
+
+// GetEndpoints returns the endpoints of the group ordered by their health score
+func (group *EndpointGroup) GetEndpoints() []string {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	healths := append([]*endpointHealth{}, group.endpoints...)
+	sort.SliceStable(healths, func(i, j int) bool {
+		return healths[i].score() < healths[j].score()
+	})
+
+	endpoints := make([]string, 0, len(healths))
+	for _, health := range healths {
+		endpoints = append(endpoints, health.endpoint)
+	}
+
+	return endpoints
+}
+
+func (group *EndpointGroup) recordSuccess(endpoint string, latency time.Duration) {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	for _, health := range group.endpoints {
+		if health.endpoint == endpoint {
+			// exponentially weighted moving average so a single slow
+			// response does not immediately degrade an endpoint
+			if health.latency == 0 {
+				health.latency = latency
+			} else {
+				health.latency = (4*health.latency + latency) / 5
+			}
+			health.failures = 0
+		}
+	}
+}
+
+func (group *EndpointGroup) recordFailure(endpoint string) {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	for _, health := range group.endpoints {
+		if health.endpoint == endpoint {
+			health.failures++
+		}
+	}
+}
+
+// Do executes the request against the healthiest endpoint and fails over to the next
+// endpoint right away if it fails. Only once all endpoints failed the request is retried
+// with exponential backoff, until the maximum retries are reached or the context gets
+// canceled. A single endpoint is therefore retried like a plain request with backoff
+func (group *EndpointGroup) Do(ctx context.Context, request func(endpoint string) ([]byte, error)) (data []byte, err error) {
+	if group == nil || len(group.endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints specified")
+	}
+
+	for i := 0; i < BackoffMaxRetries; i++ {
+		endpoints := group.GetEndpoints()
+
+		for _, endpoint := range endpoints {
+			data, err = group.request(endpoint, request)
+			if err == nil {
+				// only log success message if there were errors previously
+				if i > 0 {
+					logger.Info().Msg(fmt.Sprintf("successfully fetched data from endpoint %s", endpoint))
+				}
+				return data, nil
+			}
+
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			metrics.IncRequestRetries(getHost(endpoint))
+			logger.Error().Msg(fmt.Sprintf("request to endpoint \"%s\" failed with error \"%s\"", endpoint, err))
+		}
+
+		if i == BackoffMaxRetries-1 {
+			break
+		}
+
+		delaySec := math.Pow(2, float64(i))
+		logger.Error().Msg(fmt.Sprintf("request failed on all %d endpoints, retrying in %d seconds", len(endpoints), int(delaySec)))
+
+		if err := SleepWithContext(ctx, time.Duration(delaySec)*time.Second); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("request failed on all %d endpoints within maximum retry limit of %d: %w", len(group.endpoints), BackoffMaxRetries, err)
+}
+
+// request executes the request against the endpoint and records the health of the endpoint
+func (group *EndpointGroup) request(endpoint string, request func(endpoint string) ([]byte, error)) ([]byte, error) {
+	start := time.Now()
+
+	data, err := request(endpoint)
+	if err != nil {
+		group.recordFailure(endpoint)
+		return nil, err
+	}
+
+	group.recordSuccess(endpoint, time.Since(start))
+	return data, nil
+}
+
+// Get fetches the path, including the query, from the healthiest endpoint of the group
+func (group *EndpointGroup) Get(path string) ([]byte, error) {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	return group.Do(context.Background(), func(endpoint string) ([]byte, error) {
+		return getFromUrl(context.Background(), endpoint+path, transport)
+	})
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEndpointGroupFailsOverWithoutBackoff(t *testing.T) {
+	group := NewEndpointGroup([]string{"first", "second"})
+
+	start := time.Now()
+
+	data, err := group.Do(context.Background(), func(endpoint string) ([]byte, error) {
+		if endpoint == "first" {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return []byte(endpoint), nil
+	})
+	if err != nil {
+		t.Fatalf("failed to fail over: %s", err)
+	}
+
+	if string(data) != "second" {
+		t.Errorf("expected data from the second endpoint, got %s", data)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected no backoff between the endpoints, took %s", elapsed)
+	}
+
+	if endpoints := group.GetEndpoints(); endpoints[0] != "second" {
+		t.Errorf("expected the failing endpoint to be moved to the end, got %v", endpoints)
+	}
+}
+
+func TestEndpointGroupRetriesRotation(t *testing.T) {
+	group := NewEndpointGroup([]string{"first", "second"})
+
+	requests := 0
+
+	// both endpoints are down during the first rotation
+	data, err := group.Do(context.Background(), func(endpoint string) ([]byte, error) {
+		requests++
+		if requests <= 2 {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return []byte(endpoint), nil
+	})
+	if err != nil {
+		t.Fatalf("failed to retry the rotation: %s", err)
+	}
+
+	if requests != 3 || string(data) != "first" {
+		t.Errorf("expected the first endpoint to succeed in the second rotation, got %s after %d requests", data, requests)
+	}
+}
+
+func TestEndpointGroupCancelsBackoff(t *testing.T) {
+	group := NewEndpointGroup([]string{"first", "second"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := group.Do(ctx, func(endpoint string) ([]byte, error) {
+		return nil, fmt.Errorf("connection refused")
+	}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the backoff to be canceled, got %v", err)
+	}
+}
+
+func TestEndpointGroupWithoutEndpoints(t *testing.T) {
+	var group *EndpointGroup
+
+	if _, err := group.Get("/status"); err == nil {
+		t.Errorf("expected a request without endpoints to fail")
+	}
+}
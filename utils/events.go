@@ -201,7 +201,7 @@ func TrackPruningEvent(untilHeight int64, optOut bool) {
 	_ = err
 }
 
-func TrackServeSnapshotsEvent(engine types.Engine, chainId, chainRest, storageRest string, snapshotPort int64, rpcServer bool, rpcServerPort int64, startHeight int64, pruning, keepSnapshots, debug, optOut bool) {
+func TrackServeSnapshotsEvent(engine types.Engine, chainId string, chainRest *EndpointGroup, storageRest string, snapshotPort int64, rpcServer bool, rpcServerPort int64, startHeight int64, pruning, keepSnapshots, debug, optOut bool) {
 	if optOut {
 		return
 	}
@@ -223,7 +223,7 @@ func TrackServeSnapshotsEvent(engine types.Engine, chainId, chainRest, storageRe
 		Event:  SERVE_SNAPSHOTS,
 		Properties: analytics.NewProperties().
 			Set("chain_id", chainId).
-			Set("chain_rest", chainRest).
+			Set("chain_rest", chainRest.String()).
 			Set("storage_rest", storageRest).
 			Set("project", project).
 			Set("current_height", currentHeight).
@@ -241,7 +241,7 @@ func TrackServeSnapshotsEvent(engine types.Engine, chainId, chainRest, storageRe
 	_ = err
 }
 
-func TrackSyncStartEvent(engine types.Engine, syncType, chainId, chainRest, storageRest string, targetHeight int64, optOut bool) {
+func TrackSyncStartEvent(engine types.Engine, syncType, chainId string, chainRest *EndpointGroup, storageRest string, targetHeight int64, optOut bool) {
 	if optOut {
 		return
 	}
@@ -265,7 +265,7 @@ func TrackSyncStartEvent(engine types.Engine, syncType, chainId, chainRest, stor
 			Set("sync_id", syncId).
 			Set("sync_type", syncType).
 			Set("chain_id", chainId).
-			Set("chain_rest", chainRest).
+			Set("chain_rest", chainRest.String()).
 			Set("storage_rest", storageRest).
 			Set("project", project).
 			Set("current_height", currentHeight).
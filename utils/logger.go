@@ -1,31 +1,222 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"github.com/mattn/go-isatty"
 	"github.com/rs/zerolog"
 	"io"
 	"os"
+	"sync"
 )
 
-func KsyncLogger(moduleName string) zerolog.Logger {
-	writer := io.MultiWriter(os.Stdout)
-	customConsoleWriter := zerolog.ConsoleWriter{Out: writer}
-	customConsoleWriter.FormatCaller = func(i interface{}) string {
-		return "\x1b[36m[KSYNC]\x1b[0m"
+const (
+	LogFormatConsole = "console"
+	LogFormatJson    = "json"
+)
+
+var (
+	logFormat = LogFormatConsole
+
+	// logs are written to stderr if stdout is reserved for machine readable output
+	logStdout io.Writer = os.Stdout
+
+	// all loggers write to these outputs, this way loggers which are created
+	// on package initialization still follow the settings of the command flags
+	ksyncOutput = newLogOutput("KSYNC")
+	appOutput   = newLogOutput("APP")
+
+	// the status line is redrawn below every log line so it always stays at the bottom of the terminal
+	statusLine = &terminalStatusLine{}
+
+	// errorLogHandler gets notified about every error log, this way errors which do not
+	// stop the sync can still be reported
+	errorLogHandler   func(module, message string)
+	errorLogHandlerMu sync.RWMutex
+)
+
+func init() {
+	// debug logs of the apps are only shown if explicitly requested
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+}
+
+// logOutput is the writer of all loggers with the same prefix, the underlying
+// writer gets replaced once the logger is configured
+type logOutput struct {
+	mu     sync.RWMutex
+	prefix string
+	writer io.Writer
+}
+
+func newLogOutput(prefix string) *logOutput {
+	output := &logOutput{prefix: prefix}
+	output.writer = output.newWriter(LogFormatConsole, nil)
+	return output
+}
+
+func (output *logOutput) Write(p []byte) (int, error) {
+	output.mu.RLock()
+	defer output.mu.RUnlock()
+
+	statusLine.mu.Lock()
+	defer statusLine.mu.Unlock()
+
+	statusLine.clear()
+	defer statusLine.draw()
+
+	notifyErrorLogHandler(p)
+
+	return output.writer.Write(p)
+}
+
+// SetErrorLogHandler registers the handler which gets called with the module and the message of every error log
+func SetErrorLogHandler(handler func(module, message string)) {
+	errorLogHandlerMu.Lock()
+	defer errorLogHandlerMu.Unlock()
+
+	errorLogHandler = handler
+}
+
+func notifyErrorLogHandler(event []byte) {
+	errorLogHandlerMu.RLock()
+	defer errorLogHandlerMu.RUnlock()
+
+	if errorLogHandler == nil || !bytes.Contains(event, []byte(`"level":"error"`)) {
+		return
 	}
 
-	logger := zerolog.New(customConsoleWriter).With().Str("module", moduleName).Timestamp().Logger()
-	return logger
+	var fields struct {
+		Module  string `json:"module"`
+		Message string `json:"message"`
+	}
+
+	if err := json.Unmarshal(event, &fields); err == nil {
+		errorLogHandler(fields.Module, fields.Message)
+	}
 }
 
-func LogFormatter(keyvals ...interface{}) zerolog.Logger {
-	writer := io.MultiWriter(os.Stdout)
-	customConsoleWriter := zerolog.ConsoleWriter{Out: writer}
-	customConsoleWriter.FormatCaller = func(i interface{}) string {
-		return "\x1b[36m[APP]\x1b[0m"
+// terminalStatusLine is a single line at the bottom of the terminal, like a progress bar,
+// which gets overwritten on every update instead of being printed as a new line
+type terminalStatusLine struct {
+	mu   sync.Mutex
+	line string
+}
+
+func (status *terminalStatusLine) clear() {
+	if status.line != "" {
+		fmt.Print("\r\x1b[K")
+	}
+}
+
+func (status *terminalStatusLine) draw() {
+	if status.line != "" {
+		fmt.Print(status.line)
+	}
+}
+
+// StatusLineSupported returns whether a status line can be drawn, which is only the case if
+// stdout is a terminal and the logs are not written in the json format
+func StatusLineSupported() bool {
+	return logFormat == LogFormatConsole && isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// SetStatusLine replaces the status line at the bottom of the terminal, an empty line removes it
+func SetStatusLine(line string) {
+	statusLine.mu.Lock()
+	defer statusLine.mu.Unlock()
+
+	statusLine.clear()
+	statusLine.line = line
+	statusLine.draw()
+}
+
+// newWriter creates the writer for the format which writes to stdout and the log file if given.
+// The log file never contains color codes
+func (output *logOutput) newWriter(format string, file io.Writer) io.Writer {
+	if format == LogFormatJson {
+		if file != nil {
+			return io.MultiWriter(logStdout, file)
+		}
+		return logStdout
+	}
+
+	consoleWriter := func(out io.Writer, noColor bool) zerolog.ConsoleWriter {
+		writer := zerolog.ConsoleWriter{Out: out, NoColor: noColor, FieldsExclude: []string{"logger"}}
+		writer.FormatCaller = func(i interface{}) string {
+			if noColor {
+				return fmt.Sprintf("[%s]", output.prefix)
+			}
+			return fmt.Sprintf("\x1b[36m[%s]\x1b[0m", output.prefix)
+		}
+		return writer
 	}
 
-	logger := zerolog.New(customConsoleWriter).With()
+	if file != nil {
+		return io.MultiWriter(consoleWriter(logStdout, false), consoleWriter(file, true))
+	}
+	return consoleWriter(logStdout, false)
+}
+
+func (output *logOutput) configure(format string, file io.Writer) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	output.writer = output.newWriter(format, file)
+}
+
+// ConfigureLogger applies the log format, the log level and the optional log file to all
+// KSYNC and app loggers, including the ones which were already created. If stderr is true
+// the logs are written to stderr instead of stdout
+func ConfigureLogger(format, level, logFile string, stderr bool) error {
+	if format != LogFormatConsole && format != LogFormatJson {
+		return fmt.Errorf("log format %s not supported, use \"%s\" or \"%s\"", format, LogFormatConsole, LogFormatJson)
+	}
+
+	parsedLevel, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("failed to parse log level %s: %w", level, err)
+	}
+
+	var file io.Writer
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %w", err)
+		}
+		file = f
+	}
+
+	zerolog.SetGlobalLevel(parsedLevel)
+	logFormat = format
+
+	if stderr {
+		logStdout = os.Stderr
+	}
+
+	ksyncOutput.configure(format, file)
+	appOutput.configure(format, file)
+	return nil
+}
+
+// Prompt prints a question to the user. In the json log format stdout only contains json
+// logs, so the question is written to stderr without color codes
+func Prompt(format string, args ...interface{}) {
+	if logFormat == LogFormatJson {
+		fmt.Fprintf(os.Stderr, "[KSYNC] %s", fmt.Sprintf(format, args...))
+		return
+	}
+
+	fmt.Printf("\u001B[36m[KSYNC]\u001B[0m %s", fmt.Sprintf(format, args...))
+}
+
+func KsyncLogger(moduleName string) zerolog.Logger {
+	logger := zerolog.New(ksyncOutput).With().Str("logger", "ksync").Str("module", moduleName).Timestamp().Logger()
+	return logger
+}
+
+func LogFormatter(keyvals ...interface{}) zerolog.Logger {
+	logger := zerolog.New(appOutput).With().Str("logger", "app")
 
 	if len(keyvals) > 1 {
 		for i := 0; i < len(keyvals); i = i + 2 {
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// tailBuffer keeps only the last bytes written to it, it is used to
+// report the last output of the binary if it exits unexpectedly
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (buffer *tailBuffer) Write(p []byte) (int, error) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	buffer.data = append(buffer.data, p...)
+	if len(buffer.data) > ProcessOutputTailSize {
+		buffer.data = buffer.data[len(buffer.data)-ProcessOutputTailSize:]
+	}
+
+	return len(p), nil
+}
+
+func (buffer *tailBuffer) String() string {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+
+	return strings.TrimSpace(string(buffer.data))
+}
+
+// Process supervises a binary process started by KSYNC. It owns the command, keeps track
+// of its exit and the tail of its output so a crashed binary is reported right away
+// instead of letting KSYNC wait for it forever
+type Process struct {
+	cmd      *exec.Cmd
+	output   *tailBuffer
+	exited   chan struct{}
+	exitErr  error
+	stopping bool
+	mu       sync.Mutex
+}
+
+var (
+	// runningProcesses are all binary processes which have been started and did not exit yet,
+	// so they can still be killed when KSYNC has to exit immediately
+	runningProcesses   = make(map[*Process]struct{})
+	runningProcessesMu sync.Mutex
+)
+
+// startProcess starts the command in its own process group and supervises it until it exits
+func startProcess(cmd *exec.Cmd) (*Process, error) {
+	process := &Process{
+		cmd:    cmd,
+		output: &tailBuffer{},
+		exited: make(chan struct{}),
+	}
+
+	// Cosmos apps log to stdout, so the tail of stdout and stderr is kept together
+	if cmd.Stdout != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, process.output)
+	} else {
+		cmd.Stdout = process.output
+	}
+
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, process.output)
+	} else {
+		cmd.Stderr = process.output
+	}
+
+	// run the binary in its own process group so a Ctrl-C in the terminal does not stop it
+	// directly, instead KSYNC stops it after the current block was applied
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start binary process: %w", err)
+	}
+
+	runningProcessesMu.Lock()
+	runningProcesses[process] = struct{}{}
+	runningProcessesMu.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+
+		runningProcessesMu.Lock()
+		delete(runningProcesses, process)
+		runningProcessesMu.Unlock()
+
+		process.mu.Lock()
+		process.exitErr = process.describeExit(err)
+		stopping := process.stopping
+		process.mu.Unlock()
+
+		if !stopping {
+			logger.Error().Msg(process.exitErr.Error())
+		}
+
+		close(process.exited)
+	}()
+
+	return process, nil
+}
+
+// describeExit creates the error which reports how the binary process exited
+func (process *Process) describeExit(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		err = fmt.Errorf("binary process exited with code %d", exitErr.ExitCode())
+	} else if err != nil {
+		err = fmt.Errorf("binary process exited: %w", err)
+	} else {
+		err = fmt.Errorf("binary process exited with code 0")
+	}
+
+	if tail := process.output.String(); tail != "" {
+		return fmt.Errorf("%w, last output:\n%s", err, tail)
+	}
+
+	return err
+}
+
+// Pid returns the process id of the binary process
+func (process *Process) Pid() int {
+	if process == nil {
+		return 0
+	}
+
+	return process.cmd.Process.Pid
+}
+
+// Exited is closed once the binary process has exited
+func (process *Process) Exited() <-chan struct{} {
+	if process == nil {
+		return nil
+	}
+
+	return process.exited
+}
+
+// ExitError returns the error describing how the binary process exited, nil if it is still running
+func (process *Process) ExitError() error {
+	if process == nil {
+		return nil
+	}
+
+	select {
+	case <-process.exited:
+		process.mu.Lock()
+		defer process.mu.Unlock()
+		return process.exitErr
+	default:
+		return nil
+	}
+}
+
+// Sleep sleeps for the given duration. It returns the context error if the context gets
+// canceled and the exit error if the binary process exits in the meantime
+func (process *Process) Sleep(ctx context.Context, duration time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-process.Exited():
+		return process.ExitError()
+	case <-time.After(duration):
+		return nil
+	}
+}
+
+// WaitUntilReady polls the ready check until it succeeds. It returns an error right away if the binary
+// process exits in the meantime or if it is not ready within the timeout. A timeout of zero waits forever
+func (process *Process) WaitUntilReady(ready func() error, timeout time.Duration) error {
+	if process == nil {
+		return nil
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+
+	for {
+		err := ready()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-process.exited:
+			return fmt.Errorf("binary process exited before it was ready: %w", process.ExitError())
+		case <-deadline:
+			return fmt.Errorf("binary process was not ready within %s: %w", timeout, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// WaitForAbciSocket waits until the binary accepts connections on the ABCI address. The
+// address has the format of the proxy_app config, for example tcp://127.0.0.1:26658
+func (process *Process) WaitForAbciSocket(address string) error {
+	protocol, addr, found := strings.Cut(address, "://")
+	if !found {
+		protocol, addr = "tcp", address
+	}
+
+	return process.WaitUntilReady(func() error {
+		conn, err := net.DialTimeout(protocol, addr, time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}, ProcessStartupTimeout)
+}
+
+// Stop sends SIGTERM to the binary process and waits until it has exited. If it does not exit
+// within the shutdown timeout the whole process group gets killed with SIGKILL
+func (process *Process) Stop() error {
+	if process == nil {
+		return nil
+	}
+
+	process.mu.Lock()
+	process.stopping = true
+	process.mu.Unlock()
+
+	select {
+	case <-process.exited:
+		return nil
+	default:
+	}
+
+	if err := process.cmd.Process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("failed to stop binary process with SIGTERM: %w", err)
+	}
+
+	select {
+	case <-process.exited:
+		logger.Info().Msg(fmt.Sprintf("stopped binary process with process id %d", process.Pid()))
+		return nil
+	case <-time.After(ProcessShutdownTimeout):
+	}
+
+	logger.Error().Msg(fmt.Sprintf("binary process did not exit within %s, killing it", ProcessShutdownTimeout))
+
+	if err := syscall.Kill(-process.Pid(), syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("failed to kill binary process with SIGKILL: %w", err)
+	}
+
+	<-process.exited
+	logger.Info().Msg(fmt.Sprintf("killed binary process with process id %d", process.Pid()))
+	return nil
+}
+
+// KillRunningProcesses kills the process groups of all binary processes which are still
+// running with SIGKILL. It is used when KSYNC exits without stopping them gracefully
+func KillRunningProcesses() {
+	runningProcessesMu.Lock()
+	defer runningProcessesMu.Unlock()
+
+	for process := range runningProcesses {
+		process.mu.Lock()
+		process.stopping = true
+		process.mu.Unlock()
+
+		if err := syscall.Kill(-process.Pid(), syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+			logger.Error().Msg(fmt.Sprintf("failed to kill binary process with process id %d: %s", process.Pid(), err))
+			continue
+		}
+
+		logger.Info().Msg(fmt.Sprintf("killed binary process with process id %d", process.Pid()))
+	}
+}
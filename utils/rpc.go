@@ -4,10 +4,50 @@ import (
 	"fmt"
 	"github.com/KYVENetwork/ksync/types"
 	"github.com/tendermint/tendermint/libs/json"
+	"sort"
 )
 
+// GetStatusFromRpc returns the combined status of all block rpc endpoints. Since pruned nodes
+// may only cover parts of the chain the latest height is the end of the range the reachable
+// endpoints cover without gaps, starting from the lowest earliest height
 func GetStatusFromRpc(blockRpcConfig types.BlockRpcConfig) (*types.StatusResponse, error) {
-	result, err := GetFromUrlWithOptions(fmt.Sprintf("%s/status", blockRpcConfig.Endpoint),
+	var statuses []*types.StatusResponse
+
+	for _, endpoint := range blockRpcConfig.Endpoints {
+		status, err := GetStatusFromEndpoint(endpoint)
+		if err != nil {
+			logger.Error().Msg(fmt.Sprintf("failed to get status from block rpc %s: %s", endpoint, err))
+			continue
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("failed to get status from any block rpc")
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Result.SyncInfo.EarliestBlockHeight < statuses[j].Result.SyncInfo.EarliestBlockHeight
+	})
+
+	combined := statuses[0]
+
+	for _, status := range statuses[1:] {
+		if status.Result.SyncInfo.EarliestBlockHeight > combined.Result.SyncInfo.LatestBlockHeight+1 {
+			logger.Error().Msg(fmt.Sprintf("block rpcs do not cover blocks from %d to %d, only blocks up to %d can be synced", combined.Result.SyncInfo.LatestBlockHeight+1, status.Result.SyncInfo.EarliestBlockHeight-1, combined.Result.SyncInfo.LatestBlockHeight))
+			break
+		}
+
+		combined.Result.SyncInfo.LatestBlockHeight = max(combined.Result.SyncInfo.LatestBlockHeight, status.Result.SyncInfo.LatestBlockHeight)
+	}
+
+	return combined, nil
+}
+
+// GetStatusFromEndpoint returns the status of a single block rpc endpoint
+func GetStatusFromEndpoint(endpoint string) (*types.StatusResponse, error) {
+	result, err := GetFromUrlWithOptions(fmt.Sprintf("%s/status", endpoint),
 		GetFromUrlOptions{SkipTLSVerification: true},
 	)
 	if err != nil {
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/KYVENetwork/ksync/types"
+	"time"
+)
+
+// StopSyncGracefully stops the binary process and closes the dbs of the engine after a sync
+// got interrupted and logs a summary of the progress which was made until then
+func StopSyncGracefully(engine types.Engine, process *Process, syncType string, startHeight int64, start time.Time) error {
+	height := engine.GetHeight()
+
+	if err := process.Stop(); err != nil {
+		return fmt.Errorf("failed to stop binary process: %w", err)
+	}
+
+	if err := engine.CloseDBs(); err != nil {
+		return fmt.Errorf("failed to close dbs in engine: %w", err)
+	}
+
+	logger.Info().Msg(fmt.Sprintf("%s was interrupted at height %d after syncing %d blocks in %.2f seconds. Run the same command again to continue", syncType, height, height-startHeight, time.Since(start).Seconds()))
+	return fmt.Errorf("%s was interrupted at height %d: %w", syncType, height, context.Canceled)
+}
+
+// StopStateSyncGracefully stops the binary process and closes the dbs of the engine after a sync
+// got interrupted while applying a snapshot. A snapshot can not be partially applied, so the
+// app has to be reset before the sync can be started again
+func StopStateSyncGracefully(engine types.Engine, process *Process, syncType string) error {
+	if err := process.Stop(); err != nil {
+		return fmt.Errorf("failed to stop binary process: %w", err)
+	}
+
+	if err := engine.CloseDBs(); err != nil {
+		return fmt.Errorf("failed to close dbs in engine: %w", err)
+	}
+
+	logger.Info().Msg(fmt.Sprintf("%s was interrupted before the snapshot was fully applied. Reset the node with \"ksync reset-all\" before running the command again", syncType))
+	return fmt.Errorf("%s was interrupted while applying the snapshot: %w", syncType, context.Canceled)
+}
@@ -2,13 +2,15 @@ package utils
 
 import (
 	"bytes"
-	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"crypto/tls"
 	"fmt"
+	"github.com/KYVENetwork/ksync/metrics"
 	"io"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"runtime"
 	runtimeDebug "runtime/debug"
@@ -31,7 +33,12 @@ func GetVersion() string {
 }
 
 // getFromUrl tries to fetch data from url with a custom User-Agent header
-func getFromUrl(url string, transport *http.Transport) ([]byte, error) {
+func getFromUrl(ctx context.Context, url string, transport *http.Transport) ([]byte, error) {
+	return doRequest(ctx, "GET", url, nil, transport)
+}
+
+// doRequest sends a request with a custom User-Agent header and returns the response body
+func doRequest(ctx context.Context, method, url string, body []byte, transport *http.Transport) ([]byte, error) {
 	// Create a custom http.Client with the desired User-Agent header
 	client := &http.Client{Transport: http.DefaultTransport}
 
@@ -39,12 +46,21 @@ func getFromUrl(url string, transport *http.Transport) ([]byte, error) {
 		client = &http.Client{Transport: transport}
 	}
 
-	// Create a new GET request
-	request, err := http.NewRequest("GET", url, nil)
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	// Create a new request
+	request, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, err
 	}
 
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
 	// Set the User-Agent header
 	version := GetVersion()
 
@@ -77,15 +93,31 @@ func getFromUrl(url string, transport *http.Transport) ([]byte, error) {
 }
 
 // getFromUrlWithBackoff tries to fetch data from url with exponential backoff
-func getFromUrlWithBackoff(url string, transport *http.Transport) (data []byte, err error) {
+func getFromUrlWithBackoff(ctx context.Context, url string, transport *http.Transport) (data []byte, err error) {
+	return withBackoff(ctx, url, func() ([]byte, error) {
+		return getFromUrl(ctx, url, transport)
+	})
+}
+
+// withBackoff retries the request to the url with exponential backoff until
+// the maximum retries are reached or the context gets canceled
+func withBackoff(ctx context.Context, url string, request func() ([]byte, error)) (data []byte, err error) {
 	for i := 0; i < BackoffMaxRetries; i++ {
-		data, err = getFromUrl(url, transport)
+		data, err = request()
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
 			delaySec := math.Pow(2, float64(i))
 			delay := time.Duration(delaySec) * time.Second
 
 			logger.Error().Msg(fmt.Sprintf("failed to fetch from url \"%s\" with error \"%s\", retrying in %d seconds", url, err, int(delaySec)))
-			time.Sleep(delay)
+			metrics.IncRequestRetries(getHost(url))
+
+			if err := SleepWithContext(ctx, delay); err != nil {
+				return nil, err
+			}
 
 			continue
 		}
@@ -101,9 +133,17 @@ func getFromUrlWithBackoff(url string, transport *http.Transport) (data []byte,
 	return
 }
 
+// getHost returns the host of the url which is used to label request metrics
+func getHost(rawUrl string) string {
+	if u, err := url.Parse(rawUrl); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return rawUrl
+}
+
 // GetFromUrl tries to fetch data from url with a custom User-Agent header
 func GetFromUrl(url string) ([]byte, error) {
-	return getFromUrl(url, nil)
+	return getFromUrl(context.Background(), url, nil)
 }
 
 type GetFromUrlOptions struct {
@@ -113,6 +153,12 @@ type GetFromUrlOptions struct {
 
 // GetFromUrlWithOptions tries to fetch data from url with a custom User-Agent header and custom options
 func GetFromUrlWithOptions(url string, options GetFromUrlOptions) ([]byte, error) {
+	return GetFromUrlWithContext(context.Background(), url, options)
+}
+
+// GetFromUrlWithContext is like GetFromUrlWithOptions, but the request and the backoff
+// between the retries get canceled with the context
+func GetFromUrlWithContext(ctx context.Context, url string, options GetFromUrlOptions) ([]byte, error) {
 	var transport *http.Transport
 	if options.SkipTLSVerification {
 		transport = &http.Transport{
@@ -120,9 +166,40 @@ func GetFromUrlWithOptions(url string, options GetFromUrlOptions) ([]byte, error
 		}
 	}
 	if options.WithBackoff {
-		return getFromUrlWithBackoff(url, transport)
+		return getFromUrlWithBackoff(ctx, url, transport)
+	}
+	return getFromUrl(ctx, url, transport)
+}
+
+// PostToUrlWithOptions sends the json body to url with a custom User-Agent header and custom options
+func PostToUrlWithOptions(url string, body []byte, options GetFromUrlOptions) ([]byte, error) {
+	var transport *http.Transport
+	if options.SkipTLSVerification {
+		transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	if !options.WithBackoff {
+		return doRequest(context.Background(), "POST", url, body, transport)
 	}
-	return getFromUrl(url, transport)
+
+	var data []byte
+	var err error
+
+	for i := 0; i < BackoffMaxRetries; i++ {
+		data, err = doRequest(context.Background(), "POST", url, body, transport)
+		if err == nil {
+			return data, nil
+		}
+
+		delaySec := math.Pow(2, float64(i))
+		logger.Error().Msg(fmt.Sprintf("failed to post to url \"%s\" with error \"%s\", retrying in %d seconds", url, err, int(delaySec)))
+		metrics.IncRequestRetries(getHost(url))
+		time.Sleep(time.Duration(delaySec) * time.Second)
+	}
+
+	return nil, err
 }
 
 // GetFromUrlWithBackoff tries to fetch data from url with exponential backoff
@@ -130,6 +207,17 @@ func GetFromUrlWithBackoff(url string) (data []byte, err error) {
 	return GetFromUrlWithOptions(url, GetFromUrlOptions{SkipTLSVerification: true, WithBackoff: true})
 }
 
+// SleepWithContext sleeps for the given duration and returns the context error
+// if the context got canceled in the meantime
+func SleepWithContext(ctx context.Context, duration time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(duration):
+		return nil
+	}
+}
+
 func CreateSha256Checksum(input []byte) (hash string) {
 	h := sha256.New()
 	h.Write(input)
@@ -137,20 +225,6 @@ func CreateSha256Checksum(input []byte) (hash string) {
 	return fmt.Sprintf("%x", bs)
 }
 
-func DecompressGzip(input []byte) ([]byte, error) {
-	var out bytes.Buffer
-	r, err := gzip.NewReader(bytes.NewBuffer(input))
-	if err != nil {
-		return nil, err
-	}
-
-	if _, err := io.Copy(&out, r); err != nil {
-		return nil, err
-	}
-
-	return out.Bytes(), nil
-}
-
 func IsFileGreaterThanOrEqualTo100MB(filePath string) (bool, error) {
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
@@ -200,20 +274,21 @@ func ParseSnapshotFromKey(key string) (height int64, chunkIndex int64, err error
 	return
 }
 
-func GetChainRest(chainId, chainRest string) string {
+// GetChainRest returns the rest endpoints of the KYVE chain, multiple endpoints can be
+// given comma separated and requests fail over between them
+func GetChainRest(chainId, chainRest string) *EndpointGroup {
 	if chainRest != "" {
-		// trim trailing slash
-		return strings.TrimSuffix(chainRest, "/")
+		return NewEndpointGroup(ParseEndpoints(chainRest))
 	}
 
 	// if no custom rest endpoint was given we take it from the chainId
 	switch chainId {
 	case ChainIdMainnet:
-		return RestEndpointMainnet
+		return NewEndpointGroup([]string{RestEndpointMainnet})
 	case ChainIdKaon:
-		return RestEndpointKaon
+		return NewEndpointGroup([]string{RestEndpointKaon})
 	case ChainIdKorellia:
-		return RestEndpointKorellia
+		return NewEndpointGroup([]string{RestEndpointKorellia})
 	default:
 		panic(fmt.Sprintf("flag --chain-id has to be either \"%s\", \"%s\" or \"%s\"", ChainIdMainnet, ChainIdKaon, ChainIdKorellia))
 	}